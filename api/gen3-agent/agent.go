@@ -5,12 +5,17 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	agentHelper "github.com/uc-cdis/gen3-admin/gen3-agent/helpers"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func init() {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -22,6 +27,22 @@ func init() {
 	flag.DurationVar(&agentHelper.StatusUpdateInterval, "status-interval", agentHelper.DefaultStatusUpdateInterval, "Interval for sending status updates")
 	flag.StringVar(&agentHelper.GrpcServerURL, "server-address", agentHelper.DefaultGRPCServerURL, "Address of the GRPC server")
 	flag.StringVar(&agentHelper.Kubeconfig, "kubeconfig", agentHelper.Kubeconfig, "Path to kubeconfig file")
+	flag.DurationVar(&agentHelper.RenewBefore, "renew-before", agentHelper.DefaultRenewBefore, "Renew the client certificate this long before it expires")
+	flag.StringVar(&agentHelper.JoinTokenFile, "join-token-file", agentHelper.DefaultJoinTokenFile, "Join token used to enroll when no client certificate exists yet")
+	flag.StringVar(&agentHelper.CertSecretName, "cert-secret", agentHelper.DefaultCertSecretName, "Kubernetes Secret holding the agent certificates")
+	flag.StringVar(&agentHelper.DeploymentName, "deployment", agentHelper.DefaultDeploymentName, "Deployment running the agent, patched on upgrade")
+	flag.StringVar(&agentHelper.ContainerName, "container", agentHelper.DefaultContainerName, "Agent container in the deployment")
+	flag.DurationVar(&agentHelper.ReconnectMinBackoff, "reconnect-min-backoff", agentHelper.DefaultReconnectMinBackoff, "Initial delay before reconnecting to the server")
+	flag.Func("compression", "Comma separated codecs offered to the server for response bodies, \"none\" to disable (default \"zstd,gzip\")", func(v string) error {
+		agentHelper.Compression = nil
+		if v != "none" {
+			agentHelper.Compression = strings.Split(v, ",")
+		}
+		return nil
+	})
+	flag.IntVar(&agentHelper.CompressionThreshold, "compression-threshold", agentHelper.DefaultCompressionThreshold, "Smallest response chunk in bytes that gets compressed")
+	flag.DurationVar(&agentHelper.ReconnectMaxBackoff, "reconnect-max-backoff", agentHelper.DefaultReconnectMaxBackoff, "Maximum delay between reconnect attempts")
+	flag.StringVar(&agentHelper.MetricsAddress, "metrics-address", agentHelper.DefaultMetricsAddress, "Address serving Prometheus metrics on /metrics, empty to disable")
 	flag.Parse()
 
 	if agentHelper.AgentName == "" {
@@ -37,20 +58,30 @@ func main() {
 	zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	log.Logger = log.With().Caller().Logger()
 
-	agent, err := agentHelper.NewAgent(agentHelper.AgentName, "1.0.0", agentHelper.GrpcServerURL, agentHelper.StatusUpdateInterval)
+	// An upgrade sets the version on the container, it wins over the build version
+	if v := os.Getenv(agentHelper.VersionEnv); v != "" {
+		version = v
+	}
+
+	agent, err := agentHelper.NewAgent(agentHelper.AgentName, version, agentHelper.GrpcServerURL, agentHelper.StatusUpdateInterval)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create agent")
 	}
 
+	if agentHelper.MetricsAddress != "" {
+		go agentHelper.ServeMetrics(agentHelper.MetricsAddress)
+	}
+
 	ctx := context.Background()
-	err = agent.Connect(ctx)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.ConfigFromEnv("gen3-agent", version))
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to connect to server")
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
 	}
+	defer shutdownTracing(context.Background())
 
-	log.Info().Msg("Agent connected and running")
-	err = agent.Run(ctx)
+	err = agent.Supervise(ctx)
 	if err != nil {
+		shutdownTracing(context.Background())
 		log.Fatal().Err(err).Msg("Agent encountered an error")
 	}
 }
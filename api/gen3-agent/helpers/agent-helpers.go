@@ -1,17 +1,21 @@
 package agentHelper
 
 import (
-	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -20,12 +24,14 @@ import (
 	"github.com/uc-cdis/gen3-admin/internal/argocd"
 	"github.com/uc-cdis/gen3-admin/internal/helm"
 	"github.com/uc-cdis/gen3-admin/internal/k8s"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 	"github.com/uc-cdis/gen3-admin/internal/tunnel"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+	"github.com/uc-cdis/gen3-admin/pkg/cluster"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
@@ -43,18 +49,46 @@ var (
 	AgentName            string
 	AgentCertFile        string
 	AgentKeyFile         string
+	JoinTokenFile        string
 	lastStatusUpdate     time.Time
 	StatusUpdateInterval time.Duration
 	stream               pb.TunnelService_ConnectClient
 	GrpcServerURL        = "localhost:50051"
 	Kubeconfig           = "~/.kube/config"
+	RenewBefore          = DefaultRenewBefore
+	CertSecretName       = DefaultCertSecretName
+	DeploymentName       = DefaultDeploymentName
+	ContainerName        = DefaultContainerName
 )
 
 const (
 	DefaultGRPCServerURL        = "localhost:50051"
 	DefaultStatusUpdateInterval = 30 * time.Second
+	DefaultRenewBefore          = 30 * 24 * time.Hour
+	DefaultCertSecretName       = "csoc-tls"
+	DefaultJoinTokenFile        = "certs/join-token"
+	DefaultDeploymentName       = "csoc-agent"
+	DefaultContainerName        = "agent"
 )
 
+// Capabilities advertised to the server at registration
+var Capabilities = []string{
+	pb.CapabilityK8sProxy,
+	pb.CapabilityHTTPProxy,
+	pb.CapabilityHelm,
+	pb.CapabilityTerminal,
+	pb.CapabilityDbUiPostgres,
+	pb.CapabilityDbUiElasticsearch,
+	pb.CapabilityCertRenewal,
+	pb.CapabilitySelfUpgrade,
+	pb.CapabilityFlowControl,
+	pb.CapabilityRequestStreaming,
+	pb.CapabilityPortForward,
+	pb.CapabilityK8sUpgrade,
+	pb.CapabilityTerminalControl,
+	pb.CapabilityTerminalDebug,
+}
+
 type Agent struct {
 	Name                 string
 	Certificate          string
@@ -65,15 +99,47 @@ type Agent struct {
 	statusUpdateInterval time.Duration
 	proxyCancelMu        sync.Mutex
 	proxyCancelFuncs     map[string]context.CancelFunc
+
+	// flows holds the credit windows of proxy streams the server flow controls
+	flowsMu sync.Mutex
+	flows   map[string]*flowWindow
+	// bodies holds request bodies the server streams in chunks
+	bodiesMu sync.Mutex
+	bodies   map[string]*requestBody
+	// compression of response bodies, negotiated at registration
+	compression streamCompression
+	// forwards holds the target connections of open port-forwards
+	forwardsMu sync.Mutex
+	forwards   map[string]net.Conn
+	// spans of requests in flight, errors sent for a stream are recorded on them
+	spansMu sync.Mutex
+	spans   map[string]*tracedRequest
+
+	// session is cancelled when the stream breaks, reconnects counts new streams
+	sessionMu  sync.Mutex
+	session    context.Context
+	reconnects atomic.Int64
+
+	// cert is swapped in place on renewal, new TLS handshakes pick it up
+	certMu     sync.RWMutex
+	cert       *tls.Certificate
+	renewalKey *ecdsa.PrivateKey
 }
 
 func (a *Agent) sendMessage(msg *pb.AgentMessage) error {
 	a.sendMu.Lock()
 	defer a.sendMu.Unlock()
-	return a.stream.Send(msg)
+	if a.stream == nil {
+		return errNotConnected
+	}
+	if err := a.stream.Send(msg); err != nil {
+		return err
+	}
+	messagesSent.WithLabelValues(messageType(msg)).Inc()
+	return nil
 }
 
-var activeExecSessions sync.Map // sessionId -> *io.PipeWriter
+var activeExecSessions sync.Map // sessionId -> *execSession
 
 type terminalInit struct {
 	Type      string   `json:"type"`
@@ -82,6 +148,12 @@ type terminalInit struct {
 	Pod       string   `json:"pod"`
 	Container string   `json:"container"`
 	Command   []string `json:"command"`
+	// Tty defaults to true, servers before terminal-control don't send it
+	Tty  *bool  `json:"tty,omitempty"`
+	Cols uint32 `json:"cols,omitempty"`
+	Rows uint32 `json:"rows,omitempty"`
+	// Debug attaches to a debug container instead of exec'ing in Container
+	Debug *terminalDebug `json:"debug,omitempty"`
 }
 
 // Helper function to get secret keys for debugging
@@ -94,10 +166,6 @@ func getSecretKeys(secret *corev1.Secret) []string {
 }
 
 func NewAgent(name, version, serverAddress string, statusInterval time.Duration) (*Agent, error) {
-	cert, err := tls.LoadX509KeyPair(AgentCertFile, AgentKeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("error loading client certificates: %v", err)
-	}
 	certPool := x509.NewCertPool()
 	// TODO: Load CA location from config
 	ca, err := os.ReadFile("certs/ca.crt")
@@ -108,13 +176,38 @@ func NewAgent(name, version, serverAddress string, statusInterval time.Duration)
 		return nil, fmt.Errorf("failed to append ca certs")
 	}
 
+	cert, err := tls.LoadX509KeyPair(AgentCertFile, AgentKeyFile)
+	if err != nil {
+		// No certificate yet, enroll with the join token from the manifest
+		if _, statErr := os.Stat(JoinTokenFile); statErr != nil {
+			return nil, fmt.Errorf("error loading client certificates: %v", err)
+		}
+		enrolled, err := enroll(name, serverAddress, certPool)
+		if err != nil {
+			return nil, fmt.Errorf("error enrolling agent: %v", err)
+		}
+		cert = *enrolled
+	}
+
+	agent := &Agent{
+		Name:                 name,
+		Version:              version,
+		statusUpdateInterval: statusInterval,
+		proxyCancelFuncs:     make(map[string]context.CancelFunc),
+		flows:                make(map[string]*flowWindow),
+		bodies:               make(map[string]*requestBody),
+		forwards:             make(map[string]net.Conn),
+		spans:                make(map[string]*tracedRequest),
+		cert:                 &cert,
+	}
+
 	// serverAddressWithoutPort := strings.Split(serverAddress, ":")[0]
 
 	creds := credentials.NewTLS(&tls.Config{
 		ServerName: "csoc.gen3.org", // Replace with your actual server name
 		// ServerName:   serverAddressWithoutPort, // Replace with your actual server name
-		Certificates: []tls.Certificate{cert},
-		RootCAs:      certPool,
+		GetClientCertificate: agent.getClientCertificate,
+		RootCAs:              certPool,
 	})
 
 	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(creds))
@@ -126,45 +219,34 @@ func NewAgent(name, version, serverAddress string, statusInterval time.Duration)
 	// certPEM := string(stringCert)
 	// log.Debug().Msgf("Certificate: %s", certPEM)
 
-	client := pb.NewTunnelServiceClient(conn)
-	return &Agent{
-		Name:                 name,
-		Version:              version,
-		client:               client,
-		statusUpdateInterval: statusInterval,
-		proxyCancelFuncs:     make(map[string]context.CancelFunc),
-	}, nil
+	agent.client = pb.NewTunnelServiceClient(conn)
+	return agent, nil
 }
 
+// Connect opens a stream and registers on it. Retrying is left to Supervise.
 func (a *Agent) Connect(ctx context.Context) error {
-	var err error
-	for {
-		a.stream, err = a.client.Connect(ctx)
-		if err != nil {
-			if status.Code(err) == codes.Unavailable {
-				log.Warn().Err(err).Msg("Server unavailable, retrying in 5 seconds...")
-				time.Sleep(5 * time.Second)
-				continue
-			}
-			return fmt.Errorf("error establishing stream: %v", err)
-		}
-		log.Info().Msg("Established connection with the server")
-
-		err = a.sendMessage(&pb.AgentMessage{
-			Message: &pb.AgentMessage_Registration{
-				Registration: &pb.RegistrationRequest{
-					AgentName:    a.Name,
-					AgentVersion: a.Version,
-				},
+	stream, err := a.client.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("error establishing stream: %v", err)
+	}
+	a.sendMu.Lock()
+	a.stream = stream
+	a.sendMu.Unlock()
+	log.Info().Msg("Established connection with the server")
+
+	err = a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_Registration{
+			Registration: &pb.RegistrationRequest{
+				AgentName:    a.Name,
+				AgentVersion: a.Version,
+				Capabilities: Capabilities,
+				Compression:  Compression,
 			},
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("Error sending registration request")
-			continue
-		}
-		break
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error sending registration request: %v", err)
 	}
-
 	return nil
 }
 
@@ -190,18 +272,42 @@ func (a *Agent) collectAgentStatus() *pb.StatusUpdate {
 			HealthStatus: "ERROR",
 			Provider:     "ERROR",
 			K8SVersion:   "ERROR",
+			Reconnects:   a.reconnects.Load(),
 		}
 	}
 
 	// log.Debug().Msgf("Collected followin metrics: CPU: %v, Memory: %v, Provider: %s, K8s Version: %s", cpuPercent, vmStat.UsedPercent, k8sInfo.Provider, k8sInfo.Version)
 
-	return &pb.StatusUpdate{
+	status := &pb.StatusUpdate{
 		CpuUsage:     cpuPercent[0],
 		MemoryUsage:  vmStat.UsedPercent,
 		HealthStatus: "OK",
 		Provider:     k8sInfo.Provider,
 		K8SVersion:   k8sInfo.Version,
+		Reconnects:   a.reconnects.Load(),
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	health, err := cluster.GetClusterHealth(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error getting cluster health")
+		status.HealthStatus = "ERROR"
+		return status
+	}
+
+	status.NodeCount = int32(health.Nodes)
+	status.NotReadyNodes = int32(health.NotReadyNodes)
+	status.PodCapacity = int32(health.PodCapacity)
+	status.PodCount = int32(health.PodCount)
+	status.PendingPods = int32(health.PendingPods)
+	status.CrashLoopingPods = int32(health.CrashLoopingPods)
+	status.FailingPvcs = int32(health.FailingPVCs)
+	if !health.Healthy() {
+		status.HealthStatus = "DEGRADED"
+	}
+
+	return status
 }
 
 func (a *Agent) sendStatusUpdates(ctx context.Context) {
@@ -228,21 +334,25 @@ func (a *Agent) sendStatusUpdates(ctx context.Context) {
 	}
 }
 
-func (a *Agent) handleProxyRequest(req *pb.ProxyRequest) {
+func (a *Agent) handleProxyRequest(ctx context.Context, req *pb.ProxyRequest) {
 	log.Debug().Msgf("Handling proxy request: %v", req)
 	if req.Method == "CANCEL" {
 		log.Info().Msgf("Received cancellation for stream ID: %s", req.StreamId)
 		// Implement cancellation logic here
 		return
 	}
+	ctx, end := a.startSpan(ctx, "agent http proxy", req.StreamId,
+		semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLFull(req.Path))
+	defer end()
 
 	// Create HTTP request
-	httpReq, err := http.NewRequest(req.Method, req.Path, nil)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.Path, a.requestBodyReader(req))
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create request")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create request: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create request: %w", err))
 		return
 	}
+	setContentLength(httpReq, req)
 
 	// Set headers
 	for k, v := range req.Headers {
@@ -253,11 +363,11 @@ func (a *Agent) handleProxyRequest(req *pb.ProxyRequest) {
 	// Print headers
 
 	// Execute request
-	client := &http.Client{}
+	client := &http.Client{Transport: tracedTransport(http.DefaultTransport)}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed to execute request: %v", err)
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to execute request: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to execute request: %w", err))
 		return
 	}
 	defer resp.Body.Close()
@@ -301,6 +411,21 @@ func (a *Agent) sendProxyResponse(streamID string, status pb.ProxyResponseType,
 		resp.Headers[k] = v[0]
 	}
 
+	switch status {
+	case pb.ProxyResponseType_HEADERS:
+		a.spanResponded(streamID, statusCode)
+		a.compression.start(streamID, headers.Get("Content-Type"))
+	case pb.ProxyResponseType_DATA:
+		if err := a.waitForCredit(streamID); err != nil {
+			return err
+		}
+		resp.Body, resp.Encoding = a.compression.encode(streamID, body)
+	case pb.ProxyResponseType_END, pb.ProxyResponseType_ERROR:
+		defer a.closeFlow(streamID)
+		defer a.closeRequestBody(streamID)
+		defer a.compression.end(streamID)
+	}
+
 	// log.Debug().Msg("Sending proxy response")
 	err := a.sendMessage(&pb.AgentMessage{
 		Message: &pb.AgentMessage_Proxy{
@@ -315,14 +440,19 @@ func (a *Agent) sendProxyResponse(streamID string, status pb.ProxyResponseType,
 }
 
 func (a *Agent) sendErrorResponse(streamID string, err error) error {
+	te := tunnelError(err)
+	a.spanFailed(streamID, err)
 	resp := &pb.ProxyResponse{
 		StreamId:   streamID,
 		Status:     pb.ProxyResponseType_ERROR,
-		StatusCode: http.StatusInternalServerError,
+		StatusCode: int32(te.HTTPStatus()),
 		Headers:    map[string]string{"Content-Type": "text/plain"},
 		Body:       []byte(err.Error()),
+		Error:      te,
 	}
 	log.Debug().Msgf("Sending error response: %v", resp)
+	defer a.closeFlow(streamID)
+	defer a.closeRequestBody(streamID)
 	sendErr := a.sendMessage(&pb.AgentMessage{
 		Message: &pb.AgentMessage_Proxy{
 			Proxy: resp,
@@ -424,7 +554,7 @@ func getClientConfig() (*rest.Config, error) {
 
 }
 
-func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
+func (a *Agent) handleK8sProxyRequest(ctx context.Context, req *pb.ProxyRequest) {
 	streamID := req.StreamId
 	log.Info().
 		Str("stream_id", streamID).
@@ -451,8 +581,12 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 		return
 	}
 
+	ctx, end := a.startSpan(ctx, "agent k8s proxy", streamID,
+		semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLPath(req.Path))
+	defer end()
+
 	// Create a cancellable context for this request so the server can abort it
-	proxyCtx, cancel := context.WithCancel(context.Background())
+	proxyCtx, cancel := context.WithCancel(ctx)
 
 	// Register the cancel function so CANCEL messages can stop us
 	a.proxyCancelMu.Lock()
@@ -477,7 +611,7 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[k8s-proxy] Failed to setup k8s auth")
-		a.sendErrorResponse(streamID, fmt.Errorf("failed to setup k8s auth: %v", err))
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to setup k8s auth: %w", err))
 		return
 	}
 
@@ -491,16 +625,21 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 		Str("url", url).
 		Msg("[k8s-proxy] Forwarding request to k8s API server")
 
-	bodyReader := bytes.NewReader(req.Body)
-	httpReq, err := http.NewRequestWithContext(proxyCtx, req.Method, url, bodyReader)
+	if req.Upgrade {
+		a.proxyK8sUpgrade(proxyCtx, req, restConfig, url)
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(proxyCtx, req.Method, url, a.requestBodyReader(req))
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[k8s-proxy] Failed to create HTTP request")
-		a.sendErrorResponse(streamID, fmt.Errorf("failed to create request: %v", err))
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to create request: %w", err))
 		return
 	}
+	setContentLength(httpReq, req)
 
 	// Set Content-Type header from req.Headers if present; else fallback to application/json
 	if contentType, ok := req.Headers["Content-Type"]; ok {
@@ -516,7 +655,7 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[k8s-proxy] Failed to get transport for REST config")
-		a.sendErrorResponse(streamID, fmt.Errorf("failed to get transport for REST config: %v", err))
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to get transport for REST config: %w", err))
 		return
 	}
 
@@ -530,7 +669,7 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 
 	// Execute request with our cancellable context
 	client := &http.Client{
-		Transport: transport,
+		Transport: tracedTransport(transport),
 	}
 	resp, err := client.Do(httpReq)
 	if err != nil {
@@ -545,7 +684,7 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[k8s-proxy] Failed to execute request to k8s API")
-		a.sendErrorResponse(streamID, fmt.Errorf("failed to execute request: %v", err))
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to execute request: %w", err))
 		return
 	}
 
@@ -624,22 +763,24 @@ func (a *Agent) handleK8sProxyRequest(req *pb.ProxyRequest) {
 	}
 }
 
-func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
+func (a *Agent) handleProjectsRequest(ctx context.Context, req *pb.ProjectsRequest) {
 	log.Debug().Msg("Handling projects request for stream ID: " + req.StreamId)
+	ctx, end := a.startSpan(ctx, "agent projects", req.StreamId)
+	defer end()
 
 	// gather all helm deployments
 	helmDeployments, err := helm.ListAllHelmReleases()
 	if err != nil {
 		log.Error().Err(err).Msg("Error listing helm deployments")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error listing helm deployments: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error listing helm deployments: %w", err))
 		return
 	}
 
 	// Get all ArgoCD applications
-	argoCDApps, err := argocd.ListArgoCDApplications(context.TODO())
+	argoCDApps, err := argocd.ListArgoCDApplications(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("Error listing ArgoCD applications")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error listing ArgoCD applications: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error listing ArgoCD applications: %w", err))
 		return
 	}
 
@@ -647,7 +788,7 @@ func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
 	helmDeploymentsJSON, err = json.Marshal(helmDeployments)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling helm deployments")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm deployments: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm deployments: %w", err))
 		return
 	}
 
@@ -655,7 +796,7 @@ func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
 	argoCDAppsJSON, err = json.Marshal(argoCDApps)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling ArgoCD applications")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling ArgoCD applications: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling ArgoCD applications: %w", err))
 		return
 	}
 
@@ -666,14 +807,14 @@ func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
 	err = json.Unmarshal(helmDeploymentsJSON, &helmDeploymentsInterface)
 	if err != nil {
 		log.Error().Err(err).Msg("Error unmarshaling helm deployments")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling helm deployments: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling helm deployments: %w", err))
 		return
 	}
 
 	err = json.Unmarshal(argoCDAppsJSON, &argoCDAppsInterface)
 	if err != nil {
 		log.Error().Err(err).Msg("Error unmarshaling ArgoCD applications")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling ArgoCD applications: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling ArgoCD applications: %w", err))
 		return
 	}
 
@@ -683,7 +824,7 @@ func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
 	combinedJSON, err := json.Marshal(combinedInterface)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling combined JSON")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling combined JSON: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling combined JSON: %w", err))
 		return
 	}
 
@@ -693,8 +834,10 @@ func (a *Agent) handleProjectsRequest(req *pb.ProjectsRequest) {
 
 }
 
-func (a *Agent) handleDbUiRequest(req *pb.DbUiRequest) {
-	ctx := context.Background()
+func (a *Agent) handleDbUiRequest(ctx context.Context, req *pb.DbUiRequest) {
+	ctx, end := a.startSpan(ctx, "agent dbui", req.StreamId,
+		attribute.String("gen3.db.type", req.DbType), attribute.String("gen3.db.name", req.DbName))
+	defer end()
 
 	// Set defaults
 	namespace := req.Namespace
@@ -705,11 +848,16 @@ func (a *Agent) handleDbUiRequest(req *pb.DbUiRequest) {
 	log.Info().Msgf("Launching %s UI for database: %s in namespace: %s", req.DbType, req.DbName, namespace)
 
 	config, err := k8s.GetConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Error in getting k8s config")
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error in k8s config: %w", err))
+		return
+	}
+	config.Wrap(tracedTransport)
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		log.Error().Msg("Error in getting clientset")
-		a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 404, nil,
-			[]byte(fmt.Sprintf(`{"success": false, "message": "Error in clientset: %v"}`, err)))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error in clientset: %w", err))
 		return
 	}
 
@@ -823,8 +971,7 @@ logging.verbose: false
 		_, err = clientset.CoreV1().ConfigMaps(req.Namespace).Create(ctx, configMap, metav1.CreateOptions{})
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to create OpenSearch Dashboards config map")
-			a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 500, nil,
-				[]byte(fmt.Sprintf(`{"success": false, "message": "Failed to create OpenSearch Dashboards config map: %v"}`, err)))
+			a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create OpenSearch Dashboards config map: %w", err))
 			return
 		}
 	} else {
@@ -934,8 +1081,7 @@ logging.verbose: false
 	createdPod, err := clientset.CoreV1().Pods(req.Namespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create OpenSearch Dashboards pod")
-		a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 500, nil,
-			[]byte(fmt.Sprintf(`{"success": false, "message": "Failed to create OpenSearch Dashboards pod: %v"}`, err)))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create OpenSearch Dashboards pod: %w", err))
 		return
 	}
 
@@ -955,8 +1101,7 @@ func (a *Agent) handlePostgresUI(ctx context.Context, clientset kubernetes.Inter
 	secret, err := clientset.CoreV1().Secrets(req.Namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
 		log.Error().Err(err).Msgf("Secret %s not found", secretName)
-		a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_DATA, 404, nil,
-			[]byte(fmt.Sprintf(`{"success": false, "message": "Secret %s not found: %v"}`, secretName, err)))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to get secret %s: %w", secretName, err))
 		return
 	}
 
@@ -1125,8 +1270,7 @@ func (a *Agent) handlePostgresUI(ctx context.Context, clientset kubernetes.Inter
 	createdPod, err := clientset.CoreV1().Pods(req.Namespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create PgWeb pod")
-		a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 500, nil,
-			[]byte(fmt.Sprintf(`{"success": false, "message": "Failed to create PgWeb pod: %v"}`, err)))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create PgWeb pod: %w", err))
 		return
 	}
 
@@ -1173,8 +1317,7 @@ func (a *Agent) createOrUpdateService(ctx context.Context, clientset kubernetes.
 		createdService, err := clientset.CoreV1().Services(req.Namespace).Create(ctx, service, metav1.CreateOptions{})
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to create service")
-			a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 500, nil,
-				[]byte(fmt.Sprintf(`{"success": false, "message": "Failed to create service: %v"}`, err)))
+			a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to create service: %w", err))
 			return
 		}
 		log.Info().Msgf("Service %s created successfully", createdService.Name)
@@ -1185,8 +1328,7 @@ func (a *Agent) createOrUpdateService(ctx context.Context, clientset kubernetes.
 		_, err = clientset.CoreV1().Services(req.Namespace).Update(ctx, existingService, metav1.UpdateOptions{})
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to update service")
-			a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_ERROR, 500, nil,
-				[]byte(fmt.Sprintf(`{"success": false, "message": "Failed to update service: %v"}`, err)))
+			a.sendErrorResponse(req.StreamId, fmt.Errorf("failed to update service: %w", err))
 			return
 		}
 		log.Info().Msgf("Service %s updated successfully", serviceName)
@@ -1206,7 +1348,7 @@ func (a *Agent) createOrUpdateService(ctx context.Context, clientset kubernetes.
 		}`, strings.Title(appType), fmt.Sprintf("%s-%s", appType, req.DbName), serviceName, req.Namespace, req.DbName, port, req.DbType)))
 }
 
-func (a *Agent) handleHelmValuesRequest(req *pb.HelmValuesRequest) {
+func (a *Agent) handleHelmValuesRequest(ctx context.Context, req *pb.HelmValuesRequest) {
 
 	log.Debug().Msgf("Handling helm values request: %v", req)
 	if req.Release == "" {
@@ -1214,18 +1356,20 @@ func (a *Agent) handleHelmValuesRequest(req *pb.HelmValuesRequest) {
 		// Implement cancellation logic here
 		return
 	}
+	_, end := a.startSpan(ctx, "agent helm values", req.StreamId, helmAttributes(req.Release, req.Namespace)...)
+	defer end()
 
 	helmValues, err := helm.ShowHelmValues(req.Release, req.Namespace)
 	if err != nil {
 		log.Error().Err(err).Msg("Error getting helm values")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error getting helm values: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error getting helm values: %w", err))
 		return
 	}
 
 	responseJson, err := json.Marshal(helmValues)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling helm values")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm values: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm values: %w", err))
 		return
 	}
 
@@ -1233,7 +1377,7 @@ func (a *Agent) handleHelmValuesRequest(req *pb.HelmValuesRequest) {
 
 }
 
-func (a *Agent) handleHelmDeleteRequest(req *pb.HelmDeleteRequest) {
+func (a *Agent) handleHelmDeleteRequest(ctx context.Context, req *pb.HelmDeleteRequest) {
 
 	log.Debug().Msgf("Handling helm delete request: %v", req)
 	if req.Release == "" {
@@ -1241,11 +1385,13 @@ func (a *Agent) handleHelmDeleteRequest(req *pb.HelmDeleteRequest) {
 		// Implement cancellation logic here
 		return
 	}
+	_, end := a.startSpan(ctx, "agent helm delete", req.StreamId, helmAttributes(req.Release, req.Namespace)...)
+	defer end()
 
 	helmDelete, err := helm.DeleteHelmRelease(req.Release, req.Namespace)
 	if err != nil {
 		log.Error().Err(err).Msg("Error getting helm delete")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error getting helm delete: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error getting helm delete: %w", err))
 		return
 	}
 
@@ -1254,7 +1400,7 @@ func (a *Agent) handleHelmDeleteRequest(req *pb.HelmDeleteRequest) {
 	responseJson, err := json.Marshal(helmDelete)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling helm delete")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm delete: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling helm delete: %w", err))
 		return
 	}
 
@@ -1262,14 +1408,17 @@ func (a *Agent) handleHelmDeleteRequest(req *pb.HelmDeleteRequest) {
 
 }
 
-func (a *Agent) handleHelmInstallRequest(req *pb.HelmInstallRequest) {
+func (a *Agent) handleHelmInstallRequest(ctx context.Context, req *pb.HelmInstallRequest) {
 	log.Debug().Msgf("Handling helm install request: %v", req)
+	_, end := a.startSpan(ctx, "agent helm install", req.StreamId,
+		append(helmAttributes(req.Release, req.Namespace), attribute.String("helm.chart", req.Chart), attribute.String("helm.chart.version", req.Version))...)
+	defer end()
 
 	var values map[string]interface{}
 	err := json.Unmarshal(req.Values, &values)
 	if err != nil {
 		log.Error().Err(err).Msg("Error unmarshaling values")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling values: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error unmarshaling values: %w", err))
 		return
 	}
 
@@ -1289,7 +1438,7 @@ func (a *Agent) handleHelmInstallRequest(req *pb.HelmInstallRequest) {
 	err = installOps.Validate()
 	if err != nil {
 		log.Error().Err(err).Msg("Error validating install options")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error validating install options: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error validating install options: %w", err))
 		return
 	}
 
@@ -1298,70 +1447,115 @@ func (a *Agent) handleHelmInstallRequest(req *pb.HelmInstallRequest) {
 	install, err := helm.InstallHelmChart(installOps)
 	if err != nil {
 		log.Error().Err(err).Msg("Error installing chart")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error installing chart: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error installing chart: %w", err))
 		return
 	}
 
 	responseJson, err := json.Marshal(install)
 	if err != nil {
 		log.Error().Err(err).Msg("Error marshaling install response")
-		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling install response: %v", err))
+		a.sendErrorResponse(req.StreamId, fmt.Errorf("error marshaling install response: %w", err))
 		return
 	}
 
 	a.sendProxyResponse(req.StreamId, pb.ProxyResponseType_DATA, 0, nil, responseJson)
 }
 
+// Run serves the current stream until it breaks.
 func (a *Agent) Run(ctx context.Context) error {
+	a.sendMu.Lock()
+	stream := a.stream
+	a.sendMu.Unlock()
+	if stream == nil {
+		return errNotConnected
+	}
+
 	go a.sendStatusUpdates(ctx)
 
+	// Set once the server drains, requests in flight finish on this stream
+	// and the server closes it when they are done
+	var drain *drainError
 	for {
-		msg, err := a.stream.Recv()
+		msg, err := stream.Recv()
 		if err != nil {
+			if drain != nil {
+				return drain
+			}
 			if err == io.EOF {
 				log.Info().Msg("Server closed the connection")
-				return nil
+				return errors.New("server closed the connection")
 			}
 			return fmt.Errorf("error receiving message from server: %v", err)
 		}
 
+		messagesReceived.WithLabelValues(messageType(msg)).Inc()
+
+		// Work the message starts joins the server's trace
+		msgCtx := tracing.Extract(ctx, msg.TraceContext)
+
 		switch content := msg.Message.(type) {
 		case *pb.ServerMessage_HelmDeleteRequest:
 			log.Warn().Msg("Got a helm delete request message")
-			go a.handleHelmDeleteRequest(content.HelmDeleteRequest)
+			go a.handleHelmDeleteRequest(msgCtx, content.HelmDeleteRequest)
 		case *pb.ServerMessage_HelmInstallRequest:
 			log.Warn().Msg("Got a helm install request message")
-			go a.handleHelmInstallRequest(content.HelmInstallRequest)
+			go a.handleHelmInstallRequest(msgCtx, content.HelmInstallRequest)
 		case *pb.ServerMessage_HelmValuesRequest:
 			log.Warn().Msg("Got a helm values request message")
-			go a.handleHelmValuesRequest(content.HelmValuesRequest)
+			go a.handleHelmValuesRequest(msgCtx, content.HelmValuesRequest)
 		case *pb.ServerMessage_Projects:
 			log.Debug().Msg("Got a projects request message")
-			go a.handleProjectsRequest(content.Projects)
+			go a.handleProjectsRequest(msgCtx, content.Projects)
 		case *pb.ServerMessage_DbuiRequest:
 			log.Debug().Msgf("Got a DBUI request: %s", content)
-			go a.handleDbUiRequest(content.DbuiRequest)
+			go a.handleDbUiRequest(msgCtx, content.DbuiRequest)
 		case *pb.ServerMessage_Proxy:
+			if content.Proxy.Method == "CANCEL" {
+				a.closeFlow(content.Proxy.StreamId)
+				a.closeRequestBody(content.Proxy.StreamId)
+			} else {
+				a.openFlow(content.Proxy.StreamId, content.Proxy.Window)
+				if content.Proxy.BodyStreamed {
+					a.openRequestBody(content.Proxy.StreamId, content.Proxy.Window)
+				}
+			}
 			if content.Proxy.ProxyType == "k8s" {
 				// log.Debug().Msg("Got a k8s proxy request message")
 				// log.Debug().Msg(fmt.Sprintf("Content: %v", content.Proxy))
-				go a.handleK8sProxyRequest(content.Proxy)
+				go a.handleK8sProxyRequest(msgCtx, content.Proxy)
 			} else {
 				log.Debug().Msg("Got a non k8s proxy request message")
-				go a.handleProxyRequest(content.Proxy)
+				go a.handleProxyRequest(msgCtx, content.Proxy)
 			}
 		case *pb.ServerMessage_Registration:
 			if !content.Registration.Success {
-				// This is used as a way to signal that the server is ending the connection. Let's die.
-				log.Fatal().Msg("Connection killed by server, possibly a new agent connected. Exiting.")
+				// The server ends the connection this way when another agent registered with our name
+				log.Warn().Str("reason", content.Registration.Message).Msg("Connection closed by server")
+				return errReplaced
+			}
+			log.Info().Str("compression", content.Registration.Compression).Msgf("Registration response: %v", content.Registration.Success)
+			a.compression.setCodec(content.Registration.Compression)
+		case *pb.ServerMessage_CertificateRenewal:
+			go a.handleCertificateRenewal(content.CertificateRenewal)
+		case *pb.ServerMessage_Drain:
+			log.Info().Str("reason", content.Drain.Reason).Msg("Server is draining, reconnecting once it closes the stream")
+			drain = &drainError{
+				reason: content.Drain.Reason,
+				delay:  time.Duration(content.Drain.ReconnectDelayMs) * time.Millisecond,
 			}
-			log.Info().Msgf("Registration response: %v", content.Registration.Success)
+		case *pb.ServerMessage_Upgrade:
+			go a.handleUpgradeRequest(ctx, content.Upgrade)
+		case *pb.ServerMessage_Credit:
+			a.handleFlowCredit(content.Credit)
+		case *pb.ServerMessage_RequestBody:
+			a.handleRequestBody(content.RequestBody)
+		case *pb.ServerMessage_PortForward:
+			a.handlePortForward(content.PortForward)
 		case *pb.ServerMessage_Status:
 			log.Info().Msgf("Received server status: CPU: %v, Memory: %v", content.Status.CpuUsage, content.Status.MemoryUsage)
 		// Terminal stream
 		case *pb.ServerMessage_TerminalStream:
-			log.Info().Msgf("Received terminal request from server.")
-			go a.HandleTerminal(content.TerminalStream)
+			a.HandleTerminal(msgCtx, content.TerminalStream)
 		default:
 			log.Warn().Msgf("Unknown message type: %T", content)
 		}
@@ -1419,55 +1613,126 @@ func (a *Agent) Run(ctx context.Context) error {
 // 	log.Info().Msg("Shell exited")
 // }
 
-func (a *Agent) HandleTerminal(ts *tunnel.TerminalStream) error {
+// HandleTerminal is called from the receive loop so a session's input stays
+// in order, only the exec itself runs off of it.
+func (a *Agent) HandleTerminal(ctx context.Context, ts *tunnel.TerminalStream) error {
 
 	log.Debug().
 		Str("session", ts.SessionId).
+		Str("type", ts.Type.String()).
 		Int("bytes", len(ts.Data)).
 		Msg("terminal data received from UI")
 
-	// Try INIT JSON
-	var init terminalInit
-	if err := json.Unmarshal(ts.Data, &init); err == nil && init.Type == "init" {
-		log.Info().
-			Str("session", init.SessionId).
-			Str("ns", init.Namespace).
-			Str("pod", init.Pod).
-			Str("container", init.Container).
-			Msg("INIT received for exec session")
-
-		ctx := context.Background()
-
-		go func() {
-			if err := a.startK8sExec(
-				ctx,
-				init.Namespace,
-				init.Pod,
-				init.Container,
-				init.Command,
-				init.SessionId,
-			); err != nil {
-				log.Error().Err(err).Msg("k8s exec failed")
-			}
-		}()
+	var session *execSession
+	if v, ok := activeExecSessions.Load(ts.SessionId); ok {
+		session = v.(*execSession)
+	}
 
+	switch ts.Type {
+	case pb.TerminalMessageType_TERMINAL_RESIZE:
+		if session != nil {
+			session.sizes.push(ts.Size)
+		}
+		return nil
+	case pb.TerminalMessageType_TERMINAL_SIGNAL:
+		if session != nil {
+			session.signal(ts.Signal)
+		}
+		return nil
+	case pb.TerminalMessageType_TERMINAL_CLOSE:
+		if session != nil {
+			log.Info().Str("session", ts.SessionId).Msg("terminal closed by the server, ending session")
+			session.Close()
+		}
 		return nil
 	}
 
-	// Otherwise treat as stdin bytes
-	if stdinAny, ok := activeExecSessions.Load(ts.SessionId); ok {
-		stdin := stdinAny.(*io.PipeWriter)
-		_, _ = stdin.Write(ts.Data)
+	// Only a typed init starts an exec, stdin is never parsed for one: it
+	// carries what the user types
+	if ts.Type == pb.TerminalMessageType_TERMINAL_INIT {
+		if session != nil || ts.SessionId == "" {
+			log.Warn().Str("session", ts.SessionId).Msg("ignoring terminal init for an existing or unnamed session")
+			return nil
+		}
+		var init terminalInit
+		if err := json.Unmarshal(ts.Data, &init); err != nil {
+			log.Warn().Err(err).Str("session", ts.SessionId).Msg("invalid terminal init")
+			return nil
+		}
+		init.SessionId = ts.SessionId
+		a.startTerminal(ctx, init)
+		return nil
+	}
+
+	if session != nil {
+		session.write(ts.Data)
 	}
 
 	return nil
 }
 
-func (a *Agent) startK8sExec(ctx context.Context, namespace, pod, container string, cmd []string, streamID string) error {
+// startTerminal registers the session and runs its exec.
+func (a *Agent) startTerminal(ctx context.Context, init terminalInit) {
 	log.Info().
-		Str("streamID", streamID).
-		Msg("registering exec session")
+		Str("session", init.SessionId).
+		Str("ns", init.Namespace).
+		Str("pod", init.Pod).
+		Str("container", init.Container).
+		Strs("command", init.Command).
+		Msg("INIT received for exec session")
+
+	tty := init.Tty == nil || *init.Tty
+	ctx, cancel := context.WithCancel(ctx)
+	session := newExecSession(init.SessionId, tty, cancel)
+	session.sizes.push(&pb.TerminalSize{Cols: init.Cols, Rows: init.Rows})
+	if _, loaded := activeExecSessions.LoadOrStore(init.SessionId, session); loaded {
+		cancel()
+		return
+	}
 
+	spanName := "agent terminal exec"
+	if init.Debug != nil {
+		spanName = "agent terminal debug"
+	}
+	go func() {
+		defer func() {
+			activeExecSessions.CompareAndDelete(init.SessionId, session)
+			session.Close()
+		}()
+		ctx, end := a.startSpan(ctx, spanName, init.SessionId,
+			semconv.K8SNamespaceName(init.Namespace), semconv.K8SPodName(init.Pod), semconv.K8SContainerName(init.Container))
+		defer end()
+		var err error
+		if init.Debug != nil {
+			err = a.startK8sDebug(ctx, init, session)
+		} else {
+			err = a.startK8sExec(ctx, init.Namespace, init.Pod, init.Container, init.Command, session)
+		}
+		// A session the server closed ends with a cancelled context
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("k8s exec failed")
+			a.sendTerminalError(init.SessionId, err)
+		}
+	}()
+}
+
+// sendTerminalError tells the server why an exec session could not run or
+// broke off, it closes the session's WebSocket with it.
+func (a *Agent) sendTerminalError(sessionID string, err error) {
+	a.spanFailed(sessionID, err)
+	if sendErr := a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_TerminalStream{
+			TerminalStream: &pb.TerminalStream{
+				SessionId: sessionID,
+				Error:     tunnelError(err),
+			},
+		},
+	}); sendErr != nil {
+		log.Warn().Err(sendErr).Str("session", sessionID).Msg("failed to send exec error")
+	}
+}
+
+func (a *Agent) startK8sExec(ctx context.Context, namespace, pod, container string, cmd []string, session *execSession) error {
 	if len(cmd) == 0 {
 		cmd = []string{"/bin/sh"}
 	}
@@ -1494,17 +1759,24 @@ func (a *Agent) startK8sExec(ctx context.Context, namespace, pod, container stri
 			Stdin:     true,
 			Stdout:    true,
 			Stderr:    true,
-			TTY:       true,
+			TTY:       session.tty,
 		}, scheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	return a.streamTerminal(ctx, config, req.URL(), session)
+}
+
+// streamTerminal connects the session to an exec or attach request until
+// either side ends it.
+func (a *Agent) streamTerminal(ctx context.Context, config *rest.Config, target *url.URL, session *execSession) error {
+	streamID := session.id
+	executor, err := remotecommand.NewSPDYExecutor(config, "POST", target)
 	if err != nil {
 		return err
 	}
 
 	// UI → k8s
 	stdinReader, stdinWriter := io.Pipe()
-	activeExecSessions.Store(streamID, stdinWriter)
+	go session.pipeInput(stdinWriter)
 
 	// k8s → UI
 	stdoutReader, stdoutWriter := io.Pipe()
@@ -1532,16 +1804,19 @@ func (a *Agent) startK8sExec(ctx context.Context, namespace, pod, container stri
 		}
 	}()
 
-	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+	options := remotecommand.StreamOptions{
 		Stdin:  stdinReader,
 		Stdout: stdoutWriter,
 		Stderr: stdoutWriter,
-		Tty:    true,
-	})
+		Tty:    session.tty,
+	}
+	if session.tty {
+		options.TerminalSizeQueue = session.sizes
+	}
+	err = executor.StreamWithContext(ctx, options)
 
 	log.Info().Err(err).Msg("exec session ended")
 
-	activeExecSessions.Delete(streamID)
 	stdinWriter.Close()
 	stdoutWriter.Close()
 
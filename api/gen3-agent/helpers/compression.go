@@ -0,0 +1,82 @@
+package agentHelper
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// DefaultCompressionThreshold is the smallest DATA chunk worth compressing
+const DefaultCompressionThreshold = 1024
+
+var (
+	// Compression are the codecs offered to the server, preferred first
+	Compression          = pb.SupportedCompression
+	CompressionThreshold = DefaultCompressionThreshold
+)
+
+// streamCompression compresses the DATA of JSON responses with the codec the
+// server picked at registration.
+type streamCompression struct {
+	mu    sync.Mutex
+	codec string
+	// streams records whether a stream's HEADERS announced JSON
+	streams map[string]bool
+}
+
+func (s *streamCompression) setCodec(codec string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codec = codec
+	s.streams = make(map[string]bool)
+}
+
+// start decides from the response headers whether the stream is compressed.
+func (s *streamCompression) start(streamID, contentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.codec == "" {
+		return
+	}
+	s.streams[streamID] = pb.IsJSONContentType(contentType)
+}
+
+func (s *streamCompression) end(streamID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, streamID)
+}
+
+// encode returns the chunk to send and its encoding. Responses built by the
+// agent itself (helm, projects, ...) send no HEADERS and are JSON when they
+// look like it.
+func (s *streamCompression) encode(streamID string, body []byte) ([]byte, string) {
+	s.mu.Lock()
+	codec := s.codec
+	json, seen := s.streams[streamID]
+	s.mu.Unlock()
+
+	if codec == "" || len(body) < CompressionThreshold {
+		return body, ""
+	}
+	if !seen {
+		trimmed := bytes.TrimLeft(body, " \t\r\n")
+		json = len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	}
+	if !json {
+		return body, ""
+	}
+
+	compressed, err := pb.Compress(codec, body)
+	if err != nil {
+		log.Warn().Err(err).Str("stream_id", streamID).Msg("Failed to compress chunk, sending it as is")
+		return body, ""
+	}
+	if len(compressed) >= len(body) {
+		return body, ""
+	}
+	return compressed, codec
+}
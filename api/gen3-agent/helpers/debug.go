@@ -0,0 +1,237 @@
+package agentHelper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/uc-cdis/gen3-admin/internal/k8s"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// Debug modes of a terminal init
+const (
+	debugModeEphemeral = "ephemeral"
+	debugModeNode      = "node"
+)
+
+const (
+	// debugStartTimeout covers pulling the debug image
+	debugStartTimeout = 2 * time.Minute
+	// nodeDebugDeadline ends node debug pods the agent failed to delete,
+	// e.g. when it restarted mid session
+	nodeDebugDeadline int64 = 8 * 60 * 60
+	debugSessionLabel       = "gen3-admin/debug-session"
+)
+
+// terminalDebug asks for a debug container to attach to instead of an exec.
+// For ephemeral the container named by the init is added to its pod, for
+// node the init's pod is created on Node.
+type terminalDebug struct {
+	Mode            string `json:"mode"`
+	Image           string `json:"image"`
+	TargetContainer string `json:"targetContainer,omitempty"` // ephemeral only, shares its process namespace
+	Node            string `json:"node,omitempty"`            // node only
+}
+
+// startK8sDebug starts the debug container and attaches the session to it.
+// Node debug pods are deleted once the session ends. Ephemeral containers
+// cannot be removed from a pod, their shell exits when the session detaches.
+func (a *Agent) startK8sDebug(ctx context.Context, init terminalInit, session *execSession) error {
+	command := init.Command
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+
+	config, err := k8s.GetConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	a.sendTerminalOutput(session.id, fmt.Sprintf("Starting debug container %s (%s)...\r\n", init.Container, init.Debug.Image))
+	switch init.Debug.Mode {
+	case debugModeEphemeral:
+		err = addEphemeralContainer(ctx, clientset, init, command, session.tty)
+	case debugModeNode:
+		err = createNodeDebugPod(ctx, clientset, init, command, session.tty)
+		defer deleteNodeDebugPod(clientset, init.Namespace, init.Pod)
+	default:
+		return pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_INVALID, fmt.Sprintf("unknown debug mode %q", init.Debug.Mode))
+	}
+	if err != nil {
+		return err
+	}
+	a.sendTerminalOutput(session.id, "If you don't see a command prompt, try pressing enter.\r\n")
+
+	req := clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(init.Pod).
+		Namespace(init.Namespace).
+		SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: init.Container,
+			Stdin:     true,
+			Stdout:    true,
+			// A TTY merges stderr into stdout
+			Stderr: !session.tty,
+			TTY:    session.tty,
+		}, scheme.ParameterCodec)
+
+	return a.streamTerminal(ctx, config, req.URL(), session)
+}
+
+func addEphemeralContainer(ctx context.Context, clientset kubernetes.Interface, init terminalInit, command []string, tty bool) error {
+	pods := clientset.CoreV1().Pods(init.Namespace)
+	pod, err := pods.Get(ctx, init.Pod, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     init.Container,
+			Image:                    init.Debug.Image,
+			Command:                  command,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			Stdin:                    true,
+			// The shell gets EOF once the session detaches
+			StdinOnce: true,
+			TTY:       tty,
+		},
+		TargetContainerName: init.Debug.TargetContainer,
+	})
+	if _, err := pods.UpdateEphemeralContainers(ctx, init.Pod, pod, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Info().Str("ns", init.Namespace).Str("pod", init.Pod).Str("container", init.Container).Str("image", init.Debug.Image).Msg("added ephemeral debug container")
+
+	return waitForDebugContainer(ctx, clientset, init, func(pod *corev1.Pod) []corev1.ContainerStatus {
+		return pod.Status.EphemeralContainerStatuses
+	})
+}
+
+func createNodeDebugPod(ctx context.Context, clientset kubernetes.Interface, init terminalInit, command []string, tty bool) error {
+	privileged := true
+	deadline := nodeDebugDeadline
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      init.Pod,
+			Namespace: init.Namespace,
+			Labels: map[string]string{
+				debugSessionLabel:              init.SessionId,
+				"app.kubernetes.io/managed-by": "gen3-agent",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:              init.Debug.Node,
+			HostPID:               true,
+			HostNetwork:           true,
+			HostIPC:               true,
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			// Run on tainted nodes too, the node is the point
+			Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:            init.Container,
+				Image:           init.Debug.Image,
+				Command:         command,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Stdin:           true,
+				StdinOnce:       true,
+				TTY:             tty,
+				SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				VolumeMounts:    []corev1.VolumeMount{{Name: "host-root", MountPath: "/host"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "host-root",
+				VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}},
+			}},
+		},
+	}
+	if _, err := clientset.CoreV1().Pods(init.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	log.Info().Str("ns", init.Namespace).Str("pod", init.Pod).Str("node", init.Debug.Node).Str("image", init.Debug.Image).Msg("created node debug pod")
+
+	return waitForDebugContainer(ctx, clientset, init, func(pod *corev1.Pod) []corev1.ContainerStatus {
+		return pod.Status.ContainerStatuses
+	})
+}
+
+// waitForDebugContainer waits for the init's container to run and fails
+// early when it cannot, e.g. on a bad image.
+func waitForDebugContainer(ctx context.Context, clientset kubernetes.Interface, init terminalInit, statuses func(*corev1.Pod) []corev1.ContainerStatus) error {
+	return wait.PollUntilContextTimeout(ctx, time.Second, debugStartTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(init.Namespace).Get(ctx, init.Pod, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			return false, pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_CONFLICT,
+				fmt.Sprintf("pod %s/%s is %s", init.Namespace, init.Pod, pod.Status.Phase))
+		}
+		for _, status := range statuses(pod) {
+			if status.Name != init.Container {
+				continue
+			}
+			switch {
+			case status.State.Running != nil:
+				return true, nil
+			case status.State.Terminated != nil:
+				return false, pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_CONFLICT,
+					fmt.Sprintf("debug container %s exited: %s", init.Container, status.State.Terminated.Reason))
+			case status.State.Waiting != nil:
+				switch reason := status.State.Waiting.Reason; reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerError":
+					return false, pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_INVALID,
+						fmt.Sprintf("debug container %s cannot start: %s: %s", init.Container, reason, status.State.Waiting.Message))
+				}
+			}
+		}
+		return false, nil
+	})
+}
+
+// deleteNodeDebugPod runs once the session ended, its context is gone by then.
+func deleteNodeDebugPod(clientset kubernetes.Interface, namespace, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	grace := int64(0)
+	err := clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	// Not found when creating it failed
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("ns", namespace).Str("pod", name).Msg("failed to delete node debug pod")
+		return
+	}
+	log.Info().Str("ns", namespace).Str("pod", name).Msg("deleted node debug pod")
+}
+
+// sendTerminalOutput writes a status line to the session's terminal.
+func (a *Agent) sendTerminalOutput(sessionID, text string) {
+	if err := a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_TerminalStream{
+			TerminalStream: &pb.TerminalStream{
+				SessionId: sessionID,
+				Data:      []byte(text),
+			},
+		},
+	}); err != nil {
+		log.Warn().Err(err).Str("session", sessionID).Msg("failed to send terminal output")
+	}
+}
@@ -0,0 +1,84 @@
+package agentHelper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// enroll exchanges the join token for a client certificate. The key is
+// generated here and never sent to the server, only the CSR is.
+func enroll(name, serverAddress string, certPool *x509.CertPool) (*tls.Certificate, error) {
+	tokenBytes, err := os.ReadFile(JoinTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading join token: %v", err)
+	}
+	token := strings.TrimSpace(string(tokenBytes))
+
+	key, csrPEM, err := newCSR(name)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling key: %v", err)
+	}
+
+	// No client certificate yet, the server only allows Enroll without one
+	creds := credentials.NewTLS(&tls.Config{
+		ServerName: "csoc.gen3.org",
+		RootCAs:    certPool,
+	})
+	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	client := pb.NewTunnelServiceClient(conn)
+
+	var resp *pb.EnrollResponse
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		resp, err = client.Enroll(ctx, &pb.EnrollRequest{
+			AgentName: name,
+			JoinToken: token,
+			Csr:       csrPEM,
+		})
+		cancel()
+		if err == nil {
+			break
+		}
+		if status.Code(err) == codes.Unavailable {
+			log.Warn().Err(err).Msg("Server unavailable, retrying enrollment in 5 seconds...")
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(resp.Certificate, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("enrolled certificate does not match our key: %v", err)
+	}
+
+	if err := persistCertificate(resp.Certificate, keyPEM, resp.CaCertificate); err != nil {
+		// The token is spent, without persisting the agent can't enroll again after a restart
+		log.Error().Err(err).Msg("Error persisting enrolled certificate")
+	}
+
+	log.Info().Msg("Agent enrolled with join token")
+	return &cert, nil
+}
@@ -0,0 +1,26 @@
+package agentHelper
+
+import (
+	"errors"
+
+	"github.com/uc-cdis/gen3-admin/internal/helm"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// tunnelError classifies err for the server, helm's failures included.
+func tunnelError(err error) *pb.TunnelError {
+	var code pb.TunnelErrorCode
+	switch {
+	case errors.Is(err, helm.ErrReleaseNotFound):
+		code = pb.TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND
+	case errors.Is(err, helm.ErrReleaseExists):
+		code = pb.TunnelErrorCode_TUNNEL_ERROR_CONFLICT
+	case errors.Is(err, helm.ErrForbidden):
+		code = pb.TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN
+	default:
+		return pb.TunnelErrorFor(err)
+	}
+	te := pb.NewTunnelError(code, err.Error())
+	te.Details = map[string]string{"kind": "release"}
+	return te
+}
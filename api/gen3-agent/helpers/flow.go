@@ -0,0 +1,116 @@
+package agentHelper
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+var errFlowClosed = errors.New("stream closed while waiting for flow credit")
+
+// flowWindow holds the DATA chunks we may still send on a proxy stream. The
+// server grants more as it writes them to its client.
+type flowWindow struct {
+	mu      sync.Mutex
+	credits int
+	granted chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newFlowWindow(credits int) *flowWindow {
+	return &flowWindow{
+		credits: credits,
+		granted: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// acquire takes one credit, blocking until the server grants some or the stream closes.
+func (w *flowWindow) acquire() error {
+	for {
+		w.mu.Lock()
+		if w.credits > 0 {
+			w.credits--
+			w.mu.Unlock()
+			return nil
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.granted:
+		case <-w.done:
+			return errFlowClosed
+		}
+	}
+}
+
+func (w *flowWindow) grant(n int) {
+	w.mu.Lock()
+	w.credits += n
+	w.mu.Unlock()
+	select {
+	case w.granted <- struct{}{}:
+	default:
+	}
+}
+
+func (w *flowWindow) close() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// openFlow starts flow control for a stream when the server asked for it.
+func (a *Agent) openFlow(streamID string, window int32) {
+	if window <= 0 {
+		return
+	}
+	a.flowsMu.Lock()
+	a.flows[streamID] = newFlowWindow(int(window))
+	a.flowsMu.Unlock()
+}
+
+func (a *Agent) closeFlow(streamID string) {
+	a.flowsMu.Lock()
+	w, ok := a.flows[streamID]
+	delete(a.flows, streamID)
+	a.flowsMu.Unlock()
+	if ok {
+		w.close()
+	}
+}
+
+func (a *Agent) closeAllFlows() {
+	a.flowsMu.Lock()
+	flows := a.flows
+	a.flows = make(map[string]*flowWindow)
+	a.flowsMu.Unlock()
+	for _, w := range flows {
+		w.close()
+	}
+}
+
+func (a *Agent) handleFlowCredit(credit *pb.FlowCredit) {
+	a.flowsMu.Lock()
+	w, ok := a.flows[credit.StreamId]
+	a.flowsMu.Unlock()
+	if !ok {
+		log.Trace().Str("stream_id", credit.StreamId).Msg("Credit for a finished stream")
+		return
+	}
+	w.grant(int(credit.Credits))
+}
+
+// waitForCredit blocks a DATA chunk until the stream has credit. Streams
+// without flow control never wait.
+func (a *Agent) waitForCredit(streamID string) error {
+	a.flowsMu.Lock()
+	w, ok := a.flows[streamID]
+	a.flowsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return w.acquire()
+}
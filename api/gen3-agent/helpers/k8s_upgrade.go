@@ -0,0 +1,142 @@
+package agentHelper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"k8s.io/client-go/rest"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// upgradeSkipHeaders are client headers not forwarded to the k8s API, they
+// belong to the hop between the client and the CSOC API.
+var upgradeSkipHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Content-Length":      true,
+	"Host":                true,
+	"X-Forwarded-For":     true,
+	"X-Forwarded-Host":    true,
+	"X-Forwarded-Proto":   true,
+	"X-Gen3-Forwarded-By": true,
+}
+
+// upgradeTransport talks HTTP/1.1 to the k8s API, protocol upgrades don't
+// exist in HTTP/2. Auth from the REST config is layered on top.
+func upgradeTransport(restConfig *rest.Config) (http.RoundTripper, error) {
+	tlsConfig, err := rest.TLSConfigFor(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build TLS config: %v", err)
+	}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+	base := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+	}
+	return rest.HTTPWrappersForConfig(restConfig, base)
+}
+
+// proxyK8sUpgrade forwards a request that switches protocols (exec, attach,
+// port-forward) and relays the raw streams both ways once the k8s API agreed.
+func (a *Agent) proxyK8sUpgrade(ctx context.Context, req *pb.ProxyRequest, restConfig *rest.Config, url string) {
+	streamID := req.StreamId
+
+	transport, err := upgradeTransport(restConfig)
+	if err != nil {
+		a.sendErrorResponse(streamID, err)
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, url, nil)
+	if err != nil {
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to create request: %w", err))
+		return
+	}
+	for k, v := range req.Headers {
+		if upgradeSkipHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		// The tunnel joins repeated headers, SPDY needs each protocol version on its own
+		if http.CanonicalHeaderKey(k) == "X-Stream-Protocol-Version" {
+			for _, version := range strings.Split(v, ",") {
+				httpReq.Header.Add(k, strings.TrimSpace(version))
+			}
+			continue
+		}
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := transport.RoundTrip(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Str("stream_id", streamID).Msg("[k8s-proxy] Upgrade request to k8s API failed")
+		a.sendErrorResponse(streamID, fmt.Errorf("failed to execute request: %w", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if err := a.sendProxyResponse(streamID, pb.ProxyResponseType_HEADERS, int32(resp.StatusCode), resp.Header, nil); err != nil {
+		return
+	}
+
+	// Refused, e.g. 403 or 400: a plain response the server relays as usual
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		a.relayK8sStream(ctx, streamID, resp.Body)
+		return
+	}
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		a.sendErrorResponse(streamID, fmt.Errorf("k8s API switched protocols without a writable stream"))
+		return
+	}
+	log.Info().Str("stream_id", streamID).Str("protocol", resp.Header.Get("Upgrade")).Msg("[k8s-proxy] Switched protocols, relaying stream")
+
+	// Cancelling the request, or the session, tears the stream down
+	go func() {
+		<-ctx.Done()
+		rwc.Close()
+	}()
+	go func() {
+		if _, err := io.Copy(rwc, a.requestBodyReader(req)); err != nil {
+			log.Debug().Err(err).Str("stream_id", streamID).Msg("[k8s-proxy] Client side of upgraded stream ended")
+		}
+	}()
+
+	a.relayK8sStream(ctx, streamID, rwc)
+}
+
+// relayK8sStream sends r as DATA chunks followed by END.
+func (a *Agent) relayK8sStream(ctx context.Context, streamID string, r io.Reader) {
+	buffer := make([]byte, 16384)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			if err := a.sendProxyResponse(streamID, pb.ProxyResponseType_DATA, 0, nil, buffer[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if err != io.EOF {
+				log.Debug().Err(err).Str("stream_id", streamID).Msg("[k8s-proxy] Stream read ended")
+			}
+			a.sendProxyResponse(streamID, pb.ProxyResponseType_END, 0, nil, nil)
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package agentHelper
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMetricsAddress serves the agent's /metrics, empty disables it
+const DefaultMetricsAddress = ":9090"
+
+var MetricsAddress = DefaultMetricsAddress
+
+var (
+	messagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_agent_messages_received_total",
+		Help: "Messages received from the server, by type.",
+	}, []string{"type"})
+
+	messagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_agent_messages_sent_total",
+		Help: "Messages sent to the server, by type.",
+	}, []string{"type"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gen3_agent_handler_duration_seconds",
+		Help:    "Time the agent spent handling a request from the server.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"operation"})
+
+	requestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gen3_agent_handlers_in_flight",
+		Help: "Requests from the server the agent is handling, terminal sessions included.",
+	}, []string{"operation"})
+
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_agent_handler_errors_total",
+		Help: "Errors the agent answered requests with, by tunnel error code.",
+	}, []string{"operation", "code"})
+
+	tunnelConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gen3_agent_tunnel_connected",
+		Help: "Whether the agent has a registered tunnel to the server.",
+	})
+
+	tunnelReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gen3_agent_tunnel_reconnects_total",
+		Help: "Sessions with the server that ended and were reopened.",
+	})
+)
+
+// messageType names the message a ServerMessage or AgentMessage carries,
+// e.g. "proxy".
+func messageType(msg proto.Message) string {
+	m := msg.ProtoReflect()
+	oneof := m.Descriptor().Oneofs().ByName("message")
+	if oneof == nil {
+		return "unknown"
+	}
+	field := m.WhichOneof(oneof)
+	if field == nil {
+		return "unknown"
+	}
+	return string(field.Name())
+}
+
+// ServeMetrics serves /metrics on addr until the process exits.
+func ServeMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Info().Str("address", addr).Msg("Serving metrics")
+	if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error().Err(err).Msg("Metrics listener stopped")
+	}
+}
@@ -0,0 +1,210 @@
+package agentHelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/uc-cdis/gen3-admin/internal/k8s"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+const (
+	portForwardDialTimeout = 10 * time.Second
+	portForwardChunkSize   = 32 * 1024
+)
+
+// handlePortForward is called from the receive loop, only OPEN does work
+// off of it.
+func (a *Agent) handlePortForward(msg *pb.PortForward) {
+	switch msg.Type {
+	case pb.PortForwardType_PORT_FORWARD_OPEN:
+		// Register before dialing so nothing sent meanwhile is lost
+		a.openFlow(msg.StreamId, msg.Window)
+		body := a.openRequestBody(msg.StreamId, msg.Window)
+		go a.runPortForward(msg, body)
+	case pb.PortForwardType_PORT_FORWARD_DATA:
+		a.handleRequestBody(&pb.RequestBody{StreamId: msg.StreamId, Data: msg.Data})
+	case pb.PortForwardType_PORT_FORWARD_CLOSE:
+		// Whatever was sent before still reaches the target
+		a.handleRequestBody(&pb.RequestBody{StreamId: msg.StreamId, End: true})
+	case pb.PortForwardType_PORT_FORWARD_ERROR:
+		log.Info().Str("stream_id", msg.StreamId).Str("reason", msg.Error).Msg("[port-forward] Server dropped stream")
+		a.dropPortForward(msg.StreamId)
+	}
+}
+
+func (a *Agent) runPortForward(msg *pb.PortForward, body *requestBody) {
+	streamID := msg.StreamId
+	logger := log.With().
+		Str("stream_id", streamID).
+		Str("target", fmt.Sprintf("%s/%s/%s", msg.Kind, msg.Namespace, msg.Name)).
+		Int32("port", msg.Port).
+		Logger()
+
+	ctx := a.sessionContext()
+	conn, addr, err := dialPortForward(ctx, msg)
+	if err != nil {
+		logger.Warn().Err(err).Msg("[port-forward] Failed to connect to target")
+		a.closeFlow(streamID)
+		a.closeRequestBody(streamID)
+		a.sendPortForward(&pb.PortForward{StreamId: streamID, Type: pb.PortForwardType_PORT_FORWARD_ERROR, Error: err.Error()})
+		return
+	}
+
+	// The server may have given up while we were dialing
+	a.bodiesMu.Lock()
+	_, open := a.bodies[streamID]
+	a.bodiesMu.Unlock()
+	if !open {
+		conn.Close()
+		return
+	}
+	a.forwardsMu.Lock()
+	a.forwards[streamID] = conn
+	a.forwardsMu.Unlock()
+
+	logger.Info().Str("address", addr).Msg("[port-forward] Connected to target")
+	if err := a.sendPortForward(&pb.PortForward{StreamId: streamID, Type: pb.PortForwardType_PORT_FORWARD_OPEN}); err != nil {
+		a.dropPortForward(streamID)
+		return
+	}
+
+	// Server to target, ends when the server closes its side
+	go func() {
+		if _, err := io.Copy(conn, body); err != nil && !errors.Is(err, errBodyClosed) {
+			logger.Debug().Err(err).Msg("[port-forward] Write to target failed")
+		}
+		a.dropPortForward(streamID)
+	}()
+
+	// Target to server
+	buf := make([]byte, portForwardChunkSize)
+	for {
+		n, readErr := conn.Read(buf)
+		if n > 0 {
+			if err := a.waitForCredit(streamID); err != nil {
+				return
+			}
+			if err := a.sendPortForward(&pb.PortForward{
+				StreamId: streamID,
+				Type:     pb.PortForwardType_PORT_FORWARD_DATA,
+				Data:     slices.Clone(buf[:n]),
+			}); err != nil {
+				a.dropPortForward(streamID)
+				return
+			}
+		}
+		if readErr != nil {
+			// A stream we dropped ourselves was already ended on the server
+			if !a.dropPortForward(streamID) {
+				return
+			}
+			end := &pb.PortForward{StreamId: streamID, Type: pb.PortForwardType_PORT_FORWARD_CLOSE}
+			if !errors.Is(readErr, io.EOF) {
+				end.Type = pb.PortForwardType_PORT_FORWARD_ERROR
+				end.Error = readErr.Error()
+			}
+			logger.Info().Err(readErr).Msg("[port-forward] Target connection ended")
+			a.sendPortForward(end)
+			return
+		}
+	}
+}
+
+func dialPortForward(ctx context.Context, msg *pb.PortForward) (net.Conn, string, error) {
+	addr, err := portForwardAddress(ctx, msg)
+	if err != nil {
+		return nil, "", err
+	}
+	dialer := &net.Dialer{Timeout: portForwardDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	return conn, addr, nil
+}
+
+// portForwardAddress resolves the pod IP or service cluster IP to dial.
+func portForwardAddress(ctx context.Context, msg *pb.PortForward) (string, error) {
+	if msg.Port < 1 || msg.Port > 65535 {
+		return "", fmt.Errorf("invalid port %d", msg.Port)
+	}
+	port := strconv.Itoa(int(msg.Port))
+
+	config, err := k8s.GetConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get k8s config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to create clientset: %v", err)
+	}
+
+	switch msg.Kind {
+	case "pod":
+		pod, err := clientset.CoreV1().Pods(msg.Namespace).Get(ctx, msg.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get pod: %v", err)
+		}
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			return "", fmt.Errorf("pod %s/%s is not running", msg.Namespace, msg.Name)
+		}
+		return net.JoinHostPort(pod.Status.PodIP, port), nil
+	case "service":
+		svc, err := clientset.CoreV1().Services(msg.Namespace).Get(ctx, msg.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get service: %v", err)
+		}
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			// Headless, let cluster DNS pick a backend
+			return net.JoinHostPort(fmt.Sprintf("%s.%s.svc", msg.Name, msg.Namespace), port), nil
+		}
+		return net.JoinHostPort(svc.Spec.ClusterIP, port), nil
+	}
+	return "", fmt.Errorf("unsupported kind %q", msg.Kind)
+}
+
+func (a *Agent) sendPortForward(msg *pb.PortForward) error {
+	return a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_PortForward{
+			PortForward: msg,
+		},
+	})
+}
+
+// dropPortForward closes the target connection and stream state. It reports
+// whether the stream was still open.
+func (a *Agent) dropPortForward(streamID string) bool {
+	a.forwardsMu.Lock()
+	conn, ok := a.forwards[streamID]
+	delete(a.forwards, streamID)
+	a.forwardsMu.Unlock()
+
+	a.closeFlow(streamID)
+	a.closeRequestBody(streamID)
+	if ok {
+		conn.Close()
+	}
+	return ok
+}
+
+func (a *Agent) closeAllPortForwards() {
+	a.forwardsMu.Lock()
+	forwards := a.forwards
+	a.forwards = make(map[string]net.Conn)
+	a.forwardsMu.Unlock()
+	for _, conn := range forwards {
+		conn.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package agentHelper
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultReconnectMinBackoff = 1 * time.Second
+	DefaultReconnectMaxBackoff = 2 * time.Minute
+	// A session that stayed up this long resets the backoff
+	stableSessionAfter = 1 * time.Minute
+	// replacedBackoff leaves another instance of the agent that took over
+	// the connection, e.g. the new pod of a rollout, time to finish
+	replacedBackoff = 1 * time.Minute
+)
+
+var (
+	ReconnectMinBackoff = DefaultReconnectMinBackoff
+	ReconnectMaxBackoff = DefaultReconnectMaxBackoff
+
+	errNotConnected = errors.New("not connected to the server")
+	// errReplaced is returned by Run when the server closed the session
+	// because another connection registered with our name
+	errReplaced = errors.New("connection replaced by another agent with the same name")
+)
+
+// drainError is returned by Run when the server asked us to reconnect
+// because it is shutting down.
+type drainError struct {
+	reason string
+	delay  time.Duration
+}
+
+func (e *drainError) Error() string {
+	return "server is draining: " + e.reason
+}
+
+// backoff is an exponential backoff with jitter, each delay is picked in
+// [d/2, d) so agents losing the server together don't reconnect together.
+type backoff struct {
+	min, max time.Duration
+	next     time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, next: min}
+}
+
+func (b *backoff) Next() time.Duration {
+	d := b.next
+	b.next *= 2
+	if b.next > b.max {
+		b.next = b.max
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
+
+func (b *backoff) Reset() {
+	b.next = b.min
+}
+
+// Supervise keeps a session with the server open until ctx is done. A broken
+// stream ends the session, cleans up everything it started and a new one is
+// opened after a jittered exponential backoff, without the process exiting.
+func (a *Agent) Supervise(ctx context.Context) error {
+	go a.watchCertificateExpiry(ctx)
+
+	b := newBackoff(ReconnectMinBackoff, ReconnectMaxBackoff)
+	for {
+		started := time.Now()
+		err := a.runSession(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if time.Since(started) > stableSessionAfter {
+			b.Reset()
+		}
+		delay := b.Next()
+		if errors.Is(err, errReplaced) && delay < replacedBackoff {
+			delay = replacedBackoff
+		}
+		var drain *drainError
+		if errors.As(err, &drain) {
+			// Not a failure, another replica is ready to take the stream
+			b.Reset()
+			delay = 0
+			if drain.delay > 0 {
+				delay = time.Duration(rand.Int63n(int64(drain.delay)))
+			}
+		}
+
+		reconnects := a.reconnects.Add(1)
+		tunnelReconnects.Inc()
+		log.Warn().Err(err).Dur("retryIn", delay).Int64("reconnects", reconnects).Msg("Session with the server ended, reconnecting")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runSession connects, registers and serves the stream until it breaks. Proxy
+// requests and terminal sessions run under the session context, so returning
+// cancels them instead of leaving them writing to a dead stream.
+func (a *Agent) runSession(ctx context.Context) error {
+	sessionCtx, cancel := context.WithCancel(ctx)
+	a.sessionMu.Lock()
+	a.session = sessionCtx
+	a.sessionMu.Unlock()
+	defer a.endSession(cancel)
+
+	if err := a.Connect(sessionCtx); err != nil {
+		return err
+	}
+	tunnelConnected.Set(1)
+	log.Info().Int64("reconnects", a.reconnects.Load()).Msg("Agent connected and running")
+	return a.Run(sessionCtx)
+}
+
+func (a *Agent) endSession(cancel context.CancelFunc) {
+	cancel()
+	tunnelConnected.Set(0)
+
+	a.sendMu.Lock()
+	a.stream = nil
+	a.sendMu.Unlock()
+
+	a.proxyCancelMu.Lock()
+	for streamID, cancelFn := range a.proxyCancelFuncs {
+		cancelFn()
+		delete(a.proxyCancelFuncs, streamID)
+	}
+	a.proxyCancelMu.Unlock()
+	a.closeAllFlows()
+	a.closeAllRequestBodies()
+	a.closeAllPortForwards()
+	// The next registration negotiates again
+	a.compression.setCodec("")
+
+	// Exec sessions stop with the session context, closing stdin unblocks them
+	activeExecSessions.Range(func(key, value any) bool {
+		if w, ok := value.(interface{ Close() error }); ok {
+			w.Close()
+		}
+		activeExecSessions.Delete(key)
+		return true
+	})
+}
+
+// sessionContext is the context of the current session, work started on
+// behalf of the server should stop with it.
+func (a *Agent) sessionContext() context.Context {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+	if a.session == nil {
+		return context.Background()
+	}
+	return a.session
+}
@@ -0,0 +1,222 @@
+package agentHelper
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+const (
+	certCheckInterval = 1 * time.Hour
+	namespaceFile     = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+func (a *Agent) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	a.certMu.RLock()
+	defer a.certMu.RUnlock()
+	return a.cert, nil
+}
+
+func (a *Agent) certificateExpiry() (time.Time, error) {
+	a.certMu.RLock()
+	defer a.certMu.RUnlock()
+	if a.cert.Leaf != nil {
+		return a.cert.Leaf.NotAfter, nil
+	}
+	leaf, err := x509.ParseCertificate(a.cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing client certificate: %v", err)
+	}
+	return leaf.NotAfter, nil
+}
+
+// watchCertificateExpiry asks the server for a new certificate once the
+// current one is within RenewBefore of expiring.
+func (a *Agent) watchCertificateExpiry(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		expiry, err := a.certificateExpiry()
+		if err != nil {
+			log.Error().Err(err).Msg("Error checking certificate expiry")
+		} else if time.Until(expiry) < RenewBefore {
+			log.Info().Time("expires", expiry).Msg("Client certificate is close to expiry, requesting renewal")
+			if err := a.requestCertificateRenewal(); err != nil {
+				log.Error().Err(err).Msg("Error requesting certificate renewal")
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newCSR generates a fresh key and a PEM encoded CSR for the agent name.
+func newCSR(agentName string) (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating key: %v", err)
+	}
+
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: agentName},
+	}, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating certificate signing request: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes}), nil
+}
+
+func (a *Agent) requestCertificateRenewal() error {
+	key, csrPEM, err := newCSR(a.Name)
+	if err != nil {
+		return err
+	}
+
+	a.certMu.Lock()
+	a.renewalKey = key
+	a.certMu.Unlock()
+
+	return a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_CertificateRenewal{
+			CertificateRenewal: &pb.CertificateRenewalRequest{
+				Csr: csrPEM,
+			},
+		},
+	})
+}
+
+func (a *Agent) handleCertificateRenewal(resp *pb.CertificateRenewalResponse) {
+	if !resp.Success {
+		log.Error().Msgf("Server rejected certificate renewal: %s", resp.Message)
+		return
+	}
+
+	a.certMu.Lock()
+	key := a.renewalKey
+	a.renewalKey = nil
+	a.certMu.Unlock()
+
+	if key == nil {
+		log.Warn().Msg("Received certificate renewal response without a pending request")
+		return
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		log.Error().Err(err).Msg("Error marshaling renewed key")
+		return
+	}
+
+	// X509KeyPair also checks the certificate matches the key we generated
+	cert, err := tls.X509KeyPair(resp.Certificate, keyPEM)
+	if err != nil {
+		log.Error().Err(err).Msg("Renewed certificate does not match the requested key")
+		return
+	}
+
+	if err := persistCertificate(resp.Certificate, keyPEM, resp.CaCertificate); err != nil {
+		// Still swap in memory, the current process keeps working until it restarts
+		log.Error().Err(err).Msg("Error persisting renewed certificate")
+	}
+
+	a.certMu.Lock()
+	a.cert = &cert
+	a.certMu.Unlock()
+
+	expiry, _ := a.certificateExpiry()
+	log.Info().Time("expires", expiry).Msg("Client certificate renewed, it will be used on the next reconnect")
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), nil
+}
+
+// persistCertificate stores the agent credentials in the agent's Secret when
+// running in a cluster, or next to the current cert files otherwise.
+func persistCertificate(certPEM, keyPEM, caPEM []byte) error {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		if err := os.WriteFile(AgentCertFile, certPEM, 0644); err != nil {
+			return fmt.Errorf("error writing certificate: %v", err)
+		}
+		if err := os.WriteFile(AgentKeyFile, keyPEM, 0600); err != nil {
+			return fmt.Errorf("error writing key: %v", err)
+		}
+		os.Remove(JoinTokenFile)
+		return nil
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+
+	namespace, err := podNamespace()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, CertSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting secret %s/%s: %v", namespace, CertSecretName, err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[filepath.Base(AgentCertFile)] = certPEM
+	secret.Data[filepath.Base(AgentKeyFile)] = keyPEM
+	if len(caPEM) > 0 {
+		secret.Data["ca.crt"] = caPEM
+	}
+	// The join token is single use, drop it once we hold a certificate
+	delete(secret.Data, filepath.Base(JoinTokenFile))
+
+	if _, err := clientset.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating secret %s/%s: %v", namespace, CertSecretName, err)
+	}
+
+	log.Info().Str("namespace", namespace).Str("secret", CertSecretName).Msg("Persisted agent certificate to secret")
+	return nil
+}
+
+// podNamespace returns the namespace the agent pod runs in.
+func podNamespace() (string, error) {
+	nsBytes, err := os.ReadFile(namespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading pod namespace: %v", err)
+	}
+	namespace := strings.TrimSpace(string(nsBytes))
+	if namespace == "" {
+		return "", errors.New("pod namespace is empty")
+	}
+	return namespace, nil
+}
@@ -0,0 +1,171 @@
+package agentHelper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// defaultBodyWindow is used when the server streams a body without a window
+const defaultBodyWindow = 64
+
+var errBodyClosed = errors.New("request body closed before it was fully received")
+
+// requestBody is the body of a proxied request the server streams in
+// RequestBody frames, or the bytes of a port-forward headed to its target.
+// Read hands credit back to the server as chunks are consumed, so the server
+// never sends more than the chunk buffer holds.
+type requestBody struct {
+	agent    *Agent
+	streamID string
+	window   int
+	consumed int
+
+	chunks chan *pb.RequestBody
+	closed chan struct{}
+	once   sync.Once
+
+	buf []byte
+	err error
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		select {
+		case chunk := <-b.chunks:
+			b.buf = chunk.Data
+			switch {
+			case chunk.Error != "":
+				b.err = errors.New("client upload failed: " + chunk.Error)
+			case chunk.End:
+				b.err = io.EOF
+			default:
+				b.credit()
+			}
+		case <-b.closed:
+			return 0, errBodyClosed
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// credit grants the server more chunks every half window.
+func (b *requestBody) credit() {
+	b.consumed++
+	if b.consumed < (b.window+1)/2 {
+		return
+	}
+	err := b.agent.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_Credit{
+			Credit: &pb.FlowCredit{
+				StreamId: b.streamID,
+				Credits:  int32(b.consumed),
+			},
+		},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("stream_id", b.streamID).Msg("Failed to send request body credit")
+		return
+	}
+	b.consumed = 0
+}
+
+func (b *requestBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+// openRequestBody registers the body of a streamed request before its
+// handler starts, frames that arrive meanwhile are buffered.
+func (a *Agent) openRequestBody(streamID string, window32 int32) *requestBody {
+	window := int(window32)
+	if window <= 0 {
+		window = defaultBodyWindow
+	}
+	body := &requestBody{
+		agent:    a,
+		streamID: streamID,
+		window:   window,
+		// The END frame doesn't take credit
+		chunks: make(chan *pb.RequestBody, window+1),
+		closed: make(chan struct{}),
+	}
+	a.bodiesMu.Lock()
+	a.bodies[streamID] = body
+	a.bodiesMu.Unlock()
+	return body
+}
+
+// requestBodyReader is the body to forward for req, streamed or inline.
+func (a *Agent) requestBodyReader(req *pb.ProxyRequest) io.Reader {
+	if !req.BodyStreamed {
+		return bytes.NewReader(req.Body)
+	}
+	a.bodiesMu.Lock()
+	body, ok := a.bodies[req.StreamId]
+	a.bodiesMu.Unlock()
+	if !ok {
+		return bytes.NewReader(nil)
+	}
+	return body
+}
+
+func (a *Agent) handleRequestBody(chunk *pb.RequestBody) {
+	a.bodiesMu.Lock()
+	body, ok := a.bodies[chunk.StreamId]
+	a.bodiesMu.Unlock()
+	if !ok {
+		log.Trace().Str("stream_id", chunk.StreamId).Msg("Request body chunk for a finished stream")
+		return
+	}
+	select {
+	case body.chunks <- chunk:
+	default:
+		// The server sent past its credit, fail the request rather than block the stream
+		log.Error().Str("stream_id", chunk.StreamId).Msg("Request body chunk over the window, aborting request")
+		a.closeRequestBody(chunk.StreamId)
+	}
+}
+
+func (a *Agent) closeRequestBody(streamID string) {
+	a.bodiesMu.Lock()
+	body, ok := a.bodies[streamID]
+	delete(a.bodies, streamID)
+	a.bodiesMu.Unlock()
+	if ok {
+		body.Close()
+	}
+}
+
+func (a *Agent) closeAllRequestBodies() {
+	a.bodiesMu.Lock()
+	bodies := a.bodies
+	a.bodies = make(map[string]*requestBody)
+	a.bodiesMu.Unlock()
+	for _, body := range bodies {
+		body.Close()
+	}
+}
+
+// setContentLength keeps the client's Content-Length on a streamed body, which
+// net/http would otherwise send chunked.
+func setContentLength(httpReq *http.Request, req *pb.ProxyRequest) {
+	if !req.BodyStreamed {
+		return
+	}
+	if n, err := strconv.ParseInt(req.Headers["Content-Length"], 10, 64); err == nil && n >= 0 {
+		httpReq.ContentLength = n
+	}
+}
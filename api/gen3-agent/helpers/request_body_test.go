@@ -0,0 +1,133 @@
+package agentHelper
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// recordingStream keeps what the agent sends instead of sending it.
+type recordingStream struct {
+	pb.TunnelService_ConnectClient
+	mu   sync.Mutex
+	sent []*pb.AgentMessage
+}
+
+func (s *recordingStream) Send(msg *pb.AgentMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// credits is every FlowCredit sent for streamID, in order.
+func (s *recordingStream) credits(streamID string) []int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var credits []int32
+	for _, msg := range s.sent {
+		if credit := msg.GetCredit(); credit != nil && credit.StreamId == streamID {
+			credits = append(credits, credit.Credits)
+		}
+	}
+	return credits
+}
+
+func newTestAgent() (*Agent, *recordingStream) {
+	stream := &recordingStream{}
+	return &Agent{stream: stream, bodies: make(map[string]*requestBody)}, stream
+}
+
+func TestRequestBodyCredit(t *testing.T) {
+	tests := []struct {
+		name        string
+		window      int32
+		chunks      int
+		wantCredits []int32
+	}{
+		{"credit every half window", 4, 4, []int32{2, 2}},
+		{"odd window rounds up", 5, 5, []int32{3}},
+		{"window of one", 1, 3, []int32{1, 1, 1}},
+		{"under half a window", 4, 1, nil},
+		{"default window", 0, 32, []int32{32}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, stream := newTestAgent()
+			body := agent.openRequestBody("s1", tt.window)
+
+			var got bytes.Buffer
+			buf := make([]byte, 16)
+			for i := range tt.chunks {
+				agent.handleRequestBody(&pb.RequestBody{StreamId: "s1", Data: []byte{byte(i)}})
+				n, err := body.Read(buf)
+				if err != nil {
+					t.Fatalf("Read chunk %d: %v", i, err)
+				}
+				got.Write(buf[:n])
+			}
+			// The END frame doesn't take credit
+			agent.handleRequestBody(&pb.RequestBody{StreamId: "s1", End: true})
+			rest, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			got.Write(rest)
+
+			if got.Len() != tt.chunks {
+				t.Errorf("read %d bytes, want %d", got.Len(), tt.chunks)
+			}
+			if credits := stream.credits("s1"); !slices.Equal(credits, tt.wantCredits) {
+				t.Errorf("credits = %v, want %v", credits, tt.wantCredits)
+			}
+		})
+	}
+}
+
+func TestRequestBodyOverWindow(t *testing.T) {
+	agent, stream := newTestAgent()
+	body := agent.openRequestBody("s1", 2)
+
+	// The window plus the END frame fit, anything past it aborts the body
+	for i := range 4 {
+		agent.handleRequestBody(&pb.RequestBody{StreamId: "s1", Data: []byte{byte(i)}})
+	}
+
+	agent.bodiesMu.Lock()
+	_, open := agent.bodies["s1"]
+	agent.bodiesMu.Unlock()
+	if open {
+		t.Error("body still registered after a chunk over the window")
+	}
+	select {
+	case <-body.closed:
+	default:
+		t.Error("body not closed after a chunk over the window")
+	}
+	if credits := stream.credits("s1"); len(credits) != 0 {
+		t.Errorf("credits = %v, want none", credits)
+	}
+}
+
+func TestRequestBodyUploadError(t *testing.T) {
+	agent, stream := newTestAgent()
+	body := agent.openRequestBody("s1", 2)
+
+	agent.handleRequestBody(&pb.RequestBody{StreamId: "s1", Data: []byte("partial")})
+	agent.handleRequestBody(&pb.RequestBody{StreamId: "s1", End: true, Error: "connection reset"})
+
+	got, err := io.ReadAll(body)
+	if err == nil {
+		t.Fatal("expected the upload error")
+	}
+	if string(got) != "partial" {
+		t.Errorf("read %q before the error, want %q", got, "partial")
+	}
+	if credits := stream.credits("s1"); !slices.Equal(credits, []int32{1}) {
+		t.Errorf("credits = %v, want [1]", credits)
+	}
+}
@@ -0,0 +1,141 @@
+package agentHelper
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"k8s.io/client-go/tools/remotecommand"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// execInputBuffer is how many stdin frames a session queues before dropping,
+// the receive loop must not wait on a slow exec
+const execInputBuffer = 256
+
+// execSession is an exec started by a terminal init. It is registered before
+// the exec connects so input sent meanwhile is kept, in order.
+type execSession struct {
+	id    string
+	tty   bool
+	sizes *terminalSizeQueue
+	// cancel stops the exec, or the attach to a debug container
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	input  chan []byte
+	closed bool
+	done   chan struct{}
+}
+
+func newExecSession(id string, tty bool, cancel context.CancelFunc) *execSession {
+	done := make(chan struct{})
+	return &execSession{
+		id:     id,
+		tty:    tty,
+		sizes:  &terminalSizeQueue{sizes: make(chan remotecommand.TerminalSize, 1), done: done},
+		cancel: cancel,
+		input:  make(chan []byte, execInputBuffer),
+		done:   done,
+	}
+}
+
+// write queues stdin, called from the receive loop.
+func (s *execSession) write(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.input <- data:
+	default:
+		log.Warn().Str("session", s.id).Int("bytes", len(data)).Msg("exec input queue full, dropping input")
+	}
+}
+
+// signal sends a TerminalSignals signal. Without a TTY only EOF has an
+// equivalent, it closes stdin.
+func (s *execSession) signal(name string) {
+	char, ok := pb.TerminalSignals[name]
+	switch {
+	case !ok:
+		log.Warn().Str("session", s.id).Str("signal", name).Msg("unsupported terminal signal")
+	case s.tty:
+		s.write([]byte{char})
+	case name == "EOF":
+		s.closeInput()
+	default:
+		log.Warn().Str("session", s.id).Str("signal", name).Msg("signals need a TTY, ignoring")
+	}
+}
+
+func (s *execSession) closeInput() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.input)
+	}
+}
+
+// pipeInput copies the queued input to w until the input is closed.
+func (s *execSession) pipeInput(w *io.PipeWriter) {
+	for data := range s.input {
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+	}
+	w.Close()
+}
+
+// Close ends the session: its input, size queue and the exec itself.
+func (s *execSession) Close() error {
+	s.closeInput()
+	s.sizes.close()
+	s.cancel()
+	return nil
+}
+
+// terminalSizeQueue is the remotecommand.TerminalSizeQueue of a session, it
+// only keeps the latest size.
+type terminalSizeQueue struct {
+	sizes chan remotecommand.TerminalSize
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (q *terminalSizeQueue) push(size *pb.TerminalSize) {
+	if size == nil || size.Cols == 0 || size.Rows == 0 {
+		return
+	}
+	next := remotecommand.TerminalSize{Width: uint16(size.Cols), Height: uint16(size.Rows)}
+	for {
+		select {
+		case q.sizes <- next:
+			return
+		default:
+		}
+		// Replace the size the exec has not picked up yet
+		select {
+		case <-q.sizes:
+		default:
+		}
+	}
+}
+
+// Next blocks until the size changes, nil ends the queue.
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+func (q *terminalSizeQueue) close() {
+	q.once.Do(func() { close(q.done) })
+}
@@ -0,0 +1,89 @@
+package agentHelper
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
+)
+
+// tracedRequest is a request from the server the agent is handling.
+type tracedRequest struct {
+	span      trace.Span
+	operation string
+}
+
+// startSpan starts the agent's span for a request from the server, ctx
+// carries the server's trace context from the ServerMessage. Errors and the
+// status sent back for streamID are recorded on it, and the handler's
+// metrics under name, until end is called.
+func (a *Agent) startSpan(ctx context.Context, name, streamID string, attrs ...attribute.KeyValue) (context.Context, func()) {
+	attrs = append(attrs, attribute.String("gen3.stream_id", streamID))
+	ctx, span := tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+
+	a.spansMu.Lock()
+	a.spans[streamID] = &tracedRequest{span: span, operation: name}
+	a.spansMu.Unlock()
+	requestsInFlight.WithLabelValues(name).Inc()
+	started := time.Now()
+
+	return ctx, func() {
+		a.spansMu.Lock()
+		delete(a.spans, streamID)
+		a.spansMu.Unlock()
+		requestsInFlight.WithLabelValues(name).Dec()
+		requestDuration.WithLabelValues(name).Observe(time.Since(started).Seconds())
+		span.End()
+	}
+}
+
+func (a *Agent) tracedRequest(streamID string) *tracedRequest {
+	a.spansMu.Lock()
+	defer a.spansMu.Unlock()
+	if req, ok := a.spans[streamID]; ok {
+		return req
+	}
+	return &tracedRequest{span: trace.SpanFromContext(context.Background())}
+}
+
+// spanResponded records the status the agent answered streamID with.
+func (a *Agent) spanResponded(streamID string, statusCode int32) {
+	span := a.tracedRequest(streamID).span
+	span.SetAttributes(semconv.HTTPResponseStatusCode(int(statusCode)))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(int(statusCode)))
+	}
+}
+
+// spanFailed records the error sent for streamID.
+func (a *Agent) spanFailed(streamID string, err error) {
+	req := a.tracedRequest(streamID)
+	req.span.RecordError(err)
+	req.span.SetStatus(codes.Error, err.Error())
+	if req.operation != "" {
+		requestErrors.WithLabelValues(req.operation, tunnelError(err).CodeName()).Inc()
+	}
+}
+
+// tracedTransport adds a client span and traceparent header to calls the
+// agent makes, e.g. to the kube-apiserver.
+func tracedTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt)
+}
+
+func helmAttributes(release, namespace string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("helm.release", release),
+		semconv.K8SNamespaceName(namespace),
+	}
+}
@@ -0,0 +1,201 @@
+package agentHelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// VersionEnv is set on the agent container by an upgrade so the new pod
+// registers with the version the server asked for.
+const VersionEnv = "AGENT_VERSION"
+
+const upgradePollInterval = 5 * time.Second
+
+var upgradeMutex sync.Mutex
+
+func (a *Agent) sendUpgradeStatus(upgradeID string, state pb.UpgradeState, message string) {
+	err := a.sendMessage(&pb.AgentMessage{
+		Message: &pb.AgentMessage_UpgradeStatus{
+			UpgradeStatus: &pb.AgentUpgradeStatus{
+				UpgradeId: upgradeID,
+				State:     state,
+				Message:   message,
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("upgrade", upgradeID).Msg("Error sending upgrade status")
+	}
+}
+
+// handleUpgradeRequest patches the agent's own Deployment to the requested
+// image and follows the rollout. Once the new pod is ready this one is
+// terminated, so success is observed by the server when the new agent
+// registers. A rollout that exceeds its progress deadline is rolled back.
+func (a *Agent) handleUpgradeRequest(ctx context.Context, req *pb.AgentUpgradeRequest) {
+	if !upgradeMutex.TryLock() {
+		a.sendUpgradeStatus(req.UpgradeId, pb.UpgradeState_UPGRADE_FAILED, "another upgrade is already in progress")
+		return
+	}
+	defer upgradeMutex.Unlock()
+
+	log.Info().Str("upgrade", req.UpgradeId).Str("image", req.Image).Str("version", req.Version).Msg("Upgrading agent")
+
+	if err := a.upgradeDeployment(ctx, req); err != nil {
+		log.Error().Err(err).Str("upgrade", req.UpgradeId).Msg("Agent upgrade failed")
+		a.sendUpgradeStatus(req.UpgradeId, pb.UpgradeState_UPGRADE_FAILED, err.Error())
+	}
+}
+
+func (a *Agent) upgradeDeployment(ctx context.Context, req *pb.AgentUpgradeRequest) error {
+	if req.Image == "" {
+		return fmt.Errorf("no image given")
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("agent is not running in a cluster, can't upgrade itself: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating clientset: %v", err)
+	}
+	namespace, err := podNamespace()
+	if err != nil {
+		return err
+	}
+
+	deployments := clientset.AppsV1().Deployments(namespace)
+	deployment, err := deployments.Get(ctx, DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting deployment %s/%s: %v", namespace, DeploymentName, err)
+	}
+
+	var container *corev1.Container
+	for i := range deployment.Spec.Template.Spec.Containers {
+		if deployment.Spec.Template.Spec.Containers[i].Name == ContainerName {
+			container = &deployment.Spec.Template.Spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return fmt.Errorf("container %s not found in deployment %s/%s", ContainerName, namespace, DeploymentName)
+	}
+	previousImage := container.Image
+
+	patch, err := imagePatch(req.Image, req.Version)
+	if err != nil {
+		return err
+	}
+	patched, err := deployments.Patch(ctx, DeploymentName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("error patching deployment %s/%s: %v", namespace, DeploymentName, err)
+	}
+
+	a.sendUpgradeStatus(req.UpgradeId, pb.UpgradeState_UPGRADE_IN_PROGRESS,
+		fmt.Sprintf("deployment %s/%s patched from %s to %s", namespace, DeploymentName, previousImage, req.Image))
+
+	rolloutErr := waitForRollout(ctx, clientset, namespace, patched)
+	if rolloutErr == nil {
+		// Normally we are terminated before getting here
+		a.sendUpgradeStatus(req.UpgradeId, pb.UpgradeState_UPGRADE_IN_PROGRESS, "rollout complete, waiting for the new agent to register")
+		return nil
+	}
+
+	log.Warn().Err(rolloutErr).Str("image", previousImage).Msg("Agent rollout failed, rolling back")
+	rollback, err := imagePatch(previousImage, a.Version)
+	if err != nil {
+		return err
+	}
+	if _, err := deployments.Patch(ctx, DeploymentName, types.StrategicMergePatchType, rollback, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("%v, and rolling back to %s failed: %v", rolloutErr, previousImage, err)
+	}
+	return fmt.Errorf("%v, rolled back to %s", rolloutErr, previousImage)
+}
+
+// imagePatch builds a strategic merge patch setting the agent container's image and version.
+func imagePatch(image, version string) ([]byte, error) {
+	container := map[string]interface{}{
+		"name":  ContainerName,
+		"image": image,
+	}
+	if version != "" {
+		container["env"] = []corev1.EnvVar{{Name: VersionEnv, Value: version}}
+	}
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{container},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("error building patch: %v", err)
+	}
+	return data, nil
+}
+
+// waitForRollout waits until the deployment is fully rolled out, or fails
+// when the deployment controller reports its progress deadline exceeded.
+func waitForRollout(ctx context.Context, clientset kubernetes.Interface, namespace string, patched *appsv1.Deployment) error {
+	deadline := 10 * time.Minute
+	if patched.Spec.ProgressDeadlineSeconds != nil {
+		deadline = time.Duration(*patched.Spec.ProgressDeadlineSeconds) * time.Second
+	}
+	// Leave the controller time to report the deadline itself
+	ctx, cancel := context.WithTimeout(ctx, deadline+time.Minute)
+	defer cancel()
+
+	ticker := time.NewTicker(upgradePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("rollout did not complete within %s", deadline+time.Minute)
+		case <-ticker.C:
+		}
+
+		d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, patched.Name, metav1.GetOptions{})
+		if err != nil {
+			log.Warn().Err(err).Msg("Error getting deployment during rollout")
+			continue
+		}
+		if d.Status.ObservedGeneration < patched.Generation {
+			continue
+		}
+		for _, cond := range d.Status.Conditions {
+			if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+				return fmt.Errorf("rollout exceeded its progress deadline: %s", cond.Message)
+			}
+		}
+
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if d.Status.UpdatedReplicas == replicas && d.Status.Replicas == replicas && d.Status.AvailableReplicas == replicas {
+			return nil
+		}
+		log.Debug().
+			Int32("updated", d.Status.UpdatedReplicas).
+			Int32("available", d.Status.AvailableReplicas).
+			Int32("replicas", replicas).
+			Msg("Waiting for agent rollout")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+type portForwardOptions struct {
+	server    string
+	token     string
+	agent     string
+	namespace string
+	kind      string
+	name      string
+	insecure  bool
+}
+
+// portMapping is one [LOCAL:]REMOTE argument
+type portMapping struct {
+	local  int
+	remote int
+}
+
+func portForward(args []string) error {
+	fs := flag.NewFlagSet("port-forward", flag.ExitOnError)
+	opts := portForwardOptions{}
+	fs.StringVar(&opts.server, "server", envOr("GEN3_SERVER", "http://localhost:8002"), "URL of the API server, or GEN3_SERVER")
+	fs.StringVar(&opts.token, "token", os.Getenv("GEN3_TOKEN"), "Access token, or GEN3_TOKEN")
+	fs.StringVar(&opts.agent, "agent", "", "Agent whose cluster runs the target")
+	fs.StringVar(&opts.namespace, "n", "default", "Namespace of the target")
+	fs.BoolVar(&opts.insecure, "insecure-skip-tls-verify", false, "Don't verify the API server's certificate")
+	address := fs.String("address", "127.0.0.1", "Local address to listen on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gen3-cli port-forward -agent NAME [flags] pod/NAME|svc/NAME [LOCAL:]REMOTE...")
+		fmt.Fprintln(fs.Output(), "\nExample: gen3-cli port-forward -agent prod -n gen3 svc/postgres 15432:5432")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if opts.agent == "" || fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kind, name, ok := strings.Cut(fs.Arg(0), "/")
+	if !ok || name == "" {
+		return fmt.Errorf("target must be pod/NAME or svc/NAME, got %q", fs.Arg(0))
+	}
+	opts.kind, opts.name = kind, name
+
+	mappings := []portMapping{}
+	for _, arg := range fs.Args()[1:] {
+		m, err := parsePortMapping(arg)
+		if err != nil {
+			return err
+		}
+		mappings = append(mappings, m)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, m := range mappings {
+		ln, err := net.Listen("tcp", net.JoinHostPort(*address, strconv.Itoa(m.local)))
+		if err != nil {
+			return fmt.Errorf("failed to listen on port %d: %v", m.local, err)
+		}
+		fmt.Printf("Forwarding from %s -> %d\n", ln.Addr(), m.remote)
+		go func() {
+			<-ctx.Done()
+			ln.Close()
+		}()
+		go acceptForwards(ctx, ln, opts, m.remote)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+func acceptForwards(ctx context.Context, ln net.Listener, opts portForwardOptions, remote int) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Msg("Accept failed")
+			}
+			return
+		}
+		go func() {
+			if err := forwardConn(ctx, conn, opts, remote); err != nil {
+				log.Error().Err(err).Int("port", remote).Msg("Port-forward failed")
+			}
+		}()
+	}
+}
+
+// forwardConn relays one local connection over its own WebSocket.
+func forwardConn(ctx context.Context, conn net.Conn, opts portForwardOptions, remote int) error {
+	defer conn.Close()
+
+	u, err := url.Parse(strings.TrimSuffix(opts.server, "/"))
+	if err != nil {
+		return fmt.Errorf("invalid server URL: %v", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path += fmt.Sprintf("/api/agents/%s/portforward/%s/%s/%s/%d",
+		url.PathEscape(opts.agent), url.PathEscape(opts.namespace), url.PathEscape(opts.kind), url.PathEscape(opts.name), remote)
+
+	header := http.Header{}
+	if opts.token != "" {
+		header.Set("Authorization", "Bearer "+opts.token)
+	}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 30 * time.Second,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: opts.insecure},
+	}
+	ws, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			return fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return fmt.Errorf("failed to connect to server: %v", err)
+	}
+	defer ws.Close()
+
+	remoteDone := make(chan error, 1)
+	go func() {
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) && closeErr.Code == websocket.CloseNormalClosure {
+					err = nil
+				}
+				remoteDone <- err
+				return
+			}
+			if _, err := conn.Write(msg); err != nil {
+				remoteDone <- nil
+				return
+			}
+		}
+	}()
+
+	localDone := make(chan struct{})
+	go func() {
+		defer close(localDone)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if werr := ws.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-remoteDone:
+		return err
+	case <-localDone:
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func parsePortMapping(arg string) (portMapping, error) {
+	localStr, remoteStr, found := strings.Cut(arg, ":")
+	if !found {
+		remoteStr = localStr
+	}
+	remote, err := strconv.Atoi(remoteStr)
+	if err != nil || remote < 1 || remote > 65535 {
+		return portMapping{}, fmt.Errorf("invalid remote port in %q", arg)
+	}
+	local, err := strconv.Atoi(localStr)
+	if err != nil || local < 0 || local > 65535 {
+		return portMapping{}, fmt.Errorf("invalid local port in %q", arg)
+	}
+	return portMapping{local: local, remote: remote}, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
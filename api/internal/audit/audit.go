@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+// Keys handlers set through Describe
+const (
+	targetKey  = "audit.target"
+	summaryKey = "audit.summary"
+)
+
+// maxErrorBody is how much of a failed response is kept as the entry's error
+const maxErrorBody = 1024
+
+var (
+	sinkMu sync.RWMutex
+	sink   store.Store
+)
+
+// SetStore makes Record append to s. Until then entries are only logged.
+func SetStore(s store.Store) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	sink = s
+}
+
+// Record appends entry to the audit log. Failing to store it is logged, the
+// operation it describes already happened.
+func Record(entry store.AuditEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	log.Info().
+		Str("user", entry.User).
+		Str("agent", entry.Agent).
+		Str("action", entry.Action).
+		Str("target", entry.Target).
+		Str("result", string(entry.Result)).
+		Int("status", entry.StatusCode).
+		Msg("audit")
+
+	sinkMu.RLock()
+	s := sink
+	sinkMu.RUnlock()
+	if s == nil {
+		return
+	}
+	if err := s.RecordAudit(entry); err != nil {
+		log.Error().Err(err).Str("action", entry.Action).Str("user", entry.User).Msg("Failed to record audit entry")
+	}
+}
+
+// Action audits every request of the route as action once its handler
+// returned, long running ones like terminal sessions when they end.
+func Action(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		audit(c, action)
+	}
+}
+
+// Mutations audits requests that may change state as action, GET, HEAD and
+// OPTIONS requests pass unaudited.
+func Mutations(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		audit(c, action)
+	}
+}
+
+// Describe sets what the request changed. Without it the entry names the
+// request path and method. Never pass secrets such as helm values.
+func Describe(c *gin.Context, target, summary string) {
+	if target != "" {
+		c.Set(targetKey, target)
+	}
+	if summary != "" {
+		c.Set(summaryKey, summary)
+	}
+}
+
+// User is the username the keycloak middleware authenticated.
+func User(c *gin.Context) string {
+	userInfoInterface, _ := c.Get("userInfo")
+	if userInfo, ok := userInfoInterface.(map[string]interface{}); ok && userInfo["username"] != nil {
+		return fmt.Sprintf("%v", userInfo["username"])
+	}
+	return ""
+}
+
+func audit(c *gin.Context, action string) {
+	start := time.Now()
+	writer := &errorCapture{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	c.Next()
+
+	status := c.Writer.Status()
+	entry := store.AuditEntry{
+		Timestamp:  start,
+		User:       User(c),
+		Agent:      c.Param("agent"),
+		Action:     action,
+		Target:     c.GetString(targetKey),
+		Summary:    c.GetString(summaryKey),
+		Result:     store.AuditSuccess,
+		StatusCode: status,
+		RemoteAddr: c.ClientIP(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if entry.Target == "" {
+		entry.Target = c.Request.URL.Path
+	}
+	if entry.Summary == "" {
+		entry.Summary = c.Request.Method + " " + c.Request.URL.RequestURI()
+	}
+	if status >= http.StatusBadRequest || len(c.Errors) > 0 {
+		entry.Result = store.AuditFailure
+		entry.Error = writer.message()
+		if err := c.Errors.Last(); err != nil && entry.Error == "" {
+			entry.Error = err.Error()
+		}
+	}
+	Record(entry)
+}
+
+// errorCapture keeps the start of error responses for the audit entry.
+type errorCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorCapture) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorCapture) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorCapture) capture(b []byte) {
+	if w.Status() < http.StatusBadRequest || w.body.Len() >= maxErrorBody {
+		return
+	}
+	w.body.Write(b[:min(len(b), maxErrorBody-w.body.Len())])
+}
+
+// message is the "error" of a JSON error response, the raw body otherwise.
+func (w *errorCapture) message() string {
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(w.body.Bytes(), &resp) == nil && resp.Error != "" {
+		return resp.Error
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(w.body.String(), ""))
+}
@@ -0,0 +1,97 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// AgentCertValidity is how long issued agent client certificates are valid.
+const AgentCertValidity = 365 * 24 * time.Hour
+
+// IssueAgentCertificate signs a client certificate for the agent's public key.
+// agentID ends up in the subject serial number, it is what the server reports as the agent id.
+func IssueAgentCertificate(pub crypto.PublicKey, agentName string, agentID string) (*x509.Certificate, []byte, error) {
+	caCert, caKey, err := LoadOrCreateCA()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading/creating CA: %v", err)
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number:%v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: agentName, SerialNumber: agentID},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(AgentCertValidity),
+		DNSNames:     []string{agentName, "csoc.gen3.org", "localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, pub, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating agent certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing agent certificate: %v", err)
+	}
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), nil
+}
+
+// SignAgentCSR validates a PEM encoded CSR from an agent and issues a
+// certificate for it. The CSR common name has to match the agent name.
+func SignAgentCSR(csrPEM []byte, agentName string, agentID string) (*x509.Certificate, []byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, nil, errors.New("invalid certificate signing request")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing certificate signing request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate signing request signature: %v", err)
+	}
+	if csr.Subject.CommonName != agentName {
+		return nil, nil, fmt.Errorf("certificate signing request is for %q, expected %q", csr.Subject.CommonName, agentName)
+	}
+
+	return IssueAgentCertificate(csr.PublicKey, agentName, agentID)
+}
+
+// CACertificatePEM returns the PEM encoded CA certificate agents should trust.
+func CACertificatePEM() ([]byte, error) {
+	caCert, _, err := LoadOrCreateCA()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), nil
+}
+
+// CertificateExpiry returns NotAfter of a PEM encoded certificate, or the zero time if it can't be parsed.
+func CertificateExpiry(certPEM string) time.Time {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return time.Time{}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}
+	}
+	return cert.NotAfter
+}
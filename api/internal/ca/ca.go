@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -22,12 +23,31 @@ var (
 	certCurve = elliptic.P384()
 )
 
-const (
-	// Read from ENV fall back to these
+var (
+	// CA_CERT_FILE and CA_KEY_FILE override these
 	caCertFile = "certs/ca.crt"
 	caKeyFile  = "certs/ca.key"
+
+	// generateCA creates the CA on first start when there is none
+	generateCA = true
 )
 
+func init() {
+	if f := os.Getenv("CA_CERT_FILE"); f != "" {
+		caCertFile = f
+	}
+	if f := os.Getenv("CA_KEY_FILE"); f != "" {
+		caKeyFile = f
+	}
+}
+
+// RequireExistingCA stops LoadOrCreateCA from generating a CA. Replicas
+// sharing a store must all sign with the one they are given, a CA generated
+// by one of them would not be trusted by the others.
+func RequireExistingCA() {
+	generateCA = false
+}
+
 func LoadOrCreateServerCert(caCert *x509.Certificate, caKey *ecdsa.PrivateKey, serverCertFile string, serverKeyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	if _, err := os.Stat(serverCertFile); err == nil {
 		serverCertPEM, _ := pem.Decode(utils.MustReadFile(serverCertFile))
@@ -124,6 +144,9 @@ func LoadOrCreateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 
 		return caCert, caKey, nil
 	}
+	if !generateCA {
+		return nil, nil, fmt.Errorf("CA certificate %s not found, set CA_CERT_FILE and CA_KEY_FILE to the CA shared by all replicas", caCertFile)
+	}
 
 	caPrivKey, err := ecdsa.GenerateKey(certCurve, rand.Reader)
 	if err != nil {
@@ -159,12 +182,19 @@ func LoadOrCreateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	}
 
 	// Make sure the CA directory exists
-	os.MkdirAll("certs", 0755)
+	os.MkdirAll(filepath.Dir(caCertFile), 0755)
 
 	utils.MustWriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertBytes}), 0644)
 	utils.MustWriteFile(caKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: caKeyBytes}), 0600)
 
-	return caTemplate, caPrivKey, nil
+	// Return the parsed certificate rather than the template, it carries the
+	// generated subject key id needed to sign CRLs
+	caCert, err := x509.ParseCertificate(caCertBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing CA certificate after creation: %v", err)
+	}
+
+	return caCert, caPrivKey, nil
 }
 
 func SetupCerts() (*credentials.TransportCredentials, error) {
@@ -196,10 +226,17 @@ func SetupCerts() (*credentials.TransportCredentials, error) {
 		log.Fatal().Err(err).Msg("Failed to create TLS certificate")
 	}
 
+	if err := LoadRevocations(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to load certificate revocation list")
+	}
+
+	// Client certs are verified when presented but not required at the TLS
+	// layer so new agents can call Enroll. Connect rejects streams without one.
 	creds := credentials.NewTLS(&tls.Config{
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{tlsCert},
-		ClientCAs:    certPool,
+		ClientAuth:            tls.VerifyClientCertIfGiven,
+		Certificates:          []tls.Certificate{tlsCert},
+		ClientCAs:             certPool,
+		VerifyPeerCertificate: VerifyPeerNotRevoked,
 	})
 	return &creds, nil
 }
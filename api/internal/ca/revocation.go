@@ -0,0 +1,292 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	revocationsFile = "certs/revocations.json"
+	crlFile         = "certs/ca.crl"
+)
+
+// Revocation is a single revoked certificate. The list keeps the agent name
+// and reason for the API, ca.crl is the signed CRL generated from it.
+type Revocation struct {
+	SerialNumber string    `json:"serialNumber"`
+	Agent        string    `json:"agent"`
+	Reason       string    `json:"reason"`
+	RevokedAt    time.Time `json:"revokedAt"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+// RevocationBackend keeps the revocation list in place of revocations.json,
+// for API replicas that must all enforce the same list.
+type RevocationBackend interface {
+	SaveRevocation(r Revocation) error
+	ListRevocations() ([]Revocation, error)
+}
+
+// revocationRefreshInterval is how often the list is reloaded from a backend,
+// other replicas may have revoked certificates since.
+const revocationRefreshInterval = 10 * time.Second
+
+var (
+	revocationsMu       sync.RWMutex
+	revocations         map[string]Revocation
+	revocationsLoaded   bool
+	revocationBackend   RevocationBackend
+	revocationsLoadedAt time.Time
+)
+
+// UseRevocationBackend keeps the revocation list in backend. The CRL is still
+// written under certs/, each replica publishes its own copy.
+func UseRevocationBackend(backend RevocationBackend) {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	revocationBackend = backend
+	revocationsLoaded = false
+}
+
+func loadRevocationsLocked() error {
+	if revocationBackend != nil {
+		return refreshRevocationsLocked()
+	}
+	if revocationsLoaded {
+		return nil
+	}
+	revocations = make(map[string]Revocation)
+
+	data, err := os.ReadFile(revocationsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			revocationsLoaded = true
+			return nil
+		}
+		return fmt.Errorf("error reading revocation list: %v", err)
+	}
+
+	var list []Revocation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("error parsing revocation list: %v", err)
+	}
+	for _, r := range list {
+		revocations[r.SerialNumber] = r
+	}
+	revocationsLoaded = true
+	return nil
+}
+
+// refreshRevocationsLocked reloads the backend's list once it is due. A
+// failed reload keeps the last list, failing closed on a store outage would
+// lock every agent out.
+func refreshRevocationsLocked() error {
+	if revocationsLoaded && time.Since(revocationsLoadedAt) < revocationRefreshInterval {
+		return nil
+	}
+	list, err := revocationBackend.ListRevocations()
+	if err != nil {
+		if revocationsLoaded {
+			log.Error().Err(err).Msg("Failed to reload revocation list, keeping the last one")
+			revocationsLoadedAt = time.Now()
+			return nil
+		}
+		return fmt.Errorf("error reading revocation list: %v", err)
+	}
+
+	changed := !revocationsLoaded || len(list) != len(revocations)
+	revocations = make(map[string]Revocation, len(list))
+	for _, r := range list {
+		revocations[r.SerialNumber] = r
+	}
+	revocationsLoaded = true
+	revocationsLoadedAt = time.Now()
+
+	// Keeps the CRL in line with revocations made on other replicas
+	if changed {
+		if err := writeCRL(crlEntriesLocked()); err != nil {
+			log.Error().Err(err).Msg("Failed to write CRL")
+		}
+	}
+	return nil
+}
+
+// LoadRevocations reads the revocation list from disk. It is safe to call
+// more than once, the list is only read the first time.
+func LoadRevocations() error {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	return loadRevocationsLocked()
+}
+
+// IsRevoked reports whether the certificate with the given serial number has been revoked.
+func IsRevoked(serial *big.Int) bool {
+	if serial == nil {
+		return false
+	}
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	if err := loadRevocationsLocked(); err != nil {
+		// Fail closed, a broken revocation list must not let revoked agents in
+		log.Error().Err(err).Msg("Failed to load revocation list")
+		return true
+	}
+	_, revoked := revocations[serial.String()]
+	return revoked
+}
+
+// ListRevocations returns all revoked certificates, most recent first.
+func ListRevocations() ([]Revocation, error) {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	if err := loadRevocationsLocked(); err != nil {
+		return nil, err
+	}
+
+	list := make([]Revocation, 0, len(revocations))
+	for _, r := range revocations {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RevokedAt.After(list[j].RevokedAt)
+	})
+	return list, nil
+}
+
+// RevokeCertificate adds the certificate to the revocation list and
+// regenerates the signed CRL.
+func RevokeCertificate(cert *x509.Certificate, agentName string, reason string) error {
+	if cert == nil || cert.SerialNumber == nil {
+		return errors.New("certificate with serial number is required")
+	}
+	return RevokeSerial(cert.SerialNumber.String(), cert.NotAfter, agentName, reason)
+}
+
+// RevokeSerial revokes a certificate known only by its serial number, e.g.
+// one the agent has since renewed.
+func RevokeSerial(serial string, notAfter time.Time, agentName string, reason string) error {
+	if _, ok := new(big.Int).SetString(serial, 10); !ok {
+		return fmt.Errorf("invalid serial number %q", serial)
+	}
+
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	if err := loadRevocationsLocked(); err != nil {
+		return err
+	}
+
+	if _, exists := revocations[serial]; exists {
+		return nil
+	}
+
+	revocations[serial] = Revocation{
+		SerialNumber: serial,
+		Agent:        agentName,
+		Reason:       reason,
+		RevokedAt:    time.Now(),
+		NotAfter:     notAfter,
+	}
+
+	if err := writeRevocationsLocked(revocations[serial]); err != nil {
+		delete(revocations, serial)
+		return err
+	}
+
+	log.Info().Str("agent", agentName).Str("serial", serial).Str("reason", reason).Msg("Certificate revoked")
+	return nil
+}
+
+// writeRevocationsLocked saves the list with added, the new revocation, and
+// regenerates the CRL.
+func writeRevocationsLocked(added Revocation) error {
+	if revocationBackend != nil {
+		if err := revocationBackend.SaveRevocation(added); err != nil {
+			return fmt.Errorf("error saving revocation: %v", err)
+		}
+	} else {
+		list := make([]Revocation, 0, len(revocations))
+		for _, r := range revocations {
+			list = append(list, r)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].RevokedAt.Before(list[j].RevokedAt)
+		})
+
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling revocation list: %v", err)
+		}
+		if err := os.WriteFile(revocationsFile, data, 0644); err != nil {
+			return fmt.Errorf("error writing revocation list: %v", err)
+		}
+	}
+
+	// The list is what the server enforces, the CRL is only published for
+	// external consumers so failing to sign it is not fatal.
+	if err := writeCRL(crlEntriesLocked()); err != nil {
+		log.Error().Err(err).Msg("Failed to write CRL")
+	}
+	return nil
+}
+
+func crlEntriesLocked() []x509.RevocationListEntry {
+	entries := make([]x509.RevocationListEntry, 0, len(revocations))
+	for _, r := range revocations {
+		serial, ok := new(big.Int).SetString(r.SerialNumber, 10)
+		if !ok {
+			continue
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: r.RevokedAt,
+		})
+	}
+	return entries
+}
+
+func writeCRL(entries []x509.RevocationListEntry) error {
+	caCert, caKey, err := LoadOrCreateCA()
+	if err != nil {
+		return fmt.Errorf("error loading CA: %v", err)
+	}
+
+	now := time.Now()
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(now.UnixNano()),
+		ThisUpdate:                now,
+		NextUpdate:                now.AddDate(0, 0, 7),
+		RevokedCertificateEntries: entries,
+	}, caCert, caKey)
+	if err != nil {
+		return fmt.Errorf("error creating CRL: %v", err)
+	}
+	if err := os.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes}), 0644); err != nil {
+		return fmt.Errorf("error writing CRL: %v", err)
+	}
+	return nil
+}
+
+// VerifyPeerNotRevoked is used as tls.Config.VerifyPeerCertificate so revoked
+// agents are rejected during the handshake, before any stream is opened.
+func VerifyPeerNotRevoked(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		if IsRevoked(chain[0].SerialNumber) {
+			return fmt.Errorf("certificate %s for %s has been revoked", chain[0].SerialNumber, chain[0].Subject.CommonName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTempCerts runs the test in an empty directory with a fresh revocation
+// list, the CA and the list live under certs/ relative to it.
+func useTempCerts(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("certs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	resetRevocations()
+	t.Cleanup(resetRevocations)
+}
+
+// resetRevocations makes the next call read the list from disk again.
+func resetRevocations() {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	revocations = nil
+	revocationsLoaded = false
+	revocationBackend = nil
+}
+
+func TestIsRevoked(t *testing.T) {
+	useTempCerts(t)
+
+	revoked := &x509.Certificate{SerialNumber: big.NewInt(1001), NotAfter: time.Now().Add(time.Hour)}
+	if err := RevokeCertificate(revoked, "agent1", "compromised"); err != nil {
+		t.Fatalf("RevokeCertificate: %v", err)
+	}
+	if err := RevokeSerial("2002", time.Now().Add(time.Hour), "agent1", "superseded"); err != nil {
+		t.Fatalf("RevokeSerial: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		serial *big.Int
+		want   bool
+	}{
+		{"revoked certificate", big.NewInt(1001), true},
+		{"revoked serial", big.NewInt(2002), true},
+		{"valid certificate", big.NewInt(3003), false},
+		{"no serial", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRevoked(tt.serial); got != tt.want {
+				t.Errorf("IsRevoked(%v) = %v, want %v", tt.serial, got, tt.want)
+			}
+		})
+	}
+
+	// Revocations survive a restart
+	resetRevocations()
+	if !IsRevoked(big.NewInt(1001)) || !IsRevoked(big.NewInt(2002)) {
+		t.Error("revocations were not read back from disk")
+	}
+}
+
+func TestIsRevokedWithoutList(t *testing.T) {
+	useTempCerts(t)
+
+	if IsRevoked(big.NewInt(1)) {
+		t.Error("certificate revoked without a revocation list")
+	}
+}
+
+func TestIsRevokedFailsClosed(t *testing.T) {
+	useTempCerts(t)
+
+	if err := os.WriteFile(revocationsFile, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsRevoked(big.NewInt(1)) {
+		t.Error("a broken revocation list must reject every certificate")
+	}
+}
+
+func TestRevokeSerialRejectsInvalidSerial(t *testing.T) {
+	useTempCerts(t)
+
+	for _, serial := range []string{"", "abc", "12x"} {
+		if err := RevokeSerial(serial, time.Now(), "agent1", "test"); err == nil {
+			t.Errorf("RevokeSerial(%q) succeeded, expected an error", serial)
+		}
+	}
+}
+
+func TestRevokeCertificateWritesCRL(t *testing.T) {
+	useTempCerts(t)
+
+	if err := RevokeSerial("4004", time.Now().Add(time.Hour), "agent1", "test"); err != nil {
+		t.Fatalf("RevokeSerial: %v", err)
+	}
+	// Revoking again keeps a single entry
+	if err := RevokeSerial("4004", time.Now().Add(time.Hour), "agent1", "again"); err != nil {
+		t.Fatalf("RevokeSerial: %v", err)
+	}
+
+	data, err := os.ReadFile(crlFile)
+	if err != nil {
+		t.Fatalf("reading CRL: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("CRL is not PEM")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing CRL: %v", err)
+	}
+	caCert, _, err := LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(caCert); err != nil {
+		t.Errorf("CRL is not signed by the CA: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(big.NewInt(4004)) != 0 {
+		t.Errorf("CRL entries = %v, want serial 4004 only", crl.RevokedCertificateEntries)
+	}
+
+	list, err := ListRevocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Reason != "test" {
+		t.Errorf("ListRevocations = %+v, want the first revocation only", list)
+	}
+}
+
+func TestVerifyPeerNotRevoked(t *testing.T) {
+	useTempCerts(t)
+
+	if err := RevokeSerial("5005", time.Now().Add(time.Hour), "agent1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	chain := func(serial int64) [][]*x509.Certificate {
+		return [][]*x509.Certificate{{{SerialNumber: big.NewInt(serial)}}}
+	}
+	if err := VerifyPeerNotRevoked(nil, chain(5005)); err == nil {
+		t.Error("revoked peer accepted")
+	}
+	if err := VerifyPeerNotRevoked(nil, chain(6006)); err != nil {
+		t.Errorf("valid peer rejected: %v", err)
+	}
+}
+
+// memoryBackend is a revocation list shared by the replicas of a test.
+type memoryBackend struct {
+	mu   sync.Mutex
+	list []Revocation
+	err  error
+}
+
+func (m *memoryBackend) SaveRevocation(r Revocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.list = append(m.list, r)
+	return nil
+}
+
+func (m *memoryBackend) ListRevocations() ([]Revocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]Revocation(nil), m.list...), nil
+}
+
+// expireRevocations makes the next check reload the list from the backend.
+func expireRevocations() {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	revocationsLoadedAt = time.Time{}
+}
+
+func TestRevocationBackend(t *testing.T) {
+	useTempCerts(t)
+	backend := &memoryBackend{}
+	UseRevocationBackend(backend)
+
+	if err := RevokeSerial("7007", time.Now().Add(time.Hour), "agent1", "compromised"); err != nil {
+		t.Fatalf("RevokeSerial: %v", err)
+	}
+	if len(backend.list) != 1 || backend.list[0].SerialNumber != "7007" {
+		t.Fatalf("backend list = %+v, want serial 7007", backend.list)
+	}
+	if _, err := os.Stat(revocationsFile); !os.IsNotExist(err) {
+		t.Errorf("revocations.json written with a backend: %v", err)
+	}
+	if _, err := os.Stat(crlFile); err != nil {
+		t.Errorf("CRL not written: %v", err)
+	}
+
+	// Another replica revokes a certificate
+	backend.SaveRevocation(Revocation{SerialNumber: "8008", Agent: "agent2", RevokedAt: time.Now()})
+	if IsRevoked(big.NewInt(8008)) {
+		t.Error("list reloaded before the refresh interval")
+	}
+	expireRevocations()
+	if !IsRevoked(big.NewInt(8008)) {
+		t.Error("revocation made on another replica not enforced after a refresh")
+	}
+
+	// A store outage keeps the last list
+	backend.err = errors.New("store down")
+	expireRevocations()
+	if !IsRevoked(big.NewInt(7007)) || IsRevoked(big.NewInt(9009)) {
+		t.Error("the last list must stay enforced while the backend fails")
+	}
+	if err := RevokeSerial("9009", time.Now().Add(time.Hour), "agent1", "test"); err == nil {
+		t.Error("RevokeSerial succeeded without saving to the backend")
+	}
+	if IsRevoked(big.NewInt(9009)) {
+		t.Error("a revocation the backend did not save is enforced")
+	}
+}
+
+func TestRevocationBackendFailsClosed(t *testing.T) {
+	useTempCerts(t)
+	UseRevocationBackend(&memoryBackend{err: errors.New("store down")})
+
+	if !IsRevoked(big.NewInt(1)) {
+		t.Error("a backend that never loaded must reject every certificate")
+	}
+}
+
+func TestRequireExistingCA(t *testing.T) {
+	useTempCerts(t)
+	RequireExistingCA()
+	t.Cleanup(func() { generateCA = true })
+
+	if _, _, err := LoadOrCreateCA(); err == nil {
+		t.Fatal("a CA was generated although one is required")
+	}
+	if _, err := os.Stat(caKeyFile); !os.IsNotExist(err) {
+		t.Errorf("CA key written: %v", err)
+	}
+
+	generateCA = true
+	created, _, err := LoadOrCreateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	RequireExistingCA()
+	loaded, _, err := LoadOrCreateCA()
+	if err != nil {
+		t.Fatalf("existing CA not loaded: %v", err)
+	}
+	if !loaded.Equal(created) {
+		t.Error("loaded a different CA")
+	}
+}
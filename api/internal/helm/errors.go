@@ -0,0 +1,55 @@
+package helm
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrReleaseNotFound is returned when helm has no such release
+	ErrReleaseNotFound = errors.New("release not found")
+	// ErrReleaseExists is returned when installing over a release name in use
+	ErrReleaseExists = errors.New("release already exists")
+	// ErrForbidden is returned when the cluster's RBAC denied helm
+	ErrForbidden = errors.New("forbidden")
+)
+
+// cliError is a failed helm command, it reads as helm's own message and
+// matches the sentinel its stderr points to.
+type cliError struct {
+	err  error
+	kind error
+}
+
+func (e *cliError) Error() string   { return e.err.Error() }
+func (e *cliError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// stderrKind picks the sentinel for what helm printed, or nil.
+func stderrKind(stderr string) error {
+	stderr = strings.ToLower(stderr)
+	switch {
+	case strings.Contains(stderr, "release: not found"):
+		return ErrReleaseNotFound
+	case strings.Contains(stderr, "cannot re-use a name that is still in use"):
+		return ErrReleaseExists
+	case strings.Contains(stderr, "is forbidden"):
+		return ErrForbidden
+	}
+	return nil
+}
+
+// commandError replaces the bare exit status of cmd.Output() with helm's
+// message.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || len(exitErr.Stderr) == 0 {
+		return err
+	}
+	msg := strings.TrimPrefix(strings.TrimSpace(string(exitErr.Stderr)), "Error: ")
+	err = errors.New(msg)
+	if kind := stderrKind(msg); kind != nil {
+		return &cliError{err: err, kind: kind}
+	}
+	return err
+}
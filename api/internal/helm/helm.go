@@ -97,7 +97,7 @@ func ShowHelmValues(releaseName string, namespace string) (map[string]interface{
 	cmd := exec.Command("helm", "get", "values", releaseName, "-n", namespace, "--output", "json")
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get release values: %w", err)
+		return nil, fmt.Errorf("failed to get release values: %w", commandError(err))
 	}
 
 	var result map[string]interface{}
@@ -112,7 +112,7 @@ func DeleteHelmRelease(releaseName string, namespace string) (*string, error) {
 	cmd := exec.Command("helm", "uninstall", releaseName, "-n", namespace)
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete release: %w", err)
+		return nil, fmt.Errorf("failed to delete release: %w", commandError(err))
 	}
 
 	result := string(output)
@@ -252,7 +252,11 @@ func InstallHelmChart(opts InstallOptions) (*HelmRelease, error) {
 		// Log the full error for debugging
 		log.Error().Msgf("%s", errorMsg.String())
 
-		return nil, fmt.Errorf("%s: %w", errorMsg.String(), err)
+		err = fmt.Errorf("%s: %w", errorMsg.String(), err)
+		if kind := stderrKind(stderr.String()); kind != nil {
+			err = &cliError{err: err, kind: kind}
+		}
+		return nil, err
 	}
 
 	// Log successful installation/upgrade
@@ -472,7 +476,7 @@ func GetHelmReleaseHistory(releaseName, namespace string) ([]map[string]interfac
 	cmd := exec.Command("helm", "history", releaseName, "-n", namespace, "--output", "json")
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get release history: %w", err)
+		return nil, fmt.Errorf("failed to get release history: %w", commandError(err))
 	}
 
 	var history []map[string]interface{}
@@ -491,8 +495,8 @@ func RollbackHelmRelease(releaseName, namespace string, revision int) error {
 	args = append(args, "-n", namespace)
 
 	cmd := exec.Command("helm", args...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to rollback release: %w", err)
+	if _, err := cmd.Output(); err != nil {
+		return fmt.Errorf("failed to rollback release: %w", commandError(err))
 	}
 
 	return nil
@@ -8,6 +8,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 )
 
 // StructuredLogger logs a gin HTTP request in JSON format. Allows to set the
@@ -60,6 +62,10 @@ func StructuredLogger(logger *zerolog.Logger) gin.HandlerFunc {
 			logEvent = logger.Info()
 		}
 
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			logEvent = logEvent.Str("trace_id", traceID)
+		}
+
 		logEvent.Str("client_id", param.ClientIP).
 			Str("method", param.Method).
 			Int("status_code", param.StatusCode).
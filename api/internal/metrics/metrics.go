@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// TunnelCompressedBytes counts compressed response bytes received from agents
+	TunnelCompressedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_tunnel_compressed_bytes_total",
+		Help: "Compressed proxy response bytes received from agents.",
+	}, []string{"agent", "codec"})
+
+	// TunnelCompressionSavedBytes counts bytes compression kept off the tunnel
+	TunnelCompressionSavedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_tunnel_compression_saved_bytes_total",
+		Help: "Proxy response bytes saved on the tunnel by compression, decompressed minus compressed size.",
+	}, []string{"agent", "codec"})
+
+	// TunnelDeadStreams counts responses for streams whose handler was gone
+	TunnelDeadStreams = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_tunnel_dead_streams_total",
+		Help: "Streams agents kept answering after the server's handler had cleaned up.",
+	}, []string{"agent"})
+
+	// AgentRequestDuration times requests until the agent's first answer
+	AgentRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gen3_agent_request_duration_seconds",
+		Help:    "Time until an agent answered a request sent through the tunnel.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"agent", "kind"})
+
+	// AgentRequests counts requests sent to agents by outcome, code is "ok"
+	// or the tunnel error code, e.g. "timeout"
+	AgentRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_agent_requests_total",
+		Help: "Requests sent to agents through the tunnel, by outcome.",
+	}, []string{"agent", "kind", "code"})
+
+	// TerminalSessions is the number of open terminal websockets
+	TerminalSessions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gen3_terminal_sessions",
+		Help: "Terminal sessions currently open through an agent.",
+	}, []string{"agent"})
+
+	// HelmOperations counts helm operations by outcome, "success" or the
+	// tunnel error code
+	HelmOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_helm_operations_total",
+		Help: "Helm operations run by agents or locally, by outcome.",
+	}, []string{"agent", "operation", "outcome"})
+
+	// RunnerExecutions counts finished runner commands by final status
+	RunnerExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_runner_executions_total",
+		Help: "Runner command executions that finished, by status.",
+	}, []string{"status"})
+
+	// RunnerExecutionsRunning is the number of runner commands in progress
+	RunnerExecutionsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gen3_runner_executions_running",
+		Help: "Runner command executions in progress.",
+	})
+
+	// TerraformExecutions counts finished terraform executions by final
+	// status, executions that failed to launch count as "error"
+	TerraformExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gen3_terraform_executions_total",
+		Help: "Terraform executions that finished, by status.",
+	}, []string{"operation", "runtime", "status"})
+
+	// TerraformExecutionsRunning is the number of terraform executions in progress
+	TerraformExecutionsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gen3_terraform_executions_running",
+		Help: "Terraform executions in progress.",
+	})
+)
+
+// agentStates reports each known agent as connected or not at scrape time.
+type agentStates struct {
+	desc   *prometheus.Desc
+	states func() map[string]bool
+}
+
+func (s *agentStates) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+func (s *agentStates) Collect(ch chan<- prometheus.Metric) {
+	for name, connected := range s.states() {
+		value := 0.0
+		if connected {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, value, name)
+	}
+}
+
+// RegisterAgentStates exports gen3_agent_connected, 1 for every agent
+// states reports connected and 0 for the others.
+func RegisterAgentStates(states func() map[string]bool) {
+	prometheus.MustRegister(&agentStates{
+		desc: prometheus.NewDesc("gen3_agent_connected",
+			"Whether the agent has a tunnel to this server.", []string{"agent"}, nil),
+		states: states,
+	})
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -122,6 +122,27 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// -------------------------
+		// Calls between API replicas
+		// -------------------------
+
+		// The replica token only opens /internal routes, it never stands in
+		// for a user elsewhere
+
+		if strings.HasPrefix(url, "/internal/") {
+			if InternalRequest == nil || !InternalRequest(c.Request) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "replica token required"})
+				c.Abort()
+				return
+			}
+			c.Set("userInfo", map[string]interface{}{
+				"username": "replica",
+				"roles":    []string{ReplicaRole},
+			})
+			c.Next()
+			return
+		}
+
 		// -------------------------
 		// Extract token
 		// -------------------------
@@ -269,6 +290,8 @@ func AuthMiddleware() gin.HandlerFunc {
 				}
 			}
 
+			accessibleAgents = append(accessibleAgents, agentsVisibleByLabel(parseLabelGrants(roleMap))...)
+
 			c.Set("visibleAgents", accessibleAgents)
 
 			c.Next()
@@ -283,6 +306,8 @@ func AuthMiddleware() gin.HandlerFunc {
 			"/api/aws",
 			"/api/terraform",
 			"/api/runner",
+			"/api/ca",
+			"/api/upgrades",
 		}
 
 		for _, prefix := range superAdminPrefixes {
@@ -329,10 +354,13 @@ func AuthMiddleware() gin.HandlerFunc {
 
 			readRole := agent + "-read"
 			writeRole := agent + "-write"
+			labelRead, labelWrite := labelAccess(parseLabelGrants(roleMap), agentLabels(agent))
+			canWrite := roleMap[writeRole] || labelWrite || roleMap["superadmin"]
+			c.Set(AgentWriteKey, canWrite)
 
 			if method == http.MethodGet {
 
-				if !(roleMap[readRole] || roleMap[writeRole] || roleMap["superadmin"]) {
+				if !(roleMap[readRole] || roleMap[writeRole] || labelRead || roleMap["superadmin"]) {
 					c.JSON(http.StatusForbidden, gin.H{"error": "Read permission required"})
 					c.Abort()
 					return
@@ -343,7 +371,7 @@ func AuthMiddleware() gin.HandlerFunc {
 				method == http.MethodPatch ||
 				method == http.MethodDelete {
 
-				if !(roleMap[writeRole] || roleMap["superadmin"]) {
+				if !canWrite {
 					c.JSON(http.StatusForbidden, gin.H{"error": "Write permission required"})
 					c.Abort()
 					return
@@ -0,0 +1,63 @@
+package keycloak
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthMiddlewareReplicaToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := InternalRequest
+	defer func() { InternalRequest = previous }()
+	replicaToken := func(r *http.Request) bool { return r.Header.Get("X-Replica") == "secret" }
+
+	tests := []struct {
+		name      string
+		internal  func(r *http.Request) bool
+		path      string
+		token     string
+		wantCode  int
+		wantRoles []string
+	}{
+		{"internal route with the token", replicaToken, "/internal/agents/agent1/upgrade", "secret", http.StatusOK, []string{ReplicaRole}},
+		{"internal route with a wrong token", replicaToken, "/internal/agents/agent1/upgrade", "guess", http.StatusForbidden, nil},
+		{"internal route without a token", replicaToken, "/internal/agents/agent1/upgrade", "", http.StatusForbidden, nil},
+		{"internal route without replica routing", nil, "/internal/agents/agent1/upgrade", "secret", http.StatusForbidden, nil},
+		{"user route with the token", replicaToken, "/api/agents/agent1", "secret", http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InternalRequest = tt.internal
+
+			var roles []string
+			r := gin.New()
+			r.Use(AuthMiddleware())
+			r.Any("/*path", func(c *gin.Context) {
+				userInfo := c.MustGet("userInfo").(map[string]interface{})
+				roles = userInfo["roles"].([]string)
+				if _, ok := c.Get("visibleAgents"); ok {
+					t.Error("replica request can see agents")
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("X-Replica", tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !slices.Equal(roles, tt.wantRoles) {
+				t.Errorf("got roles %q, want %q", roles, tt.wantRoles)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package keycloak
+
+import (
+	"net/http"
+	"strings"
+)
+
+// labelRolePrefix marks roles that grant access by agent label instead of by
+// agent name, e.g. "label:environment=prod:read" or "label:team=data:write".
+// The access follows a colon, which label keys and values can't contain.
+const labelRolePrefix = "label:"
+
+// AgentLabels returns the labels of one agent, AllAgentLabels those of every
+// known agent keyed by agent name. The server sets both at startup, the
+// middleware can't import the server package.
+var (
+	AgentLabels    func(agent string) map[string]string
+	AllAgentLabels func() map[string]map[string]string
+)
+
+// InternalRequest reports whether a request was made by another API replica.
+// Set by the server like AgentLabels.
+var InternalRequest func(r *http.Request) bool
+
+// ReplicaRole is the only role of requests another replica makes to the
+// /internal routes, the one place the replica token is accepted.
+const ReplicaRole = "replica"
+
+// AgentWriteKey is set on agent routes to whether the user may change the
+// agent. Some GET routes change it too and check it themselves.
+const AgentWriteKey = "agentWrite"
+
+type labelGrant struct {
+	key   string
+	value string
+	write bool
+}
+
+func parseLabelGrants(roleMap map[string]bool) []labelGrant {
+	grants := []labelGrant{}
+	for role := range roleMap {
+		if !strings.HasPrefix(role, labelRolePrefix) {
+			continue
+		}
+		selector, access, ok := strings.Cut(strings.TrimPrefix(role, labelRolePrefix), ":")
+		if !ok || (access != "read" && access != "write") {
+			continue
+		}
+		write := access == "write"
+
+		key, value, ok := strings.Cut(selector, "=")
+		if !ok || key == "" {
+			continue
+		}
+		grants = append(grants, labelGrant{key: key, value: value, write: write})
+	}
+	return grants
+}
+
+// labelAccess reports the access the label grants give on an agent with the given labels.
+func labelAccess(grants []labelGrant, labels map[string]string) (read bool, write bool) {
+	for _, g := range grants {
+		if v, ok := labels[g.key]; ok && v == g.value {
+			read = true
+			if g.write {
+				write = true
+			}
+		}
+	}
+	return read, write
+}
+
+func agentLabels(agent string) map[string]string {
+	if AgentLabels == nil {
+		return nil
+	}
+	return AgentLabels(agent)
+}
+
+// agentsVisibleByLabel returns the agents at least one label grant gives read access to.
+func agentsVisibleByLabel(grants []labelGrant) []string {
+	if len(grants) == 0 || AllAgentLabels == nil {
+		return nil
+	}
+	visible := []string{}
+	for agent, labels := range AllAgentLabels() {
+		if read, _ := labelAccess(grants, labels); read {
+			visible = append(visible, agent)
+		}
+	}
+	return visible
+}
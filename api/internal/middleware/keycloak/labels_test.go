@@ -0,0 +1,115 @@
+package keycloak
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestParseLabelGrants(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		want  []labelGrant
+	}{
+		{
+			name:  "read and write",
+			roles: []string{"label:environment=prod:read", "label:team=data:write"},
+			want: []labelGrant{
+				{key: "environment", value: "prod"},
+				{key: "team", value: "data", write: true},
+			},
+		},
+		{
+			name:  "values ending like the old suffixes",
+			roles: []string{"label:tier=pre-read:write", "label:tier=copy-on-write:read"},
+			want: []labelGrant{
+				{key: "tier", value: "copy-on-write"},
+				{key: "tier", value: "pre-read", write: true},
+			},
+		},
+		{
+			name:  "prefixed key and empty value",
+			roles: []string{"label:gen3.org/region=:read"},
+			want:  []labelGrant{{key: "gen3.org/region", value: ""}},
+		},
+		{
+			name: "ignored roles",
+			roles: []string{
+				"superadmin",
+				"agent1-read",
+				"label:environment=prod-read",
+				"label:environment=prod:admin",
+				"label:environment=prod",
+				"label:=prod:read",
+				"label:environment:read",
+			},
+			want: []labelGrant{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roleMap := map[string]bool{}
+			for _, role := range tt.roles {
+				roleMap[role] = true
+			}
+			got := parseLabelGrants(roleMap)
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].key != got[j].key {
+					return got[i].key < got[j].key
+				}
+				return got[i].value < got[j].value
+			})
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseLabelGrants(%v) = %+v, want %+v", tt.roles, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelAccess(t *testing.T) {
+	grants := []labelGrant{
+		{key: "environment", value: "prod"},
+		{key: "team", value: "data", write: true},
+	}
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		wantRead  bool
+		wantWrite bool
+	}{
+		{"read grant", map[string]string{"environment": "prod"}, true, false},
+		{"write grant", map[string]string{"team": "data"}, true, true},
+		{"both", map[string]string{"environment": "prod", "team": "data"}, true, true},
+		{"other value", map[string]string{"environment": "staging", "team": "web"}, false, false},
+		{"no labels", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, write := labelAccess(grants, tt.labels)
+			if read != tt.wantRead || write != tt.wantWrite {
+				t.Errorf("labelAccess(%v) = %v, %v, want %v, %v", tt.labels, read, write, tt.wantRead, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestAgentsVisibleByLabel(t *testing.T) {
+	defer func(previous func() map[string]map[string]string) { AllAgentLabels = previous }(AllAgentLabels)
+	AllAgentLabels = func() map[string]map[string]string {
+		return map[string]map[string]string{
+			"prod-east": {"environment": "prod"},
+			"prod-west": {"environment": "prod"},
+			"staging":   {"environment": "staging"},
+		}
+	}
+
+	got := agentsVisibleByLabel([]labelGrant{{key: "environment", value: "prod"}})
+	sort.Strings(got)
+	if want := []string{"prod-east", "prod-west"}; !slices.Equal(got, want) {
+		t.Errorf("agentsVisibleByLabel = %v, want %v", got, want)
+	}
+	if got := agentsVisibleByLabel(nil); got != nil {
+		t.Errorf("agentsVisibleByLabel without grants = %v, want nil", got)
+	}
+}
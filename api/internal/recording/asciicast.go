@@ -0,0 +1,168 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Default terminal size written to the header when the client did not send one
+const (
+	DefaultWidth  = 80
+	DefaultHeight = 24
+)
+
+// Event types of an asciicast v2 recording
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// ErrClosed is returned when writing to a closed Recorder
+var ErrClosed = errors.New("recording is closed")
+
+// Header is the first line of an asciicast v2 recording, see
+// https://docs.asciinema.org/manual/asciicast/v2/
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a terminal session as asciicast v2, one event per line.
+// Events are written as they come so an unfinished recording can be played.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	start  time.Time
+	size   int64
+	closed bool
+	// pending holds a UTF-8 sequence split across two output chunks
+	pending []byte
+}
+
+// NewRecorder writes header to w and returns a Recorder appending events to
+// it. The Recorder owns w and closes it on Close.
+func NewRecorder(w io.WriteCloser, header Header) (*Recorder, error) {
+	start := time.Now()
+	header.Version = 2
+	if header.Width <= 0 {
+		header.Width = DefaultWidth
+	}
+	if header.Height <= 0 {
+		header.Height = DefaultHeight
+	}
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+
+	r := &Recorder{w: w, start: start}
+	if err := r.writeLine(header); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Output records data the terminal printed.
+func (r *Recorder) Output(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	data = append(r.pending, data...)
+	n := completeUTF8(data)
+	r.pending = append([]byte(nil), data[n:]...)
+	if n == 0 {
+		return nil
+	}
+	return r.event(EventOutput, string(data[:n]))
+}
+
+// Input records data typed into the terminal.
+func (r *Recorder) Input(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	return r.event(EventInput, string(data))
+}
+
+// Resize records a terminal size change.
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	return r.event(EventResize, strconv.Itoa(width)+"x"+strconv.Itoa(height))
+}
+
+// Size is how many bytes were written so far.
+func (r *Recorder) Size() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size
+}
+
+// Close writes out any held back output and closes the underlying writer.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var err error
+	if len(r.pending) > 0 {
+		err = r.event(EventOutput, string(r.pending))
+		r.pending = nil
+	}
+	if cerr := r.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *Recorder) event(kind, data string) error {
+	elapsed := time.Since(r.start).Seconds()
+	// asciinema writes microsecond precision
+	elapsed = float64(int64(elapsed*1e6)) / 1e6
+	return r.writeLine([]any{elapsed, kind, data})
+}
+
+func (r *Recorder) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := r.w.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+// completeUTF8 is the length of data without a trailing, incomplete UTF-8
+// sequence. Invalid bytes are not held back.
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			return i
+		}
+		break
+	}
+	return len(data)
+}
@@ -0,0 +1,108 @@
+package routing
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+// Replica is an API server process and the address other replicas reach its HTTP server on.
+type Replica struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+// Location is where an agent's tunnel stream currently terminates.
+type Location struct {
+	Agent     string    `json:"agent"`
+	Replica   Replica   `json:"replica"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Registry is the agent-location registry shared by all API replicas.
+type Registry interface {
+	// Set records replica as holding the agent's stream, refreshing UpdatedAt.
+	Set(agent string, replica Replica) error
+	// Remove forgets the location only if replicaID still holds the agent.
+	Remove(agent string, replicaID string) error
+	// Get returns nil without an error when the agent has no location.
+	Get(agent string) (*Location, error)
+}
+
+// MemoryRegistry keeps locations in process memory. It is the default for a
+// single replica, and lets several replicas in one process share a registry
+// in tests.
+type MemoryRegistry struct {
+	mu        sync.RWMutex
+	locations map[string]Location
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{locations: map[string]Location{}}
+}
+
+func (m *MemoryRegistry) Set(agent string, replica Replica) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locations[agent] = Location{Agent: agent, Replica: replica, UpdatedAt: time.Now()}
+	return nil
+}
+
+func (m *MemoryRegistry) Remove(agent string, replicaID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if loc, ok := m.locations[agent]; ok && loc.Replica.ID == replicaID {
+		delete(m.locations, agent)
+	}
+	return nil
+}
+
+func (m *MemoryRegistry) Get(agent string) (*Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loc, ok := m.locations[agent]
+	if !ok {
+		return nil, nil
+	}
+	return &loc, nil
+}
+
+// StoreRegistry keeps locations in the agent store, replicas sharing the
+// store database share the registry.
+type StoreRegistry struct {
+	store store.Store
+}
+
+func NewStoreRegistry(s store.Store) *StoreRegistry {
+	return &StoreRegistry{store: s}
+}
+
+func (s *StoreRegistry) Set(agent string, replica Replica) error {
+	return s.store.SaveAgentLocation(store.AgentLocation{
+		Agent:     agent,
+		Replica:   replica.ID,
+		Address:   replica.Address,
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (s *StoreRegistry) Remove(agent string, replicaID string) error {
+	return s.store.DeleteAgentLocation(agent, replicaID)
+}
+
+func (s *StoreRegistry) Get(agent string) (*Location, error) {
+	loc, err := s.store.GetAgentLocation(agent)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Location{
+		Agent:     loc.Agent,
+		Replica:   Replica{ID: loc.Replica, Address: loc.Address},
+		UpdatedAt: loc.UpdatedAt,
+	}, nil
+}
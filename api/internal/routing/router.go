@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ForwardedHeader marks a request another replica already routed, it is
+	// never forwarded again.
+	ForwardedHeader = "X-Gen3-Forwarded-By"
+	// ReplicaTokenHeader authenticates requests made by a replica on its own behalf.
+	ReplicaTokenHeader = "X-Gen3-Replica-Token"
+)
+
+// Router sends requests for an agent to the replica holding its stream.
+type Router struct {
+	Self     Replica
+	Registry Registry
+	// TTL is how long a location is trusted without its holder refreshing it
+	TTL time.Duration
+	// Secret is shared by all replicas, internal calls are refused when empty
+	Secret string
+
+	client *http.Client
+}
+
+func NewRouter(self Replica, registry Registry, ttl time.Duration, secret string) *Router {
+	return &Router{
+		Self:     self,
+		Registry: registry,
+		TTL:      ttl,
+		Secret:   secret,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Claim records this replica as holding the agent's stream.
+func (r *Router) Claim(agent string) error {
+	return r.Registry.Set(agent, r.Self)
+}
+
+// Release forgets the agent's location if this replica still holds it.
+func (r *Router) Release(agent string) error {
+	return r.Registry.Remove(agent, r.Self.ID)
+}
+
+// Owner returns the replica holding the agent's stream, or nil when it is
+// this one or no live replica holds it.
+func (r *Router) Owner(agent string) (*Replica, error) {
+	loc, err := r.Registry.Get(agent)
+	if err != nil || loc == nil {
+		return nil, err
+	}
+	if loc.Replica.ID == r.Self.ID || time.Since(loc.UpdatedAt) > r.TTL {
+		return nil, nil
+	}
+	return &loc.Replica, nil
+}
+
+// Internal reports whether the request carries the replica token.
+func (r *Router) Internal(req *http.Request) bool {
+	token := req.Header.Get(ReplicaTokenHeader)
+	if r.Secret == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.Secret)) == 1
+}
+
+// Middleware forwards requests for agents held by another replica. isLocal
+// reports whether this replica holds the agent's stream.
+func (r *Router) Middleware(isLocal func(agent string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agent := c.Param("agent")
+		if agent == "" || c.GetHeader(ForwardedHeader) != "" || isLocal(agent) {
+			c.Next()
+			return
+		}
+
+		owner, err := r.Owner(agent)
+		if err != nil {
+			// Let the local handler answer, it knows the agent isn't connected here
+			log.Error().Err(err).Str("agent", agent).Msg("Failed to look up agent location")
+			c.Next()
+			return
+		}
+		if owner == nil {
+			c.Next()
+			return
+		}
+
+		r.Forward(c, *owner)
+		c.Abort()
+	}
+}
+
+// Forward proxies the request as is to another replica, including upgraded
+// WebSocket connections.
+func (r *Router) Forward(c *gin.Context, to Replica) {
+	target, err := url.Parse(to.Address)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("invalid address for replica %s", to.ID)})
+		return
+	}
+
+	log.Debug().Str("replica", to.ID).Str("path", c.Request.URL.Path).Msg("Forwarding request to replica")
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Header.Set(ForwardedHeader, r.Self.ID)
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		log.Error().Err(err).Str("replica", to.ID).Msg("Error forwarding request to replica")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(gin.H{"error": fmt.Sprintf("replica %s holding the agent is unreachable", to.ID)})
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// Call makes an internal JSON request to another replica.
+func (r *Router) Call(ctx context.Context, to Replica, method, path string, body interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %v", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, to.Address+path, payload)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ReplicaTokenHeader, r.Secret)
+	req.Header.Set(ForwardedHeader, r.Self.ID)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling replica %s: %v", to.ID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("replica %s returned %d: %s", to.ID, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package routing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testReplica is a router in front of its own HTTP server, the agents in
+// local are the ones whose streams it holds.
+type testReplica struct {
+	router *Router
+	local  map[string]bool
+}
+
+func newTestReplica(t *testing.T, id string, registry Registry, secret string, local ...string) *testReplica {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ts := httptest.NewUnstartedServer(nil)
+	replica := &testReplica{
+		router: NewRouter(Replica{ID: id, Address: "http://" + ts.Listener.Addr().String()}, registry, time.Minute, secret),
+		local:  map[string]bool{},
+	}
+	for _, agent := range local {
+		replica.local[agent] = true
+	}
+
+	r := gin.New()
+	r.POST("/internal/ping", func(c *gin.Context) {
+		if !replica.router.Internal(c.Request) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	agents := r.Group("/agents/:agent")
+	agents.Use(replica.router.Middleware(func(agent string) bool { return replica.local[agent] }))
+	agents.GET("", func(c *gin.Context) {
+		c.String(http.StatusOK, id)
+	})
+	ts.Config.Handler = r
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return replica
+}
+
+func get(t *testing.T, replica *testReplica, path string, forwardedBy string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, replica.router.Self.Address+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwardedBy != "" {
+		req.Header.Set(ForwardedHeader, forwardedBy)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestRouterForwardsToOwner(t *testing.T) {
+	registry := NewMemoryRegistry()
+	r1 := newTestReplica(t, "r1", registry, "secret")
+	r2 := newTestReplica(t, "r2", registry, "secret", "agent1")
+	if err := r2.router.Claim("agent1"); err != nil {
+		t.Fatal(err)
+	}
+
+	owner, err := r1.router.Owner("agent1")
+	if err != nil || owner == nil || owner.ID != "r2" {
+		t.Fatalf("Owner from r1 = %+v, %v, want r2", owner, err)
+	}
+	if owner, _ := r2.router.Owner("agent1"); owner != nil {
+		t.Errorf("Owner from r2 = %+v, want nil for the replica holding it", owner)
+	}
+
+	if code, body := get(t, r1, "/agents/agent1", ""); code != http.StatusOK || body != "r2" {
+		t.Errorf("request to r1 got %d %q, want it served by r2", code, body)
+	}
+	if code, body := get(t, r1, "/agents/agent2", ""); code != http.StatusOK || body != "r1" {
+		t.Errorf("request for an agent without a location got %d %q, want it served by r1", code, body)
+	}
+
+	// Once r2 lets go of the agent, r1 answers for it again
+	if err := r1.router.Release("agent1"); err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := r1.router.Owner("agent1"); owner == nil {
+		t.Error("r1 released a location held by r2")
+	}
+	if err := r2.router.Release("agent1"); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := get(t, r1, "/agents/agent1", ""); code != http.StatusOK || body != "r1" {
+		t.Errorf("request after release got %d %q, want it served by r1", code, body)
+	}
+}
+
+func TestRouterOwnerExpires(t *testing.T) {
+	registry := NewMemoryRegistry()
+	r1 := newTestReplica(t, "r1", registry, "secret")
+	r2 := newTestReplica(t, "r2", registry, "secret", "agent1")
+	if err := r2.router.Claim("agent1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// r2 died without refreshing its claim
+	registry.mu.Lock()
+	loc := registry.locations["agent1"]
+	loc.UpdatedAt = time.Now().Add(-r1.router.TTL - time.Second)
+	registry.locations["agent1"] = loc
+	registry.mu.Unlock()
+
+	if owner, err := r1.router.Owner("agent1"); err != nil || owner != nil {
+		t.Errorf("Owner of an expired location = %+v, %v, want nil", owner, err)
+	}
+	if code, body := get(t, r1, "/agents/agent1", ""); code != http.StatusOK || body != "r1" {
+		t.Errorf("request got %d %q, want it served by r1", code, body)
+	}
+
+	// Refreshing the claim makes r2 the owner again
+	if err := r2.router.Claim("agent1"); err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := r1.router.Owner("agent1"); owner == nil || owner.ID != "r2" {
+		t.Errorf("Owner after refresh = %+v, want r2", owner)
+	}
+}
+
+func TestRouterForwardsOnce(t *testing.T) {
+	registry := NewMemoryRegistry()
+	r1 := newTestReplica(t, "r1", registry, "secret")
+	r2 := newTestReplica(t, "r2", registry, "secret")
+	// The registry is stale: it points at r1, which forwarded the request to r2
+	if err := r1.router.Claim("agent1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, body := get(t, r2, "/agents/agent1", "r1"); code != http.StatusOK || body != "r2" {
+		t.Errorf("forwarded request got %d %q, want r2 to answer it instead of forwarding it back", code, body)
+	}
+	if code, body := get(t, r2, "/agents/agent1", ""); code != http.StatusOK || body != "r1" {
+		t.Errorf("request got %d %q, want it forwarded to r1", code, body)
+	}
+}
+
+func TestRouterInternal(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		want   bool
+	}{
+		{"matching token", "secret", "secret", true},
+		{"mismatched token", "secret", "guess", false},
+		{"no token", "secret", "", false},
+		{"no secret", "", "", false},
+		{"no secret with a token", "", "guess", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter(Replica{ID: "r1"}, NewMemoryRegistry(), time.Minute, tt.secret)
+			req := httptest.NewRequest(http.MethodPost, "/internal/ping", nil)
+			if tt.token != "" {
+				req.Header.Set(ReplicaTokenHeader, tt.token)
+			}
+			if got := router.Internal(req); got != tt.want {
+				t.Errorf("Internal = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterCall(t *testing.T) {
+	tests := []struct {
+		name         string
+		callerSecret string
+		calleeSecret string
+		wantErr      bool
+	}{
+		{"shared secret", "secret", "secret", false},
+		{"mismatched secret", "guess", "secret", true},
+		{"caller without a secret", "", "secret", true},
+		{"callee without a secret", "secret", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewMemoryRegistry()
+			r1 := newTestReplica(t, "r1", registry, tt.callerSecret)
+			r2 := newTestReplica(t, "r2", registry, tt.calleeSecret)
+
+			err := r1.router.Call(context.Background(), r2.router.Self, http.MethodPost, "/internal/ping", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Call error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
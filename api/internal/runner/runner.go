@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
 )
 
 type CommandRequest struct {
@@ -202,6 +206,15 @@ func Runner(c *gin.Context) {
 }
 
 func ExecuteCommand(ex *Execution) {
+	metrics.RunnerExecutionsRunning.Inc()
+	defer func() {
+		metrics.RunnerExecutionsRunning.Dec()
+		ex.Mu.Lock()
+		status := ex.Status
+		ex.Mu.Unlock()
+		metrics.RunnerExecutions.WithLabelValues(string(status)).Inc()
+	}()
+
 	// ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -264,6 +277,7 @@ func HandleExecute(store *ExecutionStore) gin.HandlerFunc {
 
 		// Create new execution
 		execID := uuid.New().String()
+		audit.Describe(c, "execution "+execID, strings.TrimSpace(cmdReq.Command+" "+strings.Join(cmdReq.Args, " ")))
 		execution := &Execution{
 			ID:        execID,
 			Command:   cmdReq.Command,
@@ -2,17 +2,13 @@ package server
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"html/template"
-	"math/big"
 	"net/http"
 	"os"
 	"path"
@@ -23,37 +19,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 	"github.com/uc-cdis/gen3-admin/internal/ca"
 	"github.com/uc-cdis/gen3-admin/internal/k8s"
-	"github.com/uc-cdis/gen3-admin/internal/utils"
+	"github.com/uc-cdis/gen3-admin/internal/store"
 )
 
-type Agent struct {
-	Id              string    `json:"id"`
-	Name            string    `json:"name"`
-	Certificate     string    `json:"certificate"`
-	Metadata        Metadata  `json:"metadata"`
-	PrivateKey      string    `json:"private_key"`
-	Connected       bool      `json:"connected"`
-	LastSeen        time.Time `json:"lastSeen"`
-	CpuUsage        float64   `json:"cpuUsage"`
-	MemoryUsage     float64   `json:"memoryUsage"`
-	Provider        string    `json:"provider"`
-	K8sVersion      string    `json:"k8sVersion"`
-	PodCapacity     int       `json:"podCapacity"`
-	PodCount        int       `json:"podCount"`
-	RoleARN         string    `json:"rolearn"`
-	EKS             bool      `json:"eks"`
-	AssumeMethod    string    `json:"assumemethod"`
-	AccessKey       string    `json:"accesskey"`
-	SecretAccessKey string    `json:"secretaccesskey"`
-}
-
-type Metadata struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-}
+// Agent and Metadata are persisted by the agent registry, see internal/store.
+type Agent = store.Agent
+type Metadata = store.Metadata
 
 type ServiceAccountData struct {
 	EKS             bool
@@ -63,65 +39,41 @@ type ServiceAccountData struct {
 	SecretAccessKey string
 }
 
-func generateAgentConfig(agentName string, roleArn string, eks bool, assumeMethod string, accessKey string, secretAccessKey string) (string, error) {
-	caCert, caKey, err := ca.LoadOrCreateCA()
-	if err != nil {
-		return "", fmt.Errorf("error loading/creating CA: %v", err)
+// generateAgentConfig registers the agent and returns the manifest to deploy it.
+// The manifest only carries the CA and a one-time join token, the agent creates
+// its own key and enrolls with a CSR so the private key never leaves the cluster.
+func generateAgentConfig(agentName string, agentLabels map[string]string, createdBy string, tokenTTL time.Duration, roleArn string, eks bool, assumeMethod string, accessKey string, secretAccessKey string) (string, error) {
+	if !validAgentName.MatchString(agentName) {
+		return "", fmt.Errorf("invalid agent name: %q", agentName)
 	}
-
-	agentPrivKey, err := ecdsa.GenerateKey(CertCurve, rand.Reader)
-	if err != nil {
-		return "", fmt.Errorf("error generating agent key: %v", err)
+	if err := validateLabels(agentLabels); err != nil {
+		return "", err
 	}
 
-	id := uuid.New().String()
-
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	caCertPem, err := ca.CACertificatePEM()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate serial number:%v", err)
+		return "", fmt.Errorf("error loading/creating CA: %v", err)
 	}
 
-	subjectAlternativeNames := []string{agentName, "csoc.gen3.org", "localhost"}
-
-	agentCertTemplate := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject:      pkix.Name{CommonName: agentName, SerialNumber: id},
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(1, 0, 0),
-		DNSNames:     subjectAlternativeNames,
-		KeyUsage:     x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	agent := Agent{
+		Name:         agentName,
+		Id:           uuid.New().String(),
+		Metadata:     Metadata{Labels: agentLabels},
+		Connected:    false,
+		RoleARN:      roleArn,
+		EKS:          eks,
+		AssumeMethod: assumeMethod,
+		CreatedAt:    time.Now(),
 	}
 
-	agentCertBytes, err := x509.CreateCertificate(rand.Reader, agentCertTemplate, caCert, &agentPrivKey.PublicKey, caKey)
-	if err != nil {
-		return "", fmt.Errorf("error creating agent certificate: %v", err)
-	}
+	agentsMutex.Lock()
+	AgentConnections[agentName] = newAgentConnection(agent)
+	agentsMutex.Unlock()
+	persistAgent(agent)
 
-	agentKeyBytes, err := x509.MarshalECPrivateKey(agentPrivKey)
+	joinToken, err := mintJoinToken(agentName, createdBy, tokenTTL)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling agent key: %v", err)
-	}
-
-	agentCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: agentCertBytes})
-	agentKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: agentKeyBytes})
-	caCertPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
-
-	utils.MustWriteFile("certs/"+agentName+".crt", []byte(agentCertPEM), 0644)
-	utils.MustWriteFile("certs/"+agentName+".key", []byte(agentKeyPEM), 0600)
-
-	log.Debug().Msgf("Certificate and key for agent %s dumped to file", agentName)
-
-	AgentConnections[agentName] = &AgentConnection{
-		stream: nil,
-		agent: Agent{
-			Name:        agentName,
-			Id:          id,
-			Certificate: string(agentCertPEM),
-			Connected: false,
-			RoleARN:   roleArn,
-		},
+		return "", err
 	}
 
 	config := fmt.Sprintf(`
@@ -132,13 +84,10 @@ metadata:
   name: csoc-tls
 type: opaque
 data:
-  %s.crt: %s
-  %s.key: %s
+  %s: %s
   ca.crt: %s`,
-		agentName,
-		base64.StdEncoding.EncodeToString(agentCertPEM),
-		agentName,
-		base64.StdEncoding.EncodeToString(agentKeyPEM),
+		joinTokenKey,
+		base64.StdEncoding.EncodeToString([]byte(joinToken)),
 		base64.StdEncoding.EncodeToString(caCertPem),
 	)
 
@@ -255,12 +204,14 @@ func CreateAgentHandler(c *gin.Context) {
 	r := c.Request
 	w := c.Writer
 	var requestData struct {
-		Name            string `json:"name"`
-		RoleARN         string `json:"rolearn"`
-		EKS             bool   `json:"eks"`
-		AssumeMethod    string `json:"assumemethod"`
-		AccessKey       string `json:"accesskey"`
-		SecretAccessKey string `json:"secretaccesskey"`
+		Name            string            `json:"name"`
+		RoleARN         string            `json:"rolearn"`
+		EKS             bool              `json:"eks"`
+		AssumeMethod    string            `json:"assumemethod"`
+		AccessKey       string            `json:"accesskey"`
+		SecretAccessKey string            `json:"secretaccesskey"`
+		TokenTTL        string            `json:"tokenTTL"`
+		Labels          map[string]string `json:"labels"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
@@ -269,7 +220,16 @@ func CreateAgentHandler(c *gin.Context) {
 		return
 	}
 
-	config, err := generateAgentConfig(requestData.Name, requestData.RoleARN, requestData.EKS, requestData.AssumeMethod, requestData.AccessKey, requestData.SecretAccessKey)
+	tokenTTL := defaultJoinTokenTTL
+	if requestData.TokenTTL != "" {
+		tokenTTL, err = time.ParseDuration(requestData.TokenTTL)
+		if err != nil || tokenTTL <= 0 {
+			http.Error(w, "Invalid tokenTTL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	config, err := generateAgentConfig(requestData.Name, requestData.Labels, currentUser(c), tokenTTL, requestData.RoleARN, requestData.EKS, requestData.AssumeMethod, requestData.AccessKey, requestData.SecretAccessKey)
 	if err != nil {
 		log.Error().Err(err).Msg("Error generating agent config")
 		http.Error(w, "Error generating agent config: "+err.Error(), http.StatusInternalServerError)
@@ -284,7 +244,8 @@ func CreateLocalAgentHandler(c *gin.Context) {
 	log.Info().Msg("CreateLocalAgentHandler - creating and deploying agent to local cluster")
 
 	var requestData struct {
-		Name string `json:"name"`
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
 	}
 	if err := c.ShouldBindJSON(&requestData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
@@ -297,6 +258,9 @@ func CreateLocalAgentHandler(c *gin.Context) {
 
 	yamlManifest, err := generateAgentConfig(
 		requestData.Name,
+		requestData.Labels,
+		currentUser(c),
+		defaultJoinTokenTTL,
 		"",
 		false,
 		"",
@@ -379,6 +343,12 @@ func GetAgentsHandler(c *gin.Context) {
 
 	visibleAgents := visibleAgentsRaw.([]string)
 
+	selector, err := labels.Parse(c.Query("labelSelector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid labelSelector: " + err.Error()})
+		return
+	}
+
 	isSuperAdmin := false
 	allowedAgents := map[string]bool{}
 
@@ -399,6 +369,9 @@ func GetAgentsHandler(c *gin.Context) {
 		if !isSuperAdmin && !allowedAgents[name] {
 			continue
 		}
+		if !selector.Matches(labels.Set(agent.agent.Metadata.Labels)) {
+			continue
+		}
 
 		agent.agent.Name = name
 		agent.agent.Metadata.Name = name
@@ -417,56 +390,31 @@ func GetAgentsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, returnAgents)
 }
 
-func deleteAgent(agentName string) error {
-	if agentName == "" {
-		log.Error().Msg("deleteAgentHandler: agent_name is empty")
-		return errors.New("missing agent_name parameter")
-	}
-
+// removeAgent drops a revoked agent from memory, the registry and the certs
+// directory, where InitializeAgentsFromCerts would import it again from.
+func removeAgent(agentName string) {
 	agentsMutex.Lock()
-	defer agentsMutex.Unlock()
-
-	agent, exists := AgentConnections[agentName]
-	if !exists {
-		log.Error().Msg("Agent not found")
-		return fmt.Errorf("agent not found")
-	}
-
-	os.Remove(filepath.Join("certs", path.Clean(agent.agent.Name+".crt")))
-	os.Remove(filepath.Join("certs", path.Clean(agent.agent.Name+".key")))
-
 	delete(AgentConnections, agentName)
+	agentsMutex.Unlock()
 
-	log.Info().Str("agent", agentName).Msg("Agent deleted and certificate revoked")
-	return nil
+	for _, ext := range []string{".crt", ".key"} {
+		if err := os.Remove(filepath.Join("certs", path.Clean(agentName+ext))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("agent", agentName).Msg("Failed to remove agent certificate file")
+		}
+	}
+	forgetAgent(agentName)
 }
 
 func DeleteAgentHandler(c *gin.Context) {
-	userInfoInterface, exists := c.Get("userInfo")
-	if !exists {
+	if _, exists := c.Get("userInfo"); !exists {
 		log.Error().Msg("User info missing")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	userInfo := userInfoInterface.(map[string]interface{})
-	isSuperAdmin := false
-
-	switch r := userInfo["roles"].(type) {
-	case map[string]bool:
-		isSuperAdmin = r["superadmin"]
-	case []string:
-		for _, role := range r {
-			if role == "superadmin" {
-				isSuperAdmin = true
-				break
-			}
-		}
-	}
-
-	if !isSuperAdmin {
+	if !isSuperAdmin(c) {
 		log.Warn().
-			Str("user", fmt.Sprintf("%v", userInfo["username"])).
+			Str("user", currentUser(c)).
 			Msg("Unauthorized attempt to delete agent")
 
 		c.JSON(http.StatusForbidden, gin.H{
@@ -477,18 +425,21 @@ func DeleteAgentHandler(c *gin.Context) {
 
 	agentName := c.Param("agent")
 
-	agentsMutex.Lock()
-	defer agentsMutex.Unlock()
-
-	if _, exists := AgentConnections[agentName]; !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+	// Revoke first so the deleted agent's certificate can't be used to reconnect
+	if err := revokeAgent(agentName, "agent deleted by "+currentUser(c)); err != nil {
+		if errors.Is(err, errAgentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+			return
+		}
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to revoke agent certificate")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	delete(AgentConnections, agentName)
+	removeAgent(agentName)
 
 	log.Warn().
-		Str("user", fmt.Sprintf("%v", userInfo["username"])).
+		Str("user", currentUser(c)).
 		Str("agent", agentName).
 		Msg("Agent deleted")
 
@@ -500,10 +451,20 @@ func DeleteAgentHandler(c *gin.Context) {
 
 // RegisterAgentRoutes registers all agent CRUD routes
 func RegisterAgentRoutes(r *gin.Engine) {
-	r.POST("/api/agents", CreateAgentHandler)
-	r.POST("/api/agents/local", CreateLocalAgentHandler)
-	r.DELETE("/api/agents/:agent", DeleteAgentHandler)
+	r.POST("/api/agents", audit.Action("agent.create"), CreateAgentHandler)
+	r.POST("/api/agents/local", audit.Action("agent.create"), CreateLocalAgentHandler)
+	r.DELETE("/api/agents/:agent", audit.Action("agent.delete"), DeleteAgentHandler)
+	r.POST("/api/agents/:agent/revoke", audit.Action("agent.revoke"), RevokeAgentHandler)
+	r.PUT("/api/agents/:agent/labels", audit.Action("agent.labels"), UpdateAgentLabelsHandler)
+	r.GET("/api/agents/:agent/history", GetAgentHistoryHandler)
+	r.GET("/api/agents/:agent/status/history", GetAgentStatusHistoryHandler)
 	r.GET("/api/agents", GetAgentsHandler)
+	r.GET("/api/ca/revocations", ListRevocationsHandler)
+	r.GET("/api/ca/join-tokens", ListJoinTokensHandler)
+	r.POST("/api/upgrades", audit.Action("agent.upgrade"), CreateUpgradeHandler)
+	r.GET("/api/upgrades", ListUpgradesHandler)
+	r.GET("/api/upgrades/:id", GetUpgradeHandler)
+	r.POST("/internal/agents/:agent/upgrade", ReplicaStartUpgradeHandler)
 }
 
 func InitializeAgentsFromCerts() error {
@@ -555,18 +516,37 @@ func InitializeAgentsFromCerts() error {
 			continue
 		}
 
+		if ca.IsRevoked(cert.SerialNumber) {
+			log.Debug().Str("file", file.Name()).Msg("Skipping revoked agent cert file")
+			continue
+		}
+
 		agentName := strings.TrimSuffix(file.Name(), ".crt")
 		agentCert := string(certFile)
 
-		AgentConnections[agentName] = &AgentConnection{
-			stream: nil,
-			agent: Agent{
-				Id:          cert.Subject.SerialNumber,
-				Name:        agentName,
-				Certificate: agentCert,
-				Connected:   false,
-			},
+		// Agents already known to the registry keep their persisted state
+		agentsMutex.RLock()
+		_, known := AgentConnections[agentName]
+		agentsMutex.RUnlock()
+		if known {
+			continue
 		}
+
+		agent := Agent{
+			Id:            cert.Subject.SerialNumber,
+			Name:          agentName,
+			Certificate:   agentCert,
+			CertExpiresAt: cert.NotAfter,
+			Connected:     false,
+			CreatedAt:     cert.NotBefore,
+		}
+
+		agentsMutex.Lock()
+		AgentConnections[agentName] = newAgentConnection(agent)
+		agentsMutex.Unlock()
+
+		log.Info().Str("agent", agentName).Msg("Imported agent from certificate into registry")
+		persistAgent(agent)
 	}
 	return nil
 }
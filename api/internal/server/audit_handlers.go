@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// auditExportPage is how many entries an export reads from the store at once
+	auditExportPage = 500
+)
+
+// auditFilter reads the filters shared by the list and export endpoints:
+// user, agent, action, target (prefix), result, since and until (RFC 3339)
+// and before (an entry ID, for paging).
+func auditFilter(c *gin.Context) (store.AuditFilter, error) {
+	filter := store.AuditFilter{
+		User:   c.Query("user"),
+		Agent:  c.Query("agent"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+		Result: store.AuditResult(c.Query("result")),
+	}
+	switch filter.Result {
+	case "", store.AuditSuccess, store.AuditFailure:
+	default:
+		return filter, fmt.Errorf("invalid result %q, expected success or failure", filter.Result)
+	}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %v", param, err)
+			}
+			*t = parsed
+		}
+	}
+	if v := c.Query("before"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("invalid before")
+		}
+		filter.BeforeID = id
+	}
+	return filter, nil
+}
+
+func requireAuditAccess(c *gin.Context) bool {
+	if !isSuperAdmin(c) {
+		log.Warn().Str("user", currentUser(c)).Msg("Unauthorized attempt to read the audit log")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only superadmin can read the audit log"})
+		return false
+	}
+	if registry == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "agent registry is not initialized"})
+		return false
+	}
+	return true
+}
+
+// ListAuditHandler returns the newest matching audit entries. A full page
+// carries nextBefore to fetch the following one.
+func ListAuditHandler(c *gin.Context) {
+	if !requireAuditAccess(c) {
+		return
+	}
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Limit = defaultAuditLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit, expected 1 to %d", maxAuditLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := registry.ListAudit(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list audit entries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{"entries": entries}
+	if len(entries) == filter.Limit {
+		resp["nextBefore"] = entries[len(entries)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ExportAuditHandler streams every matching audit entry as JSON lines,
+// newest first.
+func ExportAuditHandler(c *gin.Context) {
+	if !requireAuditAccess(c) {
+		return
+	}
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Limit = auditExportPage
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102T150405Z")))
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	exported := 0
+	for {
+		entries, err := registry.ListAudit(filter)
+		if err != nil {
+			// The status is out already, a cut short export is all the client can see
+			log.Error().Err(err).Int("exported", exported).Msg("Failed to export audit entries")
+			return
+		}
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				log.Warn().Err(err).Int("exported", exported).Msg("Audit export aborted by client")
+				return
+			}
+			exported++
+		}
+		c.Writer.Flush()
+		if len(entries) < filter.Limit {
+			break
+		}
+		filter.BeforeID = entries[len(entries)-1].ID
+	}
+	log.Info().Str("user", currentUser(c)).Int("entries", exported).Msg("Exported audit log")
+}
+
+// RegisterAuditRoutes registers the audit log endpoints
+func RegisterAuditRoutes(r *gin.Engine) {
+	r.GET("/api/audit", ListAuditHandler)
+	r.GET("/api/audit/export", ExportAuditHandler)
+}
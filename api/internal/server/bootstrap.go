@@ -21,6 +21,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 	"github.com/uc-cdis/gen3-admin/internal/k8s"
 	"github.com/uc-cdis/gen3-admin/pkg/config"
 )
@@ -613,6 +614,7 @@ func ConfigMapHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
 		return
 	}
+	audit.Describe(c, "configmap "+req.Namespace+"/"+req.Name, "set key "+req.Key)
 	if req.Key == "" || req.Data == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "key and data are required"})
 		return
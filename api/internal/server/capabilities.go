@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// agentCapabilities returns what the agent advertised at registration,
+// falling back to the legacy set for agents that predate negotiation.
+func agentCapabilities(agent Agent) []string {
+	if len(agent.Capabilities) == 0 {
+		return pb.LegacyCapabilities
+	}
+	return agent.Capabilities
+}
+
+// requireCapability responds with 404 when the agent is unknown and 501 when
+// it doesn't support the capability. It returns whether the handler can go on.
+func requireCapability(c *gin.Context, agentName string, capability string) bool {
+	agentsMutex.RLock()
+	conn, exists := AgentConnections[agentName]
+	var record Agent
+	if exists {
+		record = conn.agent
+	}
+	agentsMutex.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return false
+	}
+
+	if !slices.Contains(agentCapabilities(record), capability) {
+		log.Warn().
+			Str("agent", agentName).
+			Str("version", record.Version).
+			Str("capability", capability).
+			Msg("Agent does not support requested capability")
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":      fmt.Sprintf("agent %s (version %s) does not support %s, upgrade the agent to use this feature", agentName, record.Version, capability),
+			"capability": capability,
+		})
+		return false
+	}
+	return true
+}
+
+// agentSupports reports whether the connected agent advertised capability,
+// for requests that can fall back to what older agents understand.
+func agentSupports(conn *AgentConnection, capability string) bool {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+	return slices.Contains(agentCapabilities(conn.agent), capability)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// tunnelCompression are the codecs agents may use, preferred first.
+// TUNNEL_COMPRESSION overrides it, "none" turns compression off.
+var tunnelCompression = pb.SupportedCompression
+
+func init() {
+	v := os.Getenv("TUNNEL_COMPRESSION")
+	if v == "" {
+		return
+	}
+	tunnelCompression = nil
+	if v == "none" {
+		return
+	}
+	for _, codec := range strings.Split(v, ",") {
+		codec = strings.TrimSpace(codec)
+		if !slices.Contains(pb.SupportedCompression, codec) {
+			log.Warn().Str("codec", codec).Msg("Ignoring unsupported TUNNEL_COMPRESSION codec")
+			continue
+		}
+		tunnelCompression = append(tunnelCompression, codec)
+	}
+}
+
+// decodeProxyBody decompresses a response body in place, so handlers only
+// ever see plain bytes.
+func decodeProxyBody(agentName string, resp *pb.ProxyResponse) error {
+	if resp.Encoding == "" {
+		return nil
+	}
+	body, err := pb.Decompress(resp.Encoding, resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s body: %v", resp.Encoding, err)
+	}
+	metrics.TunnelCompressedBytes.WithLabelValues(agentName, resp.Encoding).Add(float64(len(resp.Body)))
+	metrics.TunnelCompressionSavedBytes.WithLabelValues(agentName, resp.Encoding).Add(float64(len(body) - len(resp.Body)))
+	resp.Body = body
+	resp.Encoding = ""
+	return nil
+}
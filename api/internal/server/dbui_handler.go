@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
 )
 
@@ -24,18 +25,34 @@ func HandleDbUiProxy(c *gin.Context) {
 		dbType = "postgresql"
 	}
 
+	capability := pb.CapabilityDbUiPostgres
+	if dbType == "elasticsearch" {
+		capability = pb.CapabilityDbUiElasticsearch
+	}
+	if !requireCapability(c, agentID, capability) {
+		return
+	}
+
+	requestMetrics := newAgentRequestMetrics(agentID, "dbuiRequest")
+	defer requestMetrics.done()
+
 	agentsMutex.RLock()
 	agent, exists := AgentConnections[agentID]
 	agentsMutex.RUnlock()
 
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		requestMetrics.fail(agentNotConnected(agentID))
+		writeTunnelError(c, agentNotConnected(agentID))
 		return
 	}
 
 	streamID := uuid.New().String()
-	responseChan := make(chan *pb.ProxyResponse, 10000)
-	ctx, cancel := context.WithCancel(c.Request.Context())
+	// A single response is expected
+	responseChan := make(chan *pb.ProxyResponse, 16)
+	spanCtx, span := startTunnelSpan(c.Request.Context(), "tunnel dbuiRequest", agentID, streamID)
+	defer span.End()
+	ctx, cancel := context.WithTimeout(spanCtx, agentRequestTimeout)
+	defer cancel()
 
 	agent.mutex.Lock()
 	agent.requestChannels[streamID] = responseChan
@@ -52,8 +69,11 @@ func HandleDbUiProxy(c *gin.Context) {
 				DbType:    dbType,
 			},
 		},
+		TraceContext: tracing.Inject(ctx),
 	}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request to agent"})
+		cleanupAgentStream(agent, streamID)
+		requestMetrics.fail(agentSendFailed(err))
+		writeTunnelError(c, agentSendFailed(err))
 		return
 	}
 
@@ -65,8 +85,13 @@ func HandleDbUiProxy(c *gin.Context) {
 		delete(agent.contexts, streamID)
 		agent.mutex.Unlock()
 
-		if resp.Status != pb.ProxyResponseType_DATA {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from agent"})
+		if resp.Status == pb.ProxyResponseType_ERROR {
+			failSpan(span, proxyResponseError(resp))
+			requestMetrics.answer(proxyResponseError(resp))
+		} else {
+			requestMetrics.answer(nil)
+		}
+		if !checkAgentResponse(c, resp) {
 			return
 		}
 		c.Data(http.StatusOK, "application/json", resp.Body)
@@ -77,12 +102,23 @@ func HandleDbUiProxy(c *gin.Context) {
 		delete(agent.contexts, streamID)
 		agent.mutex.Unlock()
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Agent connection closed unexpectedly"})
+		switch {
+		case c.Request.Context().Err() != nil:
+			requestMetrics.fail(c.Request.Context().Err())
+			writeTunnelError(c, c.Request.Context().Err())
+		case ctx.Err() == context.DeadlineExceeded:
+			failSpan(span, agentTimedOut(agentID))
+			requestMetrics.fail(agentTimedOut(agentID))
+			writeTunnelError(c, agentTimedOut(agentID))
+		default:
+			requestMetrics.fail(agentConnectionClosed())
+			writeTunnelError(c, agentConnectionClosed())
+		}
 		return
 	}
 }
 
 // RegisterDbUiRoutes registers database UI proxy routes
 func RegisterDbUiRoutes(r *gin.Engine) {
-	r.GET("/api/agent/:agent/dbui/:namespace/:dbname", HandleDbUiProxy)
+	r.GET("/api/agent/:agent/dbui/:namespace/:dbname", limitAgentStreams, HandleDbUiProxy)
 }
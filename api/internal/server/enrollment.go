@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	"github.com/uc-cdis/gen3-admin/internal/store"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+const (
+	defaultJoinTokenTTL = 1 * time.Hour
+	// joinTokenKey is the key of the join token in the agent's csoc-tls Secret
+	joinTokenKey = "join-token"
+)
+
+func hashJoinToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// mintJoinToken creates a single-use token the agent can exchange for its certificate.
+func mintJoinToken(agentName string, createdBy string, ttl time.Duration) (string, error) {
+	if registry == nil {
+		return "", errors.New("agent registry is not initialized")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating join token: %v", err)
+	}
+	token := hex.EncodeToString(raw)
+	hash := hashJoinToken(token)
+
+	now := time.Now()
+	joinToken := store.JoinToken{
+		ID:        hash[:12],
+		TokenHash: hash,
+		Agent:     agentName,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := registry.CreateJoinToken(joinToken); err != nil {
+		return "", fmt.Errorf("error storing join token: %v", err)
+	}
+
+	log.Info().
+		Str("agent", agentName).
+		Str("token_id", joinToken.ID).
+		Str("created_by", createdBy).
+		Time("expires", joinToken.ExpiresAt).
+		Msg("Join token created")
+
+	return token, nil
+}
+
+// Enroll exchanges a join token and CSR for the agent's first certificate. It
+// is the only RPC an agent can call without a client certificate.
+func (s *AgentServer) Enroll(ctx context.Context, req *pb.EnrollRequest) (*pb.EnrollResponse, error) {
+	agentName := req.AgentName
+	if !validAgentName.MatchString(agentName) {
+		return nil, status.Error(codes.InvalidArgument, "invalid agent name")
+	}
+	if registry == nil {
+		return nil, status.Error(codes.Unavailable, "agent registry is not initialized")
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+
+	token, err := registry.ConsumeJoinToken(hashJoinToken(req.JoinToken), agentName, remoteAddr)
+	if err != nil {
+		logEvent := log.Warn().Err(err).Str("agent", agentName).Str("remote_addr", remoteAddr)
+		if token != nil {
+			logEvent = logEvent.Str("token_id", token.ID)
+		}
+		logEvent.Msg("Rejected agent enrollment")
+		return nil, status.Error(codes.Unauthenticated, "invalid join token")
+	}
+
+	agentsMutex.RLock()
+	conn, exists := AgentConnections[agentName]
+	var record Agent
+	if exists {
+		record = conn.agent
+	}
+	agentsMutex.RUnlock()
+
+	if !exists || record.Revoked {
+		log.Warn().Str("agent", agentName).Str("token_id", token.ID).Msg("Enrollment for unknown or revoked agent")
+		return nil, status.Error(codes.PermissionDenied, "agent is not registered")
+	}
+
+	cert, certPEM, err := ca.SignAgentCSR(req.Csr, agentName, record.Id)
+	if err != nil {
+		log.Warn().Err(err).Str("agent", agentName).Str("token_id", token.ID).Msg("Rejected agent CSR")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	caPEM, err := ca.CACertificatePEM()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load CA certificate")
+	}
+
+	if err := os.WriteFile(filepath.Join("certs", path.Clean(agentName+".crt")), certPEM, 0644); err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to write agent certificate")
+	}
+
+	agentsMutex.Lock()
+	if current := AgentConnections[agentName]; current != nil {
+		current.agent.Certificate = string(certPEM)
+		current.agent.CertExpiresAt = cert.NotAfter
+		current.agent.AddCertificate(cert.SerialNumber.String(), cert.NotAfter)
+		record = current.agent
+	}
+	agentsMutex.Unlock()
+	persistAgent(record)
+
+	log.Info().
+		Str("agent", agentName).
+		Str("token_id", token.ID).
+		Str("remote_addr", remoteAddr).
+		Str("serial", cert.SerialNumber.String()).
+		Msg("Agent enrolled")
+
+	return &pb.EnrollResponse{
+		Certificate:   certPEM,
+		CaCertificate: caPEM,
+	}, nil
+}
+
+func ListJoinTokensHandler(c *gin.Context) {
+	if registry == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "agent registry is not initialized"})
+		return
+	}
+	tokens, err := registry.ListJoinTokens()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list join tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	"github.com/uc-cdis/gen3-admin/internal/store"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// useTestRegistry backs the agent registry with an empty SQLite store.
+func useTestRegistry(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := registry
+	registry = s
+	t.Cleanup(func() {
+		registry = previous
+		s.Close()
+	})
+	return s
+}
+
+// useTestCA runs the test in an empty directory, where the CA and the
+// revocation list are created under certs/ on first use.
+func useTestCA(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("certs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Drops the revocation list read from another test's directory
+	ca.UseRevocationBackend(nil)
+	t.Cleanup(func() { ca.UseRevocationBackend(nil) })
+}
+
+// useTestAgents replaces the known agents with the given ones.
+func useTestAgents(t *testing.T, agents ...Agent) {
+	t.Helper()
+	agentsMutex.Lock()
+	previous := AgentConnections
+	AgentConnections = map[string]*AgentConnection{}
+	for _, agent := range agents {
+		AgentConnections[agent.Name] = newAgentConnection(agent)
+		persistAgent(agent)
+	}
+	agentsMutex.Unlock()
+	t.Cleanup(func() {
+		agentsMutex.Lock()
+		AgentConnections = previous
+		agentsMutex.Unlock()
+	})
+}
+
+func newTestCSR(t *testing.T, commonName string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(CertCurve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func TestEnrollConsumesJoinToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		agents   []Agent
+		mintFor  string
+		ttl      time.Duration
+		token    string
+		enrollAs string
+		csrName  string
+		twice    bool
+		wantCode codes.Code
+	}{
+		{name: "valid token", agents: []Agent{{Name: "agent1", Id: "id1"}}, mintFor: "agent1", ttl: time.Hour, enrollAs: "agent1", csrName: "agent1", wantCode: codes.OK},
+		{name: "token used twice", agents: []Agent{{Name: "agent1"}}, mintFor: "agent1", ttl: time.Hour, enrollAs: "agent1", csrName: "agent1", twice: true, wantCode: codes.Unauthenticated},
+		{name: "expired token", agents: []Agent{{Name: "agent1"}}, mintFor: "agent1", ttl: -time.Second, enrollAs: "agent1", csrName: "agent1", wantCode: codes.Unauthenticated},
+		{name: "token for another agent", agents: []Agent{{Name: "agent1"}, {Name: "agent2"}}, mintFor: "agent1", ttl: time.Hour, enrollAs: "agent2", csrName: "agent2", wantCode: codes.Unauthenticated},
+		{name: "unknown token", agents: []Agent{{Name: "agent1"}}, token: "not-a-token", enrollAs: "agent1", csrName: "agent1", wantCode: codes.Unauthenticated},
+		{name: "invalid agent name", mintFor: "agent1", ttl: time.Hour, enrollAs: "../agent1", csrName: "../agent1", wantCode: codes.InvalidArgument},
+		{name: "revoked agent", agents: []Agent{{Name: "agent1", Revoked: true}}, mintFor: "agent1", ttl: time.Hour, enrollAs: "agent1", csrName: "agent1", wantCode: codes.PermissionDenied},
+		{name: "deleted agent", mintFor: "agent1", ttl: time.Hour, enrollAs: "agent1", csrName: "agent1", wantCode: codes.PermissionDenied},
+		{name: "CSR for another agent", agents: []Agent{{Name: "agent1"}}, mintFor: "agent1", ttl: time.Hour, enrollAs: "agent1", csrName: "agent2", wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useTestRegistry(t)
+			useTestCA(t)
+			useTestAgents(t, tt.agents...)
+
+			token := tt.token
+			if tt.mintFor != "" {
+				var err error
+				if token, err = mintJoinToken(tt.mintFor, "admin", tt.ttl); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}})
+			req := &pb.EnrollRequest{AgentName: tt.enrollAs, JoinToken: token, Csr: newTestCSR(t, tt.csrName)}
+			server := &AgentServer{}
+			resp, err := server.Enroll(ctx, req)
+			if tt.twice {
+				if err != nil {
+					t.Fatalf("first Enroll: %v", err)
+				}
+				resp, err = server.Enroll(ctx, req)
+			}
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Enroll error = %v, want %s", err, tt.wantCode)
+			}
+			if tt.wantCode != codes.OK {
+				return
+			}
+
+			cert := parseCertificatePEM(string(resp.Certificate))
+			if cert == nil || cert.Subject.CommonName != "agent1" || cert.Subject.SerialNumber != "id1" {
+				t.Fatalf("Enroll returned certificate %+v, want one for agent1", cert)
+			}
+			if len(resp.CaCertificate) == 0 {
+				t.Error("Enroll returned no CA certificate")
+			}
+
+			tokens, err := s.ListJoinTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tokens) != 1 || tokens[0].UsedAt == nil || tokens[0].UsedFrom != "10.0.0.2:4000" {
+				t.Errorf("join tokens = %+v, want the token marked used", tokens)
+			}
+
+			// The certificate is tracked so revoking the agent revokes it
+			stored, err := s.GetAgent("agent1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Certificates) != 1 || stored.Certificates[0].SerialNumber != cert.SerialNumber.String() {
+				t.Errorf("stored certificates = %+v, want the enrolled one", stored.Certificates)
+			}
+			if _, err := os.Stat(filepath.Join("certs", "agent1.crt")); err != nil {
+				t.Errorf("certificate was not written: %v", err)
+			}
+		})
+	}
+}
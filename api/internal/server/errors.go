@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// agentRequestTimeout bounds the wait for an agent's first response.
+// AGENT_REQUEST_TIMEOUT overrides it.
+var agentRequestTimeout = 2 * time.Minute
+
+func init() {
+	if v := os.Getenv("AGENT_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			agentRequestTimeout = d
+		} else {
+			log.Warn().Str("value", v).Msg("Invalid AGENT_REQUEST_TIMEOUT, using default")
+		}
+	}
+}
+
+func agentNotConnected(agentID string) *pb.TunnelError {
+	te := pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND, fmt.Sprintf("agent not found: %s", agentID))
+	te.Details = map[string]string{"kind": "agent", "name": agentID}
+	return te
+}
+
+func agentTimedOut(agentID string) *pb.TunnelError {
+	return pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_TIMEOUT,
+		fmt.Sprintf("agent %s did not answer within %s", agentID, agentRequestTimeout))
+}
+
+func agentSendFailed(err error) *pb.TunnelError {
+	return pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE, fmt.Sprintf("failed to send request to agent: %v", err))
+}
+
+func agentConnectionClosed() *pb.TunnelError {
+	return pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE, "agent connection closed unexpectedly")
+}
+
+// proxyResponseError is the error an ERROR frame carries. Agents that
+// predate TunnelError only send a status code and the message.
+func proxyResponseError(resp *pb.ProxyResponse) *pb.TunnelError {
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return pb.NewTunnelError(pb.TunnelErrorCodeForStatus(int(resp.StatusCode)), string(resp.Body))
+}
+
+// writeTunnelError answers with the HTTP status err maps to, in the same
+// shape across helm, dbui, proxy and terminal endpoints.
+func writeTunnelError(c *gin.Context, err error) {
+	te := pb.TunnelErrorFor(err)
+	body := gin.H{
+		"error":     te.Message,
+		"code":      te.CodeName(),
+		"retryable": te.Retryable,
+	}
+	if te.Reason != "" {
+		body["reason"] = te.Reason
+	}
+	if len(te.Details) > 0 {
+		body["details"] = te.Details
+	}
+	c.JSON(te.HTTPStatus(), body)
+}
+
+// checkAgentResponse answers for a single-response request that didn't
+// come back as DATA, and reports whether the handler should carry on.
+func checkAgentResponse(c *gin.Context, resp *pb.ProxyResponse) bool {
+	switch resp.Status {
+	case pb.ProxyResponseType_DATA:
+		return true
+	case pb.ProxyResponseType_ERROR:
+		writeTunnelError(c, proxyResponseError(resp))
+		return false
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "Invalid response from agent", "code": "internal", "retryable": false})
+	return false
+}
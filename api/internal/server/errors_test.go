@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+func TestCheckAgentResponse(t *testing.T) {
+	notFound := pb.TunnelErrorFor(apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web-0"))
+
+	tests := []struct {
+		name          string
+		resp          *pb.ProxyResponse
+		wantOK        bool
+		wantStatus    int
+		wantCode      string
+		wantRetryable bool
+		wantDetails   bool
+	}{
+		{
+			name:   "data",
+			resp:   &pb.ProxyResponse{Status: pb.ProxyResponseType_DATA},
+			wantOK: true,
+		},
+		{
+			name:        "tunnel error",
+			resp:        &pb.ProxyResponse{Status: pb.ProxyResponseType_ERROR, Error: notFound},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    "not_found",
+			wantDetails: true,
+		},
+		{
+			name:       "status from an older agent",
+			resp:       &pb.ProxyResponse{Status: pb.ProxyResponseType_ERROR, StatusCode: http.StatusForbidden, Body: []byte("denied")},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "forbidden",
+		},
+		{
+			name:          "unavailable agent",
+			resp:          &pb.ProxyResponse{Status: pb.ProxyResponseType_ERROR, Error: agentConnectionClosed()},
+			wantStatus:    http.StatusServiceUnavailable,
+			wantCode:      "unavailable",
+			wantRetryable: true,
+		},
+		{
+			name:       "unexpected frame",
+			resp:       &pb.ProxyResponse{Status: pb.ProxyResponseType_END},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   "internal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			if ok := checkAgentResponse(c, tt.resp); ok != tt.wantOK {
+				t.Fatalf("checkAgentResponse = %v, want %v", ok, tt.wantOK)
+			}
+			if tt.wantOK {
+				return
+			}
+
+			var body struct {
+				Error     string            `json:"error"`
+				Code      string            `json:"code"`
+				Retryable bool              `json:"retryable"`
+				Details   map[string]string `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantStatus || body.Code != tt.wantCode || body.Retryable != tt.wantRetryable || body.Error == "" {
+				t.Errorf("got %d %+v, want %d %s retryable=%v", w.Code, body, tt.wantStatus, tt.wantCode, tt.wantRetryable)
+			}
+			if (body.Details != nil) != tt.wantDetails {
+				t.Errorf("got details %v, want details %v", body.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestWriteTunnelError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"agent not connected", agentNotConnected("agent1"), http.StatusNotFound, "not_found"},
+		{"agent timed out", agentTimedOut("agent1"), http.StatusGatewayTimeout, "timeout"},
+		{"send failed", agentSendFailed(errors.New("stream closed")), http.StatusServiceUnavailable, "unavailable"},
+		{"plain error", errors.New("boom"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			writeTunnelError(c, tt.err)
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantStatus || body["code"] != tt.wantCode {
+				t.Errorf("got %d %v, want %d %s", w.Code, body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
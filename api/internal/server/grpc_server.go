@@ -3,13 +3,11 @@ package server
 import (
 	"context"
 	"crypto/x509"
-	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"os"
-	"path"
-	"path/filepath"
+	"net/http"
 	"sync"
 	"time"
 
@@ -22,6 +20,8 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/uc-cdis/gen3-admin/internal/ca"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
+	"github.com/uc-cdis/gen3-admin/internal/store"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
 )
 
@@ -42,6 +42,25 @@ type AgentConnection struct {
 	mutex           sync.Mutex
 	agent           Agent
 	terminalStreams map[string]*websocket.Conn
+	uploadCredits   map[string]chan int32
+	portForwards    map[string]chan *pb.PortForward
+
+	// done is closed by terminate to end the agent's stream from the server side
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	peerCert  *x509.Certificate
+
+	// persistedAt is when a status update last wrote the agent to the registry
+	persistedAt time.Time
+}
+
+// terminate closes the agent's tunnel, Connect returns err to the agent.
+func (a *AgentConnection) terminate(err error) {
+	a.closeOnce.Do(func() {
+		a.closeErr = err
+		close(a.done)
+	})
 }
 
 func (a *AgentConnection) sendMessage(msg *pb.ServerMessage) error {
@@ -69,11 +88,62 @@ func SetupGRCPServer() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to listen on port")
 	}
+	serversMu.Lock()
+	grpcServer = s
+	serversMu.Unlock()
+
 	go s.Serve(lis)
+	go monitorAgentLiveness()
 
 	log.Info().Msg("GRPC Server listening on :50051")
 }
 
+func clusterHealthFromStatus(s *pb.StatusUpdate) store.ClusterHealth {
+	return store.ClusterHealth{
+		NodeCount:        int(s.NodeCount),
+		NotReadyNodes:    int(s.NotReadyNodes),
+		PendingPods:      int(s.PendingPods),
+		CrashLoopingPods: int(s.CrashLoopingPods),
+		FailingPVCs:      int(s.FailingPvcs),
+	}
+}
+
+// registeredAgent returns the record of an agent that is connecting. The
+// store is checked too, another replica may have revoked or deleted it.
+func registeredAgent(agentName string) (Agent, bool) {
+	var record Agent
+	agentsMutex.RLock()
+	existing, registered := AgentConnections[agentName]
+	if registered {
+		record = existing.agent
+	}
+	agentsMutex.RUnlock()
+
+	if registry == nil {
+		return record, registered
+	}
+	stored, err := registry.GetAgent(agentName)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return record, false
+	case err != nil:
+		// Keep agents connecting through a store outage, on what this replica knows
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to read agent from registry")
+	case !registered:
+		return *stored, true
+	default:
+		record.Revoked = record.Revoked || stored.Revoked
+	}
+	return record, registered
+}
+
+func peerAddr(p *peer.Peer) string {
+	if p == nil || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
 func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 	// Extract TLS Info for client authentication
 	p, ok := peer.FromContext(stream.Context())
@@ -109,18 +179,9 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 
 	agentName := registrationRequest.Registration.AgentName
 
-	var roleArn string
-	var assumeMethod string
-	var accessKey string
-	var secretAccessKey string
-	agentsMutex.RLock()
-	if existing := AgentConnections[agentName]; existing != nil {
-		roleArn = existing.agent.RoleARN
-		assumeMethod = existing.agent.AssumeMethod
-		accessKey = existing.agent.AccessKey
-		secretAccessKey = existing.agent.SecretAccessKey
-	}
-	agentsMutex.RUnlock()
+	// Start from the registered record so everything set at creation time
+	// (role, assume method, metadata, ...) survives reconnects and restarts
+	record, registered := registeredAgent(agentName)
 
 	// Check subject common name against configured username
 	if tlsAuth.State.VerifiedChains[0][0].Subject.CommonName != agentName {
@@ -136,24 +197,22 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 		return status.Error(codes.InvalidArgument, "invalid agent name")
 	}
 
-	// Read agent cert file
-	certFile, err := os.ReadFile(filepath.Join("certs", path.Clean(agentName+".crt")))
-	if err != nil {
-		log.Fatal().Err(err).Msg("Error reading agent cert file")
-		return err
+	// A CA-signed certificate is not enough, the agent must still be
+	// registered: deleting or revoking it ends all of its certificates
+	if !registered {
+		log.Warn().Str("agent", agentName).Str("serial", clientCert.SerialNumber.String()).Msg("Rejected unknown agent")
+		return status.Error(codes.PermissionDenied, "agent is not registered")
 	}
-
-	// Parse the certificate
-	block, _ := pem.Decode(certFile)
-	if block == nil {
-		log.Fatal().Msg("Failed to parse agent certificate")
-		return err
+	if record.Revoked {
+		log.Warn().Str("agent", agentName).Str("serial", clientCert.SerialNumber.String()).Msg("Rejected revoked agent")
+		return status.Error(codes.PermissionDenied, "agent has been revoked")
 	}
 
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to parse agent certificate")
-		return err
+	// The handshake already rejects revoked certs, this catches revocations
+	// that happened while the TLS connection was being reused
+	if ca.IsRevoked(clientCert.SerialNumber) {
+		log.Warn().Str("agent", agentName).Str("serial", clientCert.SerialNumber.String()).Msg("Rejected revoked agent certificate")
+		return status.Error(codes.PermissionDenied, "agent certificate has been revoked")
 	}
 
 	// Check if the agent is already connected
@@ -199,6 +258,8 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 		})
 
 		time.Sleep(1 * time.Second)
+		existingAgent.terminate(status.Error(codes.Aborted, "replaced by a new connection"))
+		recordConnectionEvent(agentName, store.EventReplaced, "already connected, replacing the connection", peerAddr(p))
 		agentsMutex.Lock()
 
 		delete(AgentConnections, agentName)
@@ -208,29 +269,70 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 		}
 	}
 
-	agent := &AgentConnection{
-		stream:          stream,
-		requestChannels: preservedChannels,
-		contexts:        preservedContexts,
-		cancelFuncs:     preservedCancelFuncs,
-		terminalStreams: make(map[string]*websocket.Conn),
-		agent: Agent{
-			Id:              cert.Subject.SerialNumber,
-			Name:            agentName,
-			Connected:       true,
-			LastSeen:        time.Now(),
-			RoleARN:         roleArn,
-			AssumeMethod:    assumeMethod,
-			AccessKey:       accessKey,
-			SecretAccessKey: secretAccessKey,
-		},
+	record.Id = clientCert.Subject.SerialNumber
+	record.Name = agentName
+	record.Connected = true
+	record.Status = store.AgentOnline
+	record.LastSeen = time.Now()
+	record.Version = registrationRequest.Registration.AgentVersion
+	record.Capabilities = registrationRequest.Registration.Capabilities
+	record.Compression = pb.NegotiateCompression(tunnelCompression, registrationRequest.Registration.Compression)
+	if record.CertExpiresAt.IsZero() {
+		record.CertExpiresAt = clientCert.NotAfter
+	}
+	if exists {
+		// An upgrade may have moved on since the record was read
+		record.DesiredImage = existingAgent.agent.DesiredImage
+		record.DesiredVersion = existingAgent.agent.DesiredVersion
+		record.Upgrade = existingAgent.agent.Upgrade
 	}
 
+	agent := newAgentConnection(record)
+	agent.stream = stream
+	agent.requestChannels = preservedChannels
+	agent.contexts = preservedContexts
+	agent.cancelFuncs = preservedCancelFuncs
+	agent.peerCert = clientCert
+
 	AgentConnections[agentName] = agent
 	agentsMutex.Unlock()
+	claimAgent(agentName)
+
+	err = agent.sendMessage(&pb.ServerMessage{
+		Message: &pb.ServerMessage_Registration{
+			Registration: &pb.RegistrationResponse{
+				Success:     true,
+				Compression: record.Compression,
+			},
+		},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("agent", agentName).Msg("Failed to send registration response")
+	}
+
+	remoteAddr := peerAddr(p)
+	persistAgent(record)
+	recordConnectionEvent(agentName, store.EventConnected, "", remoteAddr)
+	reconcileUpgrade(agentName)
 
 	log.Info().Msgf("Agent %s connected", agentName)
 
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.receiveLoop(stream, agent, agentName, remoteAddr)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-agent.done:
+		// Returning cancels the stream, receiveLoop then records the disconnect
+		log.Warn().Err(agent.closeErr).Msgf("Terminating tunnel for agent %s", agentName)
+		return agent.closeErr
+	}
+}
+
+func (s *AgentServer) receiveLoop(stream pb.TunnelService_ConnectServer, agent *AgentConnection, agentName string, remoteAddr string) error {
 	for {
 		agentMessage, err := stream.Recv()
 		if err != nil {
@@ -240,19 +342,33 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 				log.Error().Err(err).Msgf("Error receiving message from agent %s", agentName)
 			}
 
+			reason := "stream closed by agent"
+			if err != io.EOF {
+				reason = err.Error()
+			}
+
 			log.Warn().Msg("Agent disconnected, keeping pending requests alive")
 			// Preserve the request channels so pending requests can still receive responses
-			// when the agent reconnects
+			// when the agent reconnects. A replaced connection must not clobber its successor.
 			agentsMutex.Lock()
-			AgentConnections[agentName] = &AgentConnection{
-				requestChannels: agent.requestChannels,
-				cancelFuncs:     agent.cancelFuncs,
-				contexts:        agent.contexts,
-				agent: Agent{
-					Connected: false,
-				},
+			if AgentConnections[agentName] == agent {
+				disconnected := newAgentConnection(agent.agent)
+				disconnected.requestChannels = agent.requestChannels
+				disconnected.cancelFuncs = agent.cancelFuncs
+				disconnected.contexts = agent.contexts
+				disconnected.peerCert = agent.peerCert
+				disconnected.agent.Connected = false
+				disconnected.agent.Status = store.AgentOffline
+				AgentConnections[agentName] = disconnected
+				agentsMutex.Unlock()
+				// Reconnected to another replica already, which now owns the record
+				if releaseAgent(agentName) {
+					persistAgent(disconnected.agent)
+				}
+			} else {
+				agentsMutex.Unlock()
 			}
-			agentsMutex.Unlock()
+			recordConnectionEvent(agentName, store.EventDisconnected, reason, remoteAddr)
 
 			// Don't cancel pending requests - they'll be fulfilled when agent reconnects
 			return err
@@ -282,17 +398,71 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 		case *pb.AgentMessage_Status:
 			log.Debug().Msgf("Received status update from agent %s: %v", agentName, msg.Status)
 			agentsMutex.Lock()
-			agent := AgentConnections[agentName]
+			previousStatus := agent.agent.Status
+			agent.agent.Status = store.AgentOnline
 			agent.agent.LastSeen = time.Now()
 			agent.agent.CpuUsage = msg.Status.CpuUsage
 			agent.agent.MemoryUsage = msg.Status.MemoryUsage
 			agent.agent.Connected = true
 			agent.agent.Provider = msg.Status.Provider
 			agent.agent.K8sVersion = msg.Status.K8SVersion
-			AgentConnections[agentName] = agent
+			agent.agent.PodCapacity = int(msg.Status.PodCapacity)
+			agent.agent.PodCount = int(msg.Status.PodCount)
+			agent.agent.Reconnects = msg.Status.Reconnects
+			agent.agent.ClusterHealth = clusterHealthFromStatus(msg.Status)
+			record := agent.agent
+			current := AgentConnections[agentName] == agent
+			// Usage is kept by the status history, the stored agent only
+			// follows status changes and is refreshed now and then
+			persist := current && (previousStatus != store.AgentOnline || time.Since(agent.persistedAt) >= agentPersistInterval)
+			if persist {
+				agent.persistedAt = record.LastSeen
+			}
 			agentsMutex.Unlock()
+
+			// A deleted or replaced connection must not write over the registry
+			if !current {
+				break
+			}
+			if previousStatus == store.AgentDegraded {
+				recordConnectionEvent(agentName, store.EventRecovered, "status updates resumed", remoteAddr)
+			}
+			if persist {
+				persistAgent(record)
+			}
+			persistStatus(store.StatusSample{
+				Agent:         agentName,
+				Timestamp:     record.LastSeen,
+				CpuUsage:      msg.Status.CpuUsage,
+				MemoryUsage:   msg.Status.MemoryUsage,
+				HealthStatus:  msg.Status.HealthStatus,
+				Provider:      msg.Status.Provider,
+				K8sVersion:    msg.Status.K8SVersion,
+				PodCapacity:   int(msg.Status.PodCapacity),
+				PodCount:      int(msg.Status.PodCount),
+				ClusterHealth: record.ClusterHealth,
+			})
+		case *pb.AgentMessage_CertificateRenewal:
+			log.Info().Msgf("Received certificate renewal request from agent %s", agentName)
+			go handleCertificateRenewal(agent, agentName, msg.CertificateRenewal)
+		case *pb.AgentMessage_UpgradeStatus:
+			handleUpgradeStatus(agentName, msg.UpgradeStatus)
+		case *pb.AgentMessage_Credit:
+			agent.grantUploadCredit(msg.Credit)
+		case *pb.AgentMessage_PortForward:
+			agent.deliverPortForward(msg.PortForward)
 		case *pb.AgentMessage_Proxy:
 			proxyResp := msg.Proxy
+			if err := decodeProxyBody(agentName, proxyResp); err != nil {
+				log.Error().Err(err).Str("stream_id", proxyResp.StreamId).Msg("[grpc-server] Dropping undecodable proxy response")
+				proxyResp = &pb.ProxyResponse{
+					StreamId:   proxyResp.StreamId,
+					Status:     pb.ProxyResponseType_ERROR,
+					StatusCode: http.StatusBadGateway,
+					Body:       []byte(err.Error()),
+					Error:      pb.NewTunnelError(pb.TunnelErrorCode_TUNNEL_ERROR_INTERNAL, err.Error()),
+				}
+			}
 			agent.mutex.Lock()
 			responseChan, exists := agent.requestChannels[proxyResp.StreamId]
 			if exists {
@@ -314,6 +484,7 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 						Str("response_type", proxyResp.Status.String()).
 						Msg("[grpc-server] Received response for unknown stream ID - handler already cleaned up")
 					s.deadStreamIDs[proxyResp.StreamId] = time.Now()
+					metrics.TunnelDeadStreams.WithLabelValues(agentName).Inc()
 				}
 				s.mu.Unlock()
 			}
@@ -325,7 +496,19 @@ func (s *AgentServer) Connect(stream pb.TunnelService_ConnectServer) error {
 			webSocket, exists := agent.terminalStreams[termResp.SessionId]
 			agent.mutex.Unlock()
 
+			if exists && termResp.Error != nil {
+				log.Warn().Str("session", termResp.SessionId).Str("code", termResp.Error.CodeName()).Msgf("Terminal session failed on agent %s: %s", agentName, termResp.Error.Message)
+				closeTerminalWithError(webSocket, termResp.Error)
+				agent.mutex.Lock()
+				if cancel, ok := agent.cancelFuncs[termResp.SessionId]; ok {
+					cancel()
+				}
+				agent.mutex.Unlock()
+				break
+			}
+
 			if exists {
+				recordTerminalOutput(termResp.SessionId, termResp.Data)
 				err := webSocket.WriteMessage(websocket.TextMessage, termResp.Data)
 				if err != nil {
 					log.Warn().Err(err).Msgf("Failed to write TerminalStream to WebSocket for session ID: %s", termResp.SessionId)
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// registeringStream is an agent that presents cert and registers as agentName.
+type registeringStream struct {
+	pb.TunnelService_ConnectServer
+	ctx       context.Context
+	agentName string
+}
+
+func (s *registeringStream) Context() context.Context { return s.ctx }
+
+func (s *registeringStream) Recv() (*pb.AgentMessage, error) {
+	return &pb.AgentMessage{Message: &pb.AgentMessage_Registration{
+		Registration: &pb.RegistrationRequest{AgentName: s.agentName},
+	}}, nil
+}
+
+func newRegisteringStream(cert *x509.Certificate, agentName string) *registeringStream {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.PeerCertificates = []*x509.Certificate{cert}
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+	return &registeringStream{ctx: ctx, agentName: agentName}
+}
+
+func TestConnectRejectsAgents(t *testing.T) {
+	tests := []struct {
+		name        string
+		agents      []Agent
+		setup       func(t *testing.T, cert *x509.Certificate)
+		noCert      bool
+		registerAs  string
+		wantCode    codes.Code
+		wantMessage string
+	}{
+		{
+			name:        "unknown agent",
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "not registered",
+		},
+		{
+			name:        "revoked agent",
+			agents:      []Agent{{Name: "agent1", Revoked: true}},
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "revoked",
+		},
+		{
+			name:   "revoked in the store by another replica",
+			agents: []Agent{{Name: "agent1"}},
+			setup: func(t *testing.T, _ *x509.Certificate) {
+				if err := registry.SaveAgent(Agent{Name: "agent1", Revoked: true}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "revoked",
+		},
+		{
+			name:   "deleted from the store by another replica",
+			agents: []Agent{{Name: "agent1"}},
+			setup: func(t *testing.T, _ *x509.Certificate) {
+				if err := registry.DeleteAgent("agent1"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "not registered",
+		},
+		{
+			name:   "revoked certificate",
+			agents: []Agent{{Name: "agent1"}},
+			setup: func(t *testing.T, cert *x509.Certificate) {
+				if err := ca.RevokeCertificate(cert, "agent1", "compromised"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantCode:    codes.PermissionDenied,
+			wantMessage: "certificate has been revoked",
+		},
+		{
+			name:        "certificate of another agent",
+			agents:      []Agent{{Name: "agent1"}, {Name: "agent2"}},
+			registerAs:  "agent2",
+			wantCode:    codes.Unauthenticated,
+			wantMessage: "common name",
+		},
+		{
+			name:        "no client certificate",
+			agents:      []Agent{{Name: "agent1"}},
+			noCert:      true,
+			wantCode:    codes.Unauthenticated,
+			wantMessage: "no client certificates",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t)
+			useTestCA(t)
+			useTestAgents(t, tt.agents...)
+
+			key, err := ecdsa.GenerateKey(CertCurve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert, _, err := ca.IssueAgentCertificate(&key.PublicKey, "agent1", "id1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(t, cert)
+			}
+
+			registerAs := tt.registerAs
+			if registerAs == "" {
+				registerAs = "agent1"
+			}
+			presented := cert
+			if tt.noCert {
+				presented = nil
+			}
+
+			err = (&AgentServer{}).Connect(newRegisteringStream(presented, registerAs))
+			if status.Code(err) != tt.wantCode || !strings.Contains(status.Convert(err).Message(), tt.wantMessage) {
+				t.Errorf("Connect error = %v, want %s containing %q", err, tt.wantCode, tt.wantMessage)
+			}
+
+			agentsMutex.RLock()
+			defer agentsMutex.RUnlock()
+			if conn := AgentConnections[registerAs]; conn != nil && (conn.stream != nil || conn.agent.Connected) {
+				t.Errorf("rejected agent was marked connected: %+v", conn.agent)
+			}
+		})
+	}
+}
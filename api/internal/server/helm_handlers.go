@@ -14,7 +14,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 	"github.com/uc-cdis/gen3-admin/internal/helm"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
 	"github.com/uc-cdis/gen3-admin/pkg/config"
 )
@@ -41,18 +44,47 @@ func setStreamIDOnMessage(msg *pb.ServerMessage, streamID string) {
 
 // sendAgentProxyRequest sends a gRPC ProxyRequest to an agent and waits for the response.
 // This DRYs up the repeated pattern used by helm, namespace status, and dbui handlers.
-// It creates its own cancelable context derived from the parent.
-func sendAgentProxyRequest(agentID string, msg *pb.ServerMessage, parentCtx context.Context) (*pb.ProxyResponse, error) {
+// It creates its own cancelable context derived from the parent, bounded by
+// agentRequestTimeout unless the parent has a deadline. Errors are
+// *pb.TunnelError.
+func sendAgentProxyRequest(agentID string, msg *pb.ServerMessage, parentCtx context.Context) (resp *pb.ProxyResponse, err error) {
+	requestMetrics := newAgentRequestMetrics(agentID, messageKind(msg))
+	defer func() {
+		if err != nil {
+			requestMetrics.fail(err)
+		}
+		requestMetrics.done()
+	}()
+
 	agentsMutex.RLock()
 	agent, exists := AgentConnections[agentID]
 	agentsMutex.RUnlock()
 	if !exists {
-		return nil, fmt.Errorf("agent not found: %s", agentID)
+		return nil, agentNotConnected(agentID)
 	}
 
-	responseChan := make(chan *pb.ProxyResponse, 10000)
+	// A single response is expected
+	responseChan := make(chan *pb.ProxyResponse, 16)
 	streamID := uuid.New().String()
-	ctx, cancel := context.WithCancel(parentCtx)
+
+	parentCtx, span := startTunnelSpan(parentCtx, "tunnel "+messageKind(msg), agentID, streamID)
+	defer func() {
+		switch {
+		case err != nil:
+			failSpan(span, err)
+		case resp.Status == pb.ProxyResponseType_ERROR:
+			failSpan(span, proxyResponseError(resp))
+		}
+		span.End()
+	}()
+	msg.TraceContext = tracing.Inject(parentCtx)
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if _, ok := parentCtx.Deadline(); ok {
+		ctx, cancel = context.WithCancel(parentCtx)
+	} else {
+		ctx, cancel = context.WithTimeout(parentCtx, agentRequestTimeout)
+	}
 
 	// Inject our streamID into the inner message so the agent routes the response correctly
 	setStreamIDOnMessage(msg, streamID)
@@ -66,17 +98,30 @@ func sendAgentProxyRequest(agentID string, msg *pb.ServerMessage, parentCtx cont
 	if err := agent.sendMessage(msg); err != nil {
 		cleanupAgentStream(agent, streamID)
 		cancel()
-		return nil, fmt.Errorf("failed to send request to agent: %w", err)
+		return nil, agentSendFailed(err)
 	}
 
 	select {
 	case resp := <-responseChan:
 		cleanupAgentStream(agent, streamID)
 		cancel()
+		if resp.Status == pb.ProxyResponseType_ERROR {
+			requestMetrics.answer(proxyResponseError(resp))
+		} else {
+			requestMetrics.answer(nil)
+		}
 		return resp, nil
 	case <-ctx.Done():
 		cleanupAgentStream(agent, streamID)
-		return nil, fmt.Errorf("agent connection closed or request cancelled")
+		cancel()
+		switch {
+		case parentCtx.Err() != nil:
+			return nil, pb.TunnelErrorFor(parentCtx.Err())
+		case ctx.Err() == context.DeadlineExceeded:
+			return nil, agentTimedOut(agentID)
+		}
+		// Cancelled when the agent's stream went away
+		return nil, agentConnectionClosed()
 	}
 }
 
@@ -93,6 +138,9 @@ func cleanupAgentStream(agent *AgentConnection, streamID string) {
 
 func HandleAgentHelmList(c *gin.Context) {
 	agentID := c.Param("agent")
+	if !requireCapability(c, agentID, pb.CapabilityHelm) {
+		return
+	}
 	ctx := c.Request.Context()
 
 	msg := &pb.ServerMessage{
@@ -103,12 +151,11 @@ func HandleAgentHelmList(c *gin.Context) {
 
 	resp, err := sendAgentProxyRequest(agentID, msg, ctx)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeTunnelError(c, err)
 		return
 	}
 
-	if resp.Status != pb.ProxyResponseType_DATA {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from agent"})
+	if !checkAgentResponse(c, resp) {
 		return
 	}
 	c.Data(http.StatusOK, "application/json", resp.Body)
@@ -116,6 +163,9 @@ func HandleAgentHelmList(c *gin.Context) {
 
 func HandleAgentHelmValues(c *gin.Context) {
 	agentID := c.Param("agent")
+	if !requireCapability(c, agentID, pb.CapabilityHelm) {
+		return
+	}
 	releaseName := c.Param("releasename")
 	namespace := c.Param("namespace")
 	ctx := c.Request.Context()
@@ -132,12 +182,11 @@ func HandleAgentHelmValues(c *gin.Context) {
 
 	resp, err := sendAgentProxyRequest(agentID, msg, ctx)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeTunnelError(c, err)
 		return
 	}
 
-	if resp.Status != pb.ProxyResponseType_DATA {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from agent"})
+	if !checkAgentResponse(c, resp) {
 		return
 	}
 	c.Data(http.StatusOK, "application/json", resp.Body)
@@ -145,8 +194,12 @@ func HandleAgentHelmValues(c *gin.Context) {
 
 func HandleAgentHelmDelete(c *gin.Context) {
 	agentID := c.Param("agent")
+	if !requireCapability(c, agentID, pb.CapabilityHelm) {
+		return
+	}
 	releaseName := c.Param("release")
 	namespace := c.Param("namespace")
+	audit.Describe(c, "release "+namespace+"/"+releaseName, "")
 	ctx := c.Request.Context()
 
 	log.Warn().Msgf("Helm delete request: %v", releaseName)
@@ -163,12 +216,11 @@ func HandleAgentHelmDelete(c *gin.Context) {
 
 	resp, err := sendAgentProxyRequest(agentID, msg, ctx)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeTunnelError(c, err)
 		return
 	}
 
-	if resp.Status != pb.ProxyResponseType_DATA {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from agent"})
+	if !checkAgentResponse(c, resp) {
 		return
 	}
 	c.Data(http.StatusOK, "application/json", resp.Body)
@@ -180,6 +232,9 @@ func HandleAgentHelmInstall(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Agent name is required"})
 		return
 	}
+	if !requireCapability(c, agentID, pb.CapabilityHelm) {
+		return
+	}
 
 	var requestData struct {
 		Repo      string                 `json:"repo"`
@@ -195,6 +250,8 @@ func HandleAgentHelmInstall(c *gin.Context) {
 		http.Error(c.Writer, err.Error(), http.StatusBadRequest)
 		return
 	}
+	audit.Describe(c, "release "+requestData.Namespace+"/"+requestData.Release,
+		fmt.Sprintf("install chart %s/%s version %s", requestData.Repo, requestData.Chart, requestData.Version))
 
 	installOpts := &helm.InstallOptions{
 		ChartName:       requestData.Chart,
@@ -222,7 +279,9 @@ func HandleAgentHelmInstall(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
+	// The agent gives helm installOpts.Timeout, leave it room to answer
+	ctx, cancel := context.WithTimeout(c.Request.Context(), installOpts.Timeout+30*time.Second)
+	defer cancel()
 	msg := &pb.ServerMessage{
 		Message: &pb.ServerMessage_HelmInstallRequest{
 			HelmInstallRequest: &pb.HelmInstallRequest{
@@ -240,18 +299,14 @@ func HandleAgentHelmInstall(c *gin.Context) {
 
 	resp, err := sendAgentProxyRequest(agentID, msg, ctx)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeTunnelError(c, err)
 		return
 	}
 
 	if resp.Status == pb.ProxyResponseType_ERROR {
 		log.Warn().Msg(string(resp.Body))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": string(resp.Body)})
-		return
 	}
-
-	if resp.Status != pb.ProxyResponseType_DATA {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid response from agent"})
+	if !checkAgentResponse(c, resp) {
 		return
 	}
 	c.Data(http.StatusOK, "application/json", resp.Body)
@@ -272,6 +327,8 @@ func HandleLocalHelmInstall(c *gin.Context) {
 		http.Error(c.Writer, "Invalid request data", http.StatusBadRequest)
 		return
 	}
+	audit.Describe(c, "release "+requestData.Namespace+"/"+requestData.Release,
+		fmt.Sprintf("install chart %s/%s version %s", requestData.Repo, requestData.Chart, requestData.Version))
 
 	release, err := helm.InstallHelmChart(helm.InstallOptions{
 		RepoName:        requestData.Repo,
@@ -285,9 +342,11 @@ func HandleLocalHelmInstall(c *gin.Context) {
 		CreateNamespace: true,
 	})
 	if err != nil {
+		metrics.HelmOperations.WithLabelValues("local", "install", pb.TunnelErrorFor(err).CodeName()).Inc()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	metrics.HelmOperations.WithLabelValues("local", "install", "success").Inc()
 	c.JSON(http.StatusOK, release)
 }
 
@@ -355,7 +414,7 @@ func HandleNamespaceDeploymentStatus(c *gin.Context) {
 
 	resp, err := sendAgentProxyRequest(agentID, msg, c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		writeTunnelError(c, err)
 		return
 	}
 
@@ -441,13 +500,13 @@ func HandleHelmChartsList(c *gin.Context) {
 // RegisterHelmRoutes registers all helm-related routes on the gin engine
 func RegisterHelmRoutes(r *gin.Engine) {
 	// Agent-proxied helm operations
-	r.GET("/api/agents/:agent/helm/list", HandleAgentHelmList)
-	r.GET("/api/agent/:agent/helm/values/:releasename/:namespace", HandleAgentHelmValues)
-	r.DELETE("/api/agent/:agent/helm/delete/:release/:namespace", HandleAgentHelmDelete)
-	r.POST("/api/agent/:agent/helm/install", HandleAgentHelmInstall)
+	r.GET("/api/agents/:agent/helm/list", limitAgentStreams, HandleAgentHelmList)
+	r.GET("/api/agent/:agent/helm/values/:releasename/:namespace", limitAgentStreams, HandleAgentHelmValues)
+	r.DELETE("/api/agent/:agent/helm/delete/:release/:namespace", audit.Action("helm.delete"), limitAgentStreams, HandleAgentHelmDelete)
+	r.POST("/api/agent/:agent/helm/install", audit.Action("helm.install"), limitAgentStreams, HandleAgentHelmInstall)
 
 	// Local helm operations
-	r.POST("/api/helm/install", HandleLocalHelmInstall)
+	r.POST("/api/helm/install", audit.Action("helm.install"), HandleLocalHelmInstall)
 	r.GET("/api/helm/values/:release", HandleHelmShowValues)
 	r.GET("/api/helm/repos", HandleHelmReposList)
 	r.GET("/api/helm/charts/:repo", HandleHelmChartsList)
@@ -456,5 +515,5 @@ func RegisterHelmRoutes(r *gin.Engine) {
 	r.GET("/api/secrets/tls", HandleTLSSecretsList)
 
 	// Namespace deployment status (for deploy wizard)
-	r.GET("/api/agent/:agent/namespace/:ns/status", HandleNamespaceDeploymentStatus)
+	r.GET("/api/agent/:agent/namespace/:ns/status", limitAgentStreams, HandleNamespaceDeploymentStatus)
 }
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateLabels applies the Kubernetes label syntax so agent labels work
+// with the same selectors used everywhere else.
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %q for %q: %s", value, key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// agentLabelsOf and agentLabelsSnapshot are used by the keycloak middleware
+// for label-based grants.
+func agentLabelsOf(agentName string) map[string]string {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+
+	conn, exists := AgentConnections[agentName]
+	if !exists {
+		return nil
+	}
+	labels := make(map[string]string, len(conn.agent.Metadata.Labels))
+	for k, v := range conn.agent.Metadata.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func agentLabelsSnapshot() map[string]map[string]string {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+
+	snapshot := make(map[string]map[string]string, len(AgentConnections))
+	for name, conn := range AgentConnections {
+		labels := make(map[string]string, len(conn.agent.Metadata.Labels))
+		for k, v := range conn.agent.Metadata.Labels {
+			labels[k] = v
+		}
+		snapshot[name] = labels
+	}
+	return snapshot
+}
+
+func setAgentLabels(agentName string, labels map[string]string) (Agent, error) {
+	agentsMutex.Lock()
+	conn, exists := AgentConnections[agentName]
+	if !exists {
+		agentsMutex.Unlock()
+		return Agent{}, errAgentNotFound
+	}
+	conn.agent.Metadata.Labels = labels
+	record := conn.agent
+	agentsMutex.Unlock()
+
+	persistAgent(record)
+	return record, nil
+}
+
+// UpdateAgentLabelsHandler replaces all labels of an agent. Labels drive
+// label-based access grants, so only superadmins can change them.
+func UpdateAgentLabelsHandler(c *gin.Context) {
+	if !isSuperAdmin(c) {
+		log.Warn().Str("user", currentUser(c)).Msg("Unauthorized attempt to update agent labels")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only superadmin can update agent labels"})
+		return
+	}
+
+	agentName := c.Param("agent")
+
+	var req struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := validateLabels(req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := setAgentLabels(agentName, req.Labels)
+	if err != nil {
+		if errors.Is(err, errAgentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info().
+		Str("user", currentUser(c)).
+		Str("agent", agentName).
+		Interface("labels", req.Labels).
+		Msg("Agent labels updated")
+
+	c.JSON(http.StatusOK, gin.H{
+		"agent":  agentName,
+		"labels": record.Metadata.Labels,
+	})
+}
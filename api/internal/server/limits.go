@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// legacyResponseBuffer is the response channel size for agents without flow
+// control, they send as fast as they can read.
+const legacyResponseBuffer = 10000
+
+var (
+	// Concurrent tunneled streams (proxy requests, terminals, ...) allowed per
+	// agent and per user, 0 disables the limit
+	maxStreamsPerAgent = 32
+	maxStreamsPerUser  = 16
+	// streamWindow is how many DATA chunks an agent may have in flight on a
+	// stream before the server grants more credit
+	streamWindow = 64
+
+	streamsMu    sync.Mutex
+	agentStreams = map[string]int{}
+	userStreams  = map[string]int{}
+)
+
+func init() {
+	for env, v := range map[string]*int{
+		"MAX_STREAMS_PER_AGENT": &maxStreamsPerAgent,
+		"MAX_STREAMS_PER_USER":  &maxStreamsPerUser,
+		"STREAM_WINDOW":         &streamWindow,
+	} {
+		s := os.Getenv(env)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || (env == "STREAM_WINDOW" && n == 0) {
+			log.Warn().Str(env, s).Msg("Invalid stream limit, using default")
+			continue
+		}
+		*v = n
+	}
+}
+
+// limitAgentStreams holds a stream slot for the whole request on routes that
+// tunnel to the :agent of the path.
+func limitAgentStreams(c *gin.Context) {
+	release, ok := acquireStream(c, c.Param("agent"))
+	if !ok {
+		c.Abort()
+		return
+	}
+	defer release()
+	c.Next()
+}
+
+// acquireStream reserves a concurrent stream for the agent and the calling
+// user. When either is at its limit it answers 429 and returns false,
+// otherwise the caller must call release once the stream is done.
+func acquireStream(c *gin.Context, agentName string) (release func(), ok bool) {
+	user := currentUser(c)
+
+	streamsMu.Lock()
+	scope, limit := "", 0
+	switch {
+	case maxStreamsPerAgent > 0 && agentStreams[agentName] >= maxStreamsPerAgent:
+		scope, limit = "agent", maxStreamsPerAgent
+	case maxStreamsPerUser > 0 && user != "" && userStreams[user] >= maxStreamsPerUser:
+		scope, limit = "user", maxStreamsPerUser
+	default:
+		agentStreams[agentName]++
+		if user != "" {
+			userStreams[user]++
+		}
+	}
+	streamsMu.Unlock()
+
+	if scope != "" {
+		log.Warn().Str("agent", agentName).Str("user", user).Str("scope", scope).Int("limit", limit).Msg("Rejected stream over concurrency limit")
+		msg := fmt.Sprintf("agent %s has too many concurrent requests (limit %d), retry later", agentName, limit)
+		if scope == "user" {
+			msg = fmt.Sprintf("you have too many concurrent requests (limit %d), retry later", limit)
+		}
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": msg, "scope": scope, "limit": limit})
+		return nil, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			streamsMu.Lock()
+			defer streamsMu.Unlock()
+			if agentStreams[agentName]--; agentStreams[agentName] <= 0 {
+				delete(agentStreams, agentName)
+			}
+			if user != "" {
+				if userStreams[user]--; userStreams[user] <= 0 {
+					delete(userStreams, user)
+				}
+			}
+		})
+	}, true
+}
+
+// streamFlow hands credit back to the agent as the handler consumes DATA
+// chunks, so a slow client slows the agent down instead of filling memory.
+type streamFlow struct {
+	agent    *AgentConnection
+	streamID string
+	window   int
+	consumed int
+}
+
+// newStreamFlow returns the response channel for a proxy stream and the flow
+// tracking it. Agents without flow control get an unlimited window.
+func newStreamFlow(agent *AgentConnection, streamID string) (chan *pb.ProxyResponse, *streamFlow) {
+	agentsMutex.RLock()
+	enabled := slices.Contains(agentCapabilities(agent.agent), pb.CapabilityFlowControl)
+	agentsMutex.RUnlock()
+
+	if !enabled {
+		return make(chan *pb.ProxyResponse, legacyResponseBuffer), &streamFlow{agent: agent, streamID: streamID}
+	}
+	// HEADERS, END and ERROR don't take credit, leave room for them
+	return make(chan *pb.ProxyResponse, streamWindow+4), &streamFlow{agent: agent, streamID: streamID, window: streamWindow}
+}
+
+// Window is what the agent is told it may send up front, 0 for unlimited.
+func (f *streamFlow) Window() int32 {
+	return int32(f.window)
+}
+
+// Consumed records a DATA chunk written to the client, granting credit in
+// batches of half the window.
+func (f *streamFlow) Consumed() {
+	if f.window == 0 {
+		return
+	}
+	f.consumed++
+	if f.consumed < (f.window+1)/2 {
+		return
+	}
+	err := f.agent.sendMessage(&pb.ServerMessage{
+		Message: &pb.ServerMessage_Credit{
+			Credit: &pb.FlowCredit{
+				StreamId: f.streamID,
+				Credits:  int32(f.consumed),
+			},
+		},
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("stream_id", f.streamID).Msg("Failed to send flow credit")
+		return
+	}
+	f.consumed = 0
+}
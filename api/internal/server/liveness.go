@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+var (
+	// statusUpdateInterval must match the agents' --status-interval
+	statusUpdateInterval = 30 * time.Second
+	// Missed status updates before an agent is degraded, and before it is
+	// considered offline and its stream is closed
+	degradedAfterMissed = 2
+	offlineAfterMissed  = 5
+)
+
+func init() {
+	if v := os.Getenv("AGENT_STATUS_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			statusUpdateInterval = d
+		} else {
+			log.Warn().Str("value", v).Msg("Invalid AGENT_STATUS_INTERVAL, using default")
+		}
+	}
+}
+
+// monitorAgentLiveness flips connected agents to degraded and then offline
+// when their status updates stop arriving without the stream closing.
+func monitorAgentLiveness() {
+	ticker := time.NewTicker(statusUpdateInterval / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkAgentLiveness(time.Now())
+	}
+}
+
+func checkAgentLiveness(now time.Time) {
+	type transition struct {
+		conn   *AgentConnection
+		name   string
+		status store.AgentStatus
+		reason string
+		record Agent
+	}
+	transitions := []transition{}
+
+	degradedAfter := time.Duration(degradedAfterMissed) * statusUpdateInterval
+	offlineAfter := time.Duration(offlineAfterMissed) * statusUpdateInterval
+
+	agentsMutex.Lock()
+	for name, conn := range AgentConnections {
+		if conn.stream == nil || !conn.agent.Connected {
+			continue
+		}
+		silence := now.Sub(conn.agent.LastSeen)
+
+		next := conn.agent.Status
+		switch {
+		case silence >= offlineAfter:
+			next = store.AgentOffline
+		case silence >= degradedAfter:
+			next = store.AgentDegraded
+		}
+		if next == conn.agent.Status {
+			continue
+		}
+
+		conn.agent.Status = next
+		transitions = append(transitions, transition{
+			conn:   conn,
+			name:   name,
+			status: next,
+			reason: fmt.Sprintf("no status update for %s", silence.Round(time.Second)),
+			record: conn.agent,
+		})
+	}
+	agentsMutex.Unlock()
+
+	for _, t := range transitions {
+		persistAgent(t.record)
+		switch t.status {
+		case store.AgentDegraded:
+			log.Warn().Str("agent", t.name).Str("reason", t.reason).Msg("Agent degraded")
+			recordConnectionEvent(t.name, store.EventDegraded, t.reason, "")
+		case store.AgentOffline:
+			log.Warn().Str("agent", t.name).Str("reason", t.reason).Msg("Agent offline, closing its stream")
+			recordConnectionEvent(t.name, store.EventOffline, t.reason, "")
+			// The stream is most likely half open, closing it lets the agent reconnect
+			t.conn.terminate(status.Error(codes.DeadlineExceeded, t.reason))
+		}
+	}
+}
+
+// GetAgentHistoryHandler returns the connection and liveness timeline of an agent, newest first.
+func GetAgentHistoryHandler(c *gin.Context) {
+	agentName := c.Param("agent")
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	agentsMutex.RLock()
+	conn, exists := AgentConnections[agentName]
+	var record Agent
+	if exists {
+		record = conn.agent
+	}
+	agentsMutex.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+	if registry == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "agent registry is not initialized"})
+		return
+	}
+
+	events, err := registry.ListConnectionEvents(agentName, limit)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to list connection events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"agent":     agentName,
+		"status":    record.Status,
+		"connected": record.Connected,
+		"lastSeen":  record.LastSeen,
+		"events":    events,
+	})
+}
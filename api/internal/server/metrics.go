@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// DefaultMetricsAddress serves the API's /metrics. It is kept off the public
+// API port so only what reaches the pod can scrape it.
+const DefaultMetricsAddress = ":9090"
+
+// metricsAddress reads METRICS_ADDRESS, set empty to disable metrics.
+func metricsAddress() string {
+	if addr, ok := os.LookupEnv("METRICS_ADDRESS"); ok {
+		return addr
+	}
+	return DefaultMetricsAddress
+}
+
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	log.Info().Str("address", addr).Msg("Serving metrics")
+	if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error().Err(err).Msg("Metrics listener stopped")
+	}
+}
+
+// helmOperations names the helm requests counted in gen3_helm_operations_total
+var helmOperations = map[string]string{
+	"helmInstallRequest": "install",
+	"helmDeleteRequest":  "delete",
+	"helmValuesRequest":  "values",
+}
+
+// agentConnectionStates feeds gen3_agent_connected.
+func agentConnectionStates() map[string]bool {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+	states := make(map[string]bool, len(AgentConnections))
+	for name, conn := range AgentConnections {
+		states[name] = conn.agent.Connected
+	}
+	return states
+}
+
+// agentRequestMetrics times one request sent to an agent and counts its
+// outcome once done is called.
+type agentRequestMetrics struct {
+	agent    string
+	kind     string
+	start    time.Time
+	answered bool
+	err      error
+}
+
+func newAgentRequestMetrics(agentID, kind string) *agentRequestMetrics {
+	return &agentRequestMetrics{agent: agentID, kind: kind, start: time.Now()}
+}
+
+// answer records a response of the agent, the first one ends the timing.
+// err is the error it answered with if any.
+func (m *agentRequestMetrics) answer(err error) {
+	if !m.answered {
+		m.answered = true
+		metrics.AgentRequestDuration.WithLabelValues(m.agent, m.kind).Observe(time.Since(m.start).Seconds())
+	}
+	m.fail(err)
+}
+
+// fail records why the request failed, the first error wins.
+func (m *agentRequestMetrics) fail(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+func (m *agentRequestMetrics) done() {
+	code := "ok"
+	if m.err != nil {
+		code = pb.TunnelErrorFor(m.err).CodeName()
+	}
+	metrics.AgentRequests.WithLabelValues(m.agent, m.kind, code).Inc()
+
+	if op, ok := helmOperations[m.kind]; ok {
+		outcome := code
+		if outcome == "ok" {
+			outcome = "success"
+		}
+		metrics.HelmOperations.WithLabelValues(m.agent, op, outcome).Inc()
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// portForwardOpenTimeout bounds how long the agent may take to connect to the target
+const portForwardOpenTimeout = 30 * time.Second
+
+func portForwardKind(kind string) string {
+	switch kind {
+	case "pod", "pods", "po":
+		return "pod"
+	case "service", "services", "svc":
+		return "service"
+	}
+	return ""
+}
+
+// HandlePortForward opens a TCP connection to a pod or service port in the
+// agent's cluster and relays it over a WebSocket, binary messages carry the
+// raw bytes in both directions. Each WebSocket is one TCP connection. It
+// reaches any port, databases included, so it needs write access.
+func HandlePortForward(c *gin.Context) {
+	agentID := c.Param("agent")
+	namespace := c.Param("namespace")
+	kind := portForwardKind(c.Param("kind"))
+	name := c.Param("name")
+
+	if !canWriteAgent(c) {
+		log.Warn().Str("user", currentUser(c)).Str("agent", agentID).Msg("Unauthorized attempt to port-forward")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write permission required"})
+		return
+	}
+
+	port, err := strconv.Atoi(c.Param("port"))
+	if err != nil || port < 1 || port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "port must be between 1 and 65535"})
+		return
+	}
+	if kind == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be pod or service"})
+		return
+	}
+
+	if !requireCapability(c, agentID, pb.CapabilityPortForward) {
+		return
+	}
+
+	agentsMutex.RLock()
+	agent, exists := AgentConnections[agentID]
+	agentsMutex.RUnlock()
+	if !exists || agent.stream == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not connected"})
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("WS upgrade failed")
+		return
+	}
+
+	streamID := uuid.New().String()
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	frames := make(chan *pb.PortForward, streamWindow+4)
+
+	agent.mutex.Lock()
+	agent.portForwards[streamID] = frames
+	agent.cancelFuncs[streamID] = cancel
+	agent.contexts[streamID] = ctx
+	agent.mutex.Unlock()
+
+	flow := &streamFlow{agent: agent, streamID: streamID, window: streamWindow}
+	upload := agent.openUpload(streamID, streamWindow)
+
+	// ended is set once either side finished the stream, otherwise the agent
+	// is told to drop it when we leave
+	var ended atomic.Bool
+	defer func() {
+		cancel()
+		if !ended.Load() {
+			agent.sendPortForward(&pb.PortForward{
+				StreamId: streamID,
+				Type:     pb.PortForwardType_PORT_FORWARD_ERROR,
+				Error:    "port-forward closed by the server",
+			})
+		}
+		agent.closeUpload(streamID)
+		agent.mutex.Lock()
+		delete(agent.portForwards, streamID)
+		delete(agent.cancelFuncs, streamID)
+		delete(agent.contexts, streamID)
+		agent.mutex.Unlock()
+		ws.Close()
+	}()
+
+	closeWS := func(code int, reason string) {
+		msg := websocket.FormatCloseMessage(code, reason)
+		ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	}
+
+	logger := log.With().
+		Str("stream_id", streamID).
+		Str("agent", agentID).
+		Str("target", kind+"/"+namespace+"/"+name).
+		Int("port", port).
+		Logger()
+
+	err = agent.sendPortForward(&pb.PortForward{
+		StreamId:  streamID,
+		Type:      pb.PortForwardType_PORT_FORWARD_OPEN,
+		Namespace: namespace,
+		Kind:      kind,
+		Name:      name,
+		Port:      int32(port),
+		Window:    int32(streamWindow),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("[port-forward] Failed to send open request to agent")
+		closeWS(websocket.CloseInternalServerErr, "failed to reach agent")
+		return
+	}
+
+	select {
+	case frame := <-frames:
+		if frame.Type != pb.PortForwardType_PORT_FORWARD_OPEN {
+			ended.Store(true)
+			logger.Warn().Str("error", frame.Error).Msg("[port-forward] Agent could not connect to target")
+			closeWS(websocket.CloseInternalServerErr, frame.Error)
+			return
+		}
+	case <-time.After(portForwardOpenTimeout):
+		logger.Warn().Msg("[port-forward] Timed out waiting for agent to connect to target")
+		closeWS(websocket.CloseInternalServerErr, "timed out connecting to target")
+		return
+	case <-ctx.Done():
+		return
+	}
+	logger.Info().Msg("[port-forward] Stream open")
+
+	// Client to agent
+	go func() {
+		defer cancel()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					agent.sendPortForward(&pb.PortForward{StreamId: streamID, Type: pb.PortForwardType_PORT_FORWARD_CLOSE})
+					ended.Store(true)
+				}
+				return
+			}
+			for len(msg) > 0 {
+				n := min(len(msg), requestChunkSize)
+				if err := upload.take(ctx); err != nil {
+					return
+				}
+				if err := agent.sendPortForward(&pb.PortForward{
+					StreamId: streamID,
+					Type:     pb.PortForwardType_PORT_FORWARD_DATA,
+					Data:     msg[:n],
+				}); err != nil {
+					logger.Warn().Err(err).Msg("[port-forward] gRPC send failed")
+					return
+				}
+				msg = msg[n:]
+			}
+		}
+	}()
+
+	// Agent to client
+	for {
+		select {
+		case frame := <-frames:
+			switch frame.Type {
+			case pb.PortForwardType_PORT_FORWARD_DATA:
+				if err := ws.WriteMessage(websocket.BinaryMessage, frame.Data); err != nil {
+					logger.Warn().Err(err).Msg("[port-forward] WS write failed")
+					return
+				}
+				flow.Consumed()
+			case pb.PortForwardType_PORT_FORWARD_CLOSE:
+				ended.Store(true)
+				logger.Info().Msg("[port-forward] Target closed the connection")
+				closeWS(websocket.CloseNormalClosure, "")
+				return
+			case pb.PortForwardType_PORT_FORWARD_ERROR:
+				ended.Store(true)
+				logger.Warn().Str("error", frame.Error).Msg("[port-forward] Stream failed on the agent")
+				closeWS(websocket.CloseInternalServerErr, frame.Error)
+				return
+			}
+		case <-ctx.Done():
+			logger.Info().Msg("[port-forward] Client closed the connection")
+			return
+		}
+	}
+}
+
+func (a *AgentConnection) sendPortForward(msg *pb.PortForward) error {
+	return a.sendMessage(&pb.ServerMessage{
+		Message: &pb.ServerMessage_PortForward{
+			PortForward: msg,
+		},
+	})
+}
+
+// deliverPortForward hands a frame from the agent to its handler.
+func (a *AgentConnection) deliverPortForward(msg *pb.PortForward) {
+	a.mutex.Lock()
+	frames, ok := a.portForwards[msg.StreamId]
+	ctx := a.contexts[msg.StreamId]
+	a.mutex.Unlock()
+	if !ok {
+		log.Trace().Str("stream_id", msg.StreamId).Msg("Port-forward frame for a finished stream")
+		return
+	}
+	select {
+	case frames <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// RegisterPortForwardRoutes registers the WebSocket port-forward route
+func RegisterPortForwardRoutes(r *gin.Engine) {
+	r.GET("/api/agents/:agent/portforward/:namespace/:kind/:name/:port", audit.Action("portforward.open"), limitAgentStreams, HandlePortForward)
+}
@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
 )
 
@@ -19,20 +23,42 @@ func HandleK8sProxyRequest(c *gin.Context) {
 	path := c.Param("path")
 	queryString := c.Request.URL.Query().Encode()
 
+	if !requireCapability(c, agentID, pb.CapabilityK8sProxy) {
+		return
+	}
+	// exec, attach and port-forward switch protocols and need a raw stream
+	upgrade := isUpgradeRequest(c.Request)
+	if upgrade && !requireCapability(c, agentID, pb.CapabilityK8sUpgrade) {
+		return
+	}
+
 	var wg sync.WaitGroup
+	requestMetrics := newAgentRequestMetrics(agentID, "k8sProxy")
+	defer requestMetrics.done()
 
 	agentsMutex.RLock()
 	agent, exists := AgentConnections[agentID]
 	agentsMutex.RUnlock()
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		requestMetrics.fail(agentNotConnected(agentID))
+		writeTunnelError(c, agentNotConnected(agentID))
 		log.Warn().Msgf("Agent not found: %s", agentID)
 		return
 	}
 
 	streamID := uuid.New().String()
-	responseChan := make(chan *pb.ProxyResponse, 10000)
-	ctx, cancel := context.WithCancel(c.Request.Context())
+	var rec *terminalRecording
+	if upgrade {
+		var ok bool
+		if rec, ok = startProxyShellRecording(c, streamID, agentID, path); !ok {
+			return
+		}
+		defer rec.finish()
+	}
+	responseChan, flow := newStreamFlow(agent, streamID)
+	spanCtx, span := startTunnelSpan(c.Request.Context(), "tunnel k8s proxy", agentID, streamID)
+	defer span.End()
+	ctx, cancel := context.WithCancel(spanCtx)
 
 	log.Info().
 		Str("stream_id", streamID).
@@ -95,6 +121,8 @@ func HandleK8sProxyRequest(c *gin.Context) {
 		Path:      path + "?" + queryString,
 		Headers:   make(map[string]string),
 		Body:      nil,
+		Window:    flow.Window(),
+		Upgrade:   upgrade,
 		ProxyType: "k8s",
 	}
 
@@ -102,7 +130,12 @@ func HandleK8sProxyRequest(c *gin.Context) {
 		proxyReq.Headers[k] = strings.Join(v, ",")
 	}
 
-	if c.Request.Body != nil {
+	// Large uploads go to the agent in chunks instead of one message
+	streamBody := !upgrade && c.Request.Body != nil && streamsRequestBody(agent, flow, c.Request.ContentLength)
+	// After switching protocols the client's side of the stream is the body
+	if streamBody || upgrade {
+		proxyReq.BodyStreamed = true
+	} else if c.Request.Body != nil {
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read request body"})
@@ -115,16 +148,32 @@ func HandleK8sProxyRequest(c *gin.Context) {
 		Message: &pb.ServerMessage_Proxy{
 			Proxy: proxyReq,
 		},
+		TraceContext: tracing.Inject(ctx),
 	})
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[proxy-handler] Failed to send request to agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request to agent"})
+		requestMetrics.fail(agentSendFailed(err))
+		writeTunnelError(c, agentSendFailed(err))
 		return
 	}
 
+	if streamBody {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := agent.sendRequestBody(ctx, streamID, c.Request.Body, int(flow.Window()))
+			if err != nil && ctx.Err() == nil {
+				log.Warn().
+					Err(err).
+					Str("stream_id", streamID).
+					Msg("[proxy-handler] Failed to stream request body to agent")
+			}
+		}()
+	}
+
 	log.Info().
 		Str("stream_id", streamID).
 		Str("agent", agentID).
@@ -132,8 +181,25 @@ func HandleK8sProxyRequest(c *gin.Context) {
 
 	var responseStarted bool
 	chunkCount := 0
+	// Until the agent answers, a stuck agent ends the request with a 504
+	firstResponse := time.NewTimer(agentRequestTimeout)
+	defer firstResponse.Stop()
 	for {
 		select {
+		case <-firstResponse.C:
+			if responseStarted {
+				continue
+			}
+			log.Warn().
+				Str("stream_id", streamID).
+				Dur("timeout", agentRequestTimeout).
+				Msg("[proxy-handler] Agent did not answer in time")
+			failSpan(span, agentTimedOut(agentID))
+			requestMetrics.fail(agentTimedOut(agentID))
+			writeTunnelError(c, agentTimedOut(agentID))
+			// Cancelling sends CANCEL so the agent drops the request
+			cancel()
+			return
 		case resp, ok := <-responseChan:
 			if !ok {
 				log.Warn().
@@ -141,14 +207,21 @@ func HandleK8sProxyRequest(c *gin.Context) {
 					Bool("response_started", responseStarted).
 					Msg("[proxy-handler] Agent connection closed unexpectedly")
 				if !responseStarted {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Agent connection closed unexpectedly"})
+					requestMetrics.fail(agentConnectionClosed())
+					writeTunnelError(c, agentConnectionClosed())
 				}
 				return
 			}
 			switch resp.Status {
 			case pb.ProxyResponseType_HEADERS:
+				requestMetrics.answer(nil)
+				if upgrade && resp.StatusCode == http.StatusSwitchingProtocols {
+					relayUpgrade(ctx, cancel, c, &wg, agent, streamID, resp, responseChan, flow, rec)
+					return
+				}
 				if !responseStarted {
 					responseStarted = true
+					span.SetAttributes(semconv.HTTPResponseStatusCode(int(resp.StatusCode)))
 					for k, v := range resp.Headers {
 						c.Header(k, v)
 					}
@@ -161,6 +234,7 @@ func HandleK8sProxyRequest(c *gin.Context) {
 			case pb.ProxyResponseType_DATA:
 				if !responseStarted {
 					responseStarted = true
+					requestMetrics.answer(nil)
 				}
 				chunkCount++
 				_, err := c.Writer.Write(resp.Body)
@@ -173,6 +247,7 @@ func HandleK8sProxyRequest(c *gin.Context) {
 					return
 				}
 				c.Writer.Flush()
+				flow.Consumed()
 			case pb.ProxyResponseType_END:
 				log.Info().
 					Str("stream_id", streamID).
@@ -182,14 +257,18 @@ func HandleK8sProxyRequest(c *gin.Context) {
 				c.Abort()
 				return
 			case pb.ProxyResponseType_ERROR:
+				te := proxyResponseError(resp)
+				failSpan(span, te)
+				requestMetrics.answer(te)
 				log.Warn().
 					Str("stream_id", streamID).
-					Str("error", string(resp.Body)).
+					Str("code", te.CodeName()).
+					Str("error", te.Message).
 					Msg("[proxy-handler] Received ERROR from agent")
+				// Once the status went out the client only sees the body cut short
 				if !responseStarted {
-					responseStarted = true
+					writeTunnelError(c, te)
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": string(resp.Body)})
 				return
 			default:
 				log.Warn().Msgf("Unknown message type from stream %s: %T", streamID, resp)
@@ -204,6 +283,12 @@ func HandleK8sProxyRequest(c *gin.Context) {
 				Msg("[proxy-handler] Context cancelled, exiting handler")
 			if responseStarted {
 				c.Writer.Flush()
+			} else if c.Request.Context().Err() == nil {
+				// Cancelled by the agent's stream going away, not the client
+				requestMetrics.fail(agentConnectionClosed())
+				writeTunnelError(c, agentConnectionClosed())
+			} else {
+				requestMetrics.fail(c.Request.Context().Err())
 			}
 			return
 		}
@@ -219,21 +304,30 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 		return
 	}
 
+	if !requireCapability(c, agentID, pb.CapabilityHTTPProxy) {
+		return
+	}
+
 	var wg sync.WaitGroup
+	requestMetrics := newAgentRequestMetrics(agentID, "httpProxy")
+	defer requestMetrics.done()
 
 	agentsMutex.RLock()
 	agent, exists := AgentConnections[agentID]
 	agentsMutex.RUnlock()
 
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		requestMetrics.fail(agentNotConnected(agentID))
+		writeTunnelError(c, agentNotConnected(agentID))
 		log.Warn().Msgf("Agent not found: %s", agentID)
 		return
 	}
 
 	streamID := uuid.New().String()
-	responseChan := make(chan *pb.ProxyResponse, 10000)
-	ctx, cancel := context.WithCancel(c.Request.Context())
+	responseChan, flow := newStreamFlow(agent, streamID)
+	spanCtx, span := startTunnelSpan(c.Request.Context(), "tunnel http proxy", agentID, streamID)
+	defer span.End()
+	ctx, cancel := context.WithCancel(spanCtx)
 
 	log.Info().
 		Str("stream_id", streamID).
@@ -293,6 +387,7 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 		Path:      targetURL,
 		Headers:   make(map[string]string),
 		Body:      nil,
+		Window:    flow.Window(),
 		ProxyType: "http",
 	}
 
@@ -300,7 +395,11 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 		proxyReq.Headers[k] = strings.Join(v, ",")
 	}
 
-	if c.Request.Body != nil {
+	// Large uploads go to the agent in chunks instead of one message
+	streamBody := c.Request.Body != nil && streamsRequestBody(agent, flow, c.Request.ContentLength)
+	if streamBody {
+		proxyReq.BodyStreamed = true
+	} else if c.Request.Body != nil {
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read request body"})
@@ -313,16 +412,32 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 		Message: &pb.ServerMessage_Proxy{
 			Proxy: proxyReq,
 		},
+		TraceContext: tracing.Inject(ctx),
 	})
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("stream_id", streamID).
 			Msg("[proxy-handler] Failed to send request to agent")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request to agent"})
+		requestMetrics.fail(agentSendFailed(err))
+		writeTunnelError(c, agentSendFailed(err))
 		return
 	}
 
+	if streamBody {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := agent.sendRequestBody(ctx, streamID, c.Request.Body, int(flow.Window()))
+			if err != nil && ctx.Err() == nil {
+				log.Warn().
+					Err(err).
+					Str("stream_id", streamID).
+					Msg("[proxy-handler] Failed to stream request body to agent")
+			}
+		}()
+	}
+
 	log.Info().
 		Str("stream_id", streamID).
 		Str("agent", agentID).
@@ -330,8 +445,25 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 
 	var responseStarted bool
 	chunkCount := 0
+	// Until the agent answers, a stuck agent ends the request with a 504
+	firstResponse := time.NewTimer(agentRequestTimeout)
+	defer firstResponse.Stop()
 	for {
 		select {
+		case <-firstResponse.C:
+			if responseStarted {
+				continue
+			}
+			log.Warn().
+				Str("stream_id", streamID).
+				Dur("timeout", agentRequestTimeout).
+				Msg("[proxy-handler] Agent did not answer in time")
+			failSpan(span, agentTimedOut(agentID))
+			requestMetrics.fail(agentTimedOut(agentID))
+			writeTunnelError(c, agentTimedOut(agentID))
+			// Cancelling sends CANCEL so the agent drops the request
+			cancel()
+			return
 		case resp, ok := <-responseChan:
 			if !ok {
 				log.Warn().
@@ -339,14 +471,17 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 					Bool("response_started", responseStarted).
 					Msg("[proxy-handler] Agent connection closed unexpectedly")
 				if !responseStarted {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Agent connection closed unexpectedly"})
+					requestMetrics.fail(agentConnectionClosed())
+					writeTunnelError(c, agentConnectionClosed())
 				}
 				return
 			}
 			switch resp.Status {
 			case pb.ProxyResponseType_HEADERS:
+				requestMetrics.answer(nil)
 				if !responseStarted {
 					responseStarted = true
+					span.SetAttributes(semconv.HTTPResponseStatusCode(int(resp.StatusCode)))
 					for k, v := range resp.Headers {
 						c.Header(k, v)
 					}
@@ -359,6 +494,7 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 			case pb.ProxyResponseType_DATA:
 				if !responseStarted {
 					responseStarted = true
+					requestMetrics.answer(nil)
 				}
 				chunkCount++
 				_, err := c.Writer.Write(resp.Body)
@@ -371,6 +507,7 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 					return
 				}
 				c.Writer.Flush()
+				flow.Consumed()
 			case pb.ProxyResponseType_END:
 				log.Info().
 					Str("stream_id", streamID).
@@ -380,14 +517,18 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 				c.Abort()
 				return
 			case pb.ProxyResponseType_ERROR:
+				te := proxyResponseError(resp)
+				failSpan(span, te)
+				requestMetrics.answer(te)
 				log.Warn().
 					Str("stream_id", streamID).
-					Str("error", string(resp.Body)).
+					Str("code", te.CodeName()).
+					Str("error", te.Message).
 					Msg("[proxy-handler] Received ERROR from agent")
+				// Once the status went out the client only sees the body cut short
 				if !responseStarted {
-					responseStarted = true
+					writeTunnelError(c, te)
 				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": string(resp.Body)})
 				return
 			default:
 				log.Warn().Msgf("Unknown message type from stream %s: %T", streamID, resp)
@@ -402,6 +543,12 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 				Msg("[proxy-handler] Context cancelled, exiting handler")
 			if responseStarted {
 				c.Writer.Flush()
+			} else if c.Request.Context().Err() == nil {
+				// Cancelled by the agent's stream going away, not the client
+				requestMetrics.fail(agentConnectionClosed())
+				writeTunnelError(c, agentConnectionClosed())
+			} else {
+				requestMetrics.fail(c.Request.Context().Err())
 			}
 			return
 		}
@@ -410,10 +557,11 @@ func HandleAgentHTTPProxyRequest(c *gin.Context) {
 
 // RegisterProxyRoutes registers K8s and HTTP proxy routes
 func RegisterProxyRoutes(protected *gin.RouterGroup) {
-	protected.Any("/api/k8s/:agent/proxy/*path", func(c *gin.Context) {
+	protected.Any("/api/k8s/:agent/proxy/*path", audit.Mutations("k8s.proxy"), limitAgentStreams, func(c *gin.Context) {
 		log.Info().Msgf("Proxying agent k8s request to: %s", c.Request.URL.String())
+		audit.Describe(c, c.Param("path"), "")
 		HandleK8sProxyRequest(c)
 	})
 
-	protected.Any("/api/agents/:agent/http", HandleAgentHTTPProxyRequest)
+	protected.Any("/api/agents/:agent/http", audit.Mutations("http.proxy"), limitAgentStreams, HandleAgentHTTPProxyRequest)
 }
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	"github.com/uc-cdis/gen3-admin/internal/store"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// agentPersistInterval is how often status updates write an agent whose
+// status did not change
+const agentPersistInterval = 5 * time.Minute
+
+// registry persists agents, their last status and connection history so the
+// in-memory AgentConnections map can be rebuilt after a restart.
+var registry store.Store
+
+// InitializeAgentRegistry opens the configured store, loads every persisted
+// agent into AgentConnections and imports agents that only exist as certs.
+func InitializeAgentRegistry() error {
+	s, err := store.OpenFromEnv()
+	if err != nil {
+		return err
+	}
+	registry = s
+	audit.SetStore(s)
+
+	if registry.Shared() {
+		// Replicas sharing the registry must trust and enforce the same CA and revocations
+		revocations, ok := registry.(store.RevocationStore)
+		if !ok {
+			return fmt.Errorf("shared store %T keeps no revocation list", registry)
+		}
+		ca.RequireExistingCA()
+		ca.UseRevocationBackend(storeRevocations{revocations})
+	}
+
+	agents, err := registry.ListAgents()
+	if err != nil {
+		return err
+	}
+
+	agentsMutex.Lock()
+	for _, agent := range agents {
+		// Nothing is connected right after startup, agents flip back on reconnect
+		agent.Connected = false
+		agent.Status = store.AgentOffline
+		if agent.CertExpiresAt.IsZero() {
+			agent.CertExpiresAt = ca.CertificateExpiry(agent.Certificate)
+		}
+		AgentConnections[agent.Name] = newAgentConnection(agent)
+	}
+	agentsMutex.Unlock()
+
+	log.Info().Int("agents", len(agents)).Msg("Loaded agents from registry")
+
+	if err := loadUpgradeWaves(); err != nil {
+		return err
+	}
+
+	go pruneStatusHistory()
+
+	return InitializeAgentsFromCerts()
+}
+
+// storeRevocations keeps the CA's revocation list in a shared store.
+type storeRevocations struct {
+	store store.RevocationStore
+}
+
+func (s storeRevocations) SaveRevocation(r ca.Revocation) error {
+	return s.store.SaveRevocation(store.Revocation(r))
+}
+
+func (s storeRevocations) ListRevocations() ([]ca.Revocation, error) {
+	stored, err := s.store.ListRevocations()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ca.Revocation, 0, len(stored))
+	for _, r := range stored {
+		list = append(list, ca.Revocation(r))
+	}
+	return list, nil
+}
+
+func newAgentConnection(agent Agent) *AgentConnection {
+	return &AgentConnection{
+		requestChannels: make(map[string]chan *pb.ProxyResponse),
+		cancelFuncs:     make(map[string]context.CancelFunc),
+		contexts:        make(map[string]context.Context),
+		terminalStreams: make(map[string]*websocket.Conn),
+		uploadCredits:   make(map[string]chan int32),
+		portForwards:    make(map[string]chan *pb.PortForward),
+		agent:           agent,
+		done:            make(chan struct{}),
+	}
+}
+
+// persistAgent writes the agent to the registry. Failures are logged but never
+// fail the caller, the in-memory state stays authoritative for this process.
+func persistAgent(agent Agent) {
+	if registry == nil {
+		return
+	}
+	if err := registry.SaveAgent(agent); err != nil {
+		log.Error().Err(err).Str("agent", agent.Name).Msg("Failed to persist agent")
+	}
+}
+
+func persistStatus(sample store.StatusSample) {
+	if registry == nil {
+		return
+	}
+	if err := registry.SaveStatus(sample); err != nil {
+		log.Error().Err(err).Str("agent", sample.Agent).Msg("Failed to persist agent status")
+	}
+}
+
+func recordConnectionEvent(agentName string, eventType store.ConnectionEventType, reason string, remoteAddr string) {
+	if registry == nil {
+		return
+	}
+	err := registry.RecordConnectionEvent(store.ConnectionEvent{
+		Agent:      agentName,
+		Type:       eventType,
+		Reason:     reason,
+		RemoteAddr: remoteAddr,
+		Timestamp:  time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to record connection event")
+	}
+}
+
+func forgetAgent(agentName string) {
+	if registry == nil {
+		return
+	}
+	if err := registry.DeleteAgent(agentName); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to remove agent from registry")
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// parseCertificatePEM returns nil for agents without a stored certificate.
+func parseCertificatePEM(certPEM string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// handleCertificateRenewal signs a CSR sent by a connected agent. The agent
+// keeps using its current certificate until it reconnects with the new one.
+func handleCertificateRenewal(agent *AgentConnection, agentName string, req *pb.CertificateRenewalRequest) {
+	respond := func(resp *pb.CertificateRenewalResponse) {
+		err := agent.sendMessage(&pb.ServerMessage{
+			Message: &pb.ServerMessage_CertificateRenewal{CertificateRenewal: resp},
+		})
+		if err != nil {
+			log.Error().Err(err).Str("agent", agentName).Msg("Failed to send certificate renewal response")
+		}
+	}
+
+	agentsMutex.RLock()
+	agentID := agent.agent.Id
+	revoked := agent.agent.Revoked
+	agentsMutex.RUnlock()
+
+	if revoked {
+		respond(&pb.CertificateRenewalResponse{Success: false, Message: "agent has been revoked"})
+		return
+	}
+
+	cert, certPEM, err := ca.SignAgentCSR(req.Csr, agentName, agentID)
+	if err != nil {
+		log.Warn().Err(err).Str("agent", agentName).Msg("Rejected certificate renewal request")
+		respond(&pb.CertificateRenewalResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	caPEM, err := ca.CACertificatePEM()
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to load CA certificate for renewal")
+		respond(&pb.CertificateRenewalResponse{Success: false, Message: "failed to load CA certificate"})
+		return
+	}
+
+	// Keep the cert on disk in sync so InitializeAgentsFromCerts sees the current one
+	if err := os.WriteFile(filepath.Join("certs", path.Clean(agentName+".crt")), certPEM, 0644); err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to write renewed agent certificate")
+	}
+
+	agentsMutex.Lock()
+	// The superseded certificates stay valid, keep them so revoking the
+	// agent revokes them too
+	for _, superseded := range []*x509.Certificate{parseCertificatePEM(agent.agent.Certificate), agent.peerCert} {
+		if superseded != nil {
+			agent.agent.AddCertificate(superseded.SerialNumber.String(), superseded.NotAfter)
+		}
+	}
+	agent.agent.Certificate = string(certPEM)
+	agent.agent.CertExpiresAt = cert.NotAfter
+	agent.agent.AddCertificate(cert.SerialNumber.String(), cert.NotAfter)
+	// A newer connection owns the record, it still has to learn the serial
+	current := AgentConnections[agentName]
+	if current != nil && current != agent {
+		current.agent.AddCertificate(cert.SerialNumber.String(), cert.NotAfter)
+	}
+	var record Agent
+	if current != nil {
+		record = current.agent
+	}
+	agentsMutex.Unlock()
+
+	// Deleted agents stay deleted
+	if current != nil {
+		persistAgent(record)
+	}
+
+	log.Info().
+		Str("agent", agentName).
+		Str("serial", cert.SerialNumber.String()).
+		Time("expires", cert.NotAfter).
+		Msg("Renewed agent certificate")
+
+	respond(&pb.CertificateRenewalResponse{
+		Success:       true,
+		Certificate:   certPEM,
+		CaCertificate: caPEM,
+	})
+}
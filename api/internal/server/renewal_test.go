@@ -0,0 +1,145 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"slices"
+	"testing"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// connectTestAgent registers the agent as connected with a freshly issued
+// certificate, the way it is after enrolling.
+func connectTestAgent(t *testing.T, agent Agent) (*AgentConnection, *forwardingStream, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(CertCurve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, certPEM, err := ca.IssueAgentCertificate(&key.PublicKey, agent.Name, agent.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.Certificate = string(certPEM)
+	agent.AddCertificate(cert.SerialNumber.String(), cert.NotAfter)
+	useTestAgents(t, agent)
+
+	stream := &forwardingStream{sent: make(chan *pb.ServerMessage, 16)}
+	agentsMutex.Lock()
+	conn := AgentConnections[agent.Name]
+	conn.stream = stream
+	conn.peerCert = cert
+	agentsMutex.Unlock()
+	return conn, stream, cert
+}
+
+// renew sends a renewal request for the agent and returns the answer.
+func renew(t *testing.T, conn *AgentConnection, stream *forwardingStream, csrName string) *pb.CertificateRenewalResponse {
+	t.Helper()
+	handleCertificateRenewal(conn, conn.agent.Name, &pb.CertificateRenewalRequest{Csr: newTestCSR(t, csrName)})
+	select {
+	case msg := <-stream.sent:
+		return msg.GetCertificateRenewal()
+	default:
+		t.Fatal("no certificate renewal response was sent")
+		return nil
+	}
+}
+
+func TestCertificateRenewal(t *testing.T) {
+	tests := []struct {
+		name        string
+		agent       Agent
+		csrName     string
+		wantSuccess bool
+	}{
+		{"renewed", Agent{Name: "agent1", Id: "id1"}, "agent1", true},
+		{"revoked agent", Agent{Name: "agent1", Revoked: true}, "agent1", false},
+		{"CSR for another agent", Agent{Name: "agent1"}, "agent2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useTestRegistry(t)
+			useTestCA(t)
+			conn, stream, original := connectTestAgent(t, tt.agent)
+			issued := conn.agent.Certificate
+
+			resp := renew(t, conn, stream, tt.csrName)
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("renewal = %+v, want success %v", resp, tt.wantSuccess)
+			}
+
+			stored, err := s.GetAgent("agent1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			serials := []string{}
+			for _, c := range stored.Certificates {
+				serials = append(serials, c.SerialNumber)
+			}
+			if !tt.wantSuccess {
+				if len(serials) != 1 || stored.Certificate != issued {
+					t.Errorf("a failed renewal changed the stored certificates: %v", serials)
+				}
+				return
+			}
+
+			renewed := parseCertificatePEM(string(resp.Certificate))
+			if renewed == nil || renewed.Subject.CommonName != "agent1" || renewed.Subject.SerialNumber != "id1" {
+				t.Fatalf("renewal returned certificate %+v, want one for agent1", renewed)
+			}
+			// The superseded certificate stays valid until it expires, it is tracked too
+			if !slices.Contains(serials, original.SerialNumber.String()) || !slices.Contains(serials, renewed.SerialNumber.String()) {
+				t.Errorf("stored certificates = %v, want %s and %s", serials, original.SerialNumber, renewed.SerialNumber)
+			}
+			if stored.Certificate != string(resp.Certificate) || !stored.CertExpiresAt.Equal(renewed.NotAfter) {
+				t.Errorf("stored certificate is not the renewed one")
+			}
+		})
+	}
+}
+
+func TestRevokeAgentRevokesRenewedCertificates(t *testing.T) {
+	useTestRegistry(t)
+	useTestCA(t)
+	conn, stream, original := connectTestAgent(t, Agent{Name: "agent1", Id: "id1"})
+
+	serials := []string{original.SerialNumber.String()}
+	for range 2 {
+		resp := renew(t, conn, stream, "agent1")
+		if !resp.Success {
+			t.Fatalf("renewal failed: %s", resp.Message)
+		}
+		serials = append(serials, parseCertificatePEM(string(resp.Certificate)).SerialNumber.String())
+	}
+
+	if err := revokeAgent("agent1", "compromised"); err != nil {
+		t.Fatalf("revokeAgent: %v", err)
+	}
+
+	revocations, err := ca.ListRevocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := map[string]bool{}
+	for _, r := range revocations {
+		revoked[r.SerialNumber] = r.Agent == "agent1" && r.Reason == "compromised"
+	}
+	for _, serial := range serials {
+		if !revoked[serial] {
+			t.Errorf("certificate %s was not revoked, revocations: %+v", serial, revocations)
+		}
+	}
+
+	select {
+	case <-conn.done:
+	default:
+		t.Error("the revoked agent's tunnel was left open")
+	}
+	if resp := renew(t, conn, stream, "agent1"); resp.Success {
+		t.Error("a revoked agent renewed its certificate")
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// requestChunkSize keeps request body frames well below gRPC's message limit
+const requestChunkSize = 32 * 1024
+
+// streamsRequestBody reports whether the body of a proxy request should be
+// sent in RequestBody frames. Agents that can't take them get it buffered in
+// the ProxyRequest, as before.
+func streamsRequestBody(agent *AgentConnection, flow *streamFlow, contentLength int64) bool {
+	if contentLength == 0 || flow.Window() == 0 {
+		return false
+	}
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+	return slices.Contains(agentCapabilities(agent.agent), pb.CapabilityRequestStreaming)
+}
+
+// uploadWindow is what the server may still send to the agent on a stream,
+// refilled by FlowCredit from the agent.
+type uploadWindow struct {
+	credits chan int32
+	n       int
+}
+
+// take waits until one chunk may be sent.
+func (w *uploadWindow) take(ctx context.Context) error {
+	for w.n == 0 {
+		select {
+		case c := <-w.credits:
+			w.n += int(c)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	w.n--
+	return nil
+}
+
+// openUpload registers the stream so credit from the agent reaches its window.
+func (a *AgentConnection) openUpload(streamID string, window int) *uploadWindow {
+	w := &uploadWindow{credits: make(chan int32, 4), n: window}
+	a.mutex.Lock()
+	a.uploadCredits[streamID] = w.credits
+	a.mutex.Unlock()
+	return w
+}
+
+func (a *AgentConnection) closeUpload(streamID string) {
+	a.mutex.Lock()
+	delete(a.uploadCredits, streamID)
+	a.mutex.Unlock()
+}
+
+// grantUploadCredit hands credit sent by the agent to the stream's upload.
+func (a *AgentConnection) grantUploadCredit(credit *pb.FlowCredit) {
+	a.mutex.Lock()
+	credits, ok := a.uploadCredits[credit.StreamId]
+	a.mutex.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case credits <- credit.Credits:
+	default:
+		log.Warn().Str("stream_id", credit.StreamId).Msg("Dropped upload credit, the stream is not reading it")
+	}
+}
+
+// sendRequestBody streams body to the agent, never more than window chunks
+// ahead of what the agent has read. It stops when ctx is done.
+func (a *AgentConnection) sendRequestBody(ctx context.Context, streamID string, body io.Reader, window int) error {
+	upload := a.openUpload(streamID, window)
+	defer a.closeUpload(streamID)
+
+	buf := make([]byte, requestChunkSize)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if err := upload.take(ctx); err != nil {
+				return err
+			}
+			err := a.sendMessage(&pb.ServerMessage{
+				Message: &pb.ServerMessage_RequestBody{
+					RequestBody: &pb.RequestBody{
+						StreamId: streamID,
+						Data:     slices.Clone(buf[:n]),
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			end := &pb.RequestBody{StreamId: streamID, End: true}
+			if !errors.Is(readErr, io.EOF) {
+				end.Error = readErr.Error()
+			}
+			if err := a.sendMessage(&pb.ServerMessage{
+				Message: &pb.ServerMessage_RequestBody{RequestBody: end},
+			}); err != nil {
+				return err
+			}
+			if end.Error != "" {
+				return readErr
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// forwardingStream hands what the server sends to the test.
+type forwardingStream struct {
+	pb.TunnelService_ConnectServer
+	sent chan *pb.ServerMessage
+}
+
+func (s *forwardingStream) Send(msg *pb.ServerMessage) error {
+	s.sent <- msg
+	return nil
+}
+
+func TestUploadWindowTake(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  int
+		credits []int32
+		takes   int
+		wantErr bool
+	}{
+		{"within the window", 2, nil, 2, false},
+		{"past the window", 2, nil, 3, true},
+		{"refilled by credit", 2, []int32{2}, 4, false},
+		{"past the credit", 2, []int32{1}, 4, true},
+		{"empty window waits for credit", 0, []int32{1, 1}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newAgentConnection(Agent{Name: "agent1"})
+			upload := conn.openUpload("s1", tt.window)
+			defer conn.closeUpload("s1")
+			for _, c := range tt.credits {
+				conn.grantUploadCredit(&pb.FlowCredit{StreamId: "s1", Credits: c})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			var err error
+			for range tt.takes {
+				if err = upload.take(ctx); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("take error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGrantUploadCreditUnknownStream(t *testing.T) {
+	conn := newAgentConnection(Agent{Name: "agent1"})
+	upload := conn.openUpload("s1", 0)
+	conn.closeUpload("s1")
+
+	// Credit for a finished stream is dropped without blocking
+	conn.grantUploadCredit(&pb.FlowCredit{StreamId: "s1", Credits: 1})
+	conn.grantUploadCredit(&pb.FlowCredit{StreamId: "other", Credits: 1})
+	if len(upload.credits) != 0 {
+		t.Errorf("%d credits reached a closed upload", len(upload.credits))
+	}
+}
+
+func TestSendRequestBodyWaitsForCredit(t *testing.T) {
+	stream := &forwardingStream{sent: make(chan *pb.ServerMessage, 16)}
+	conn := newAgentConnection(Agent{Name: "agent1"})
+	conn.stream = stream
+
+	body := bytes.Repeat([]byte("x"), 3*requestChunkSize)
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.sendRequestBody(context.Background(), "s1", bytes.NewReader(body), 2)
+	}()
+
+	receive := func() *pb.RequestBody {
+		t.Helper()
+		select {
+		case msg := <-stream.sent:
+			return msg.GetRequestBody()
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a request body frame")
+			return nil
+		}
+	}
+
+	for range 2 {
+		if chunk := receive(); chunk.End || len(chunk.Data) != requestChunkSize {
+			t.Fatalf("got %v, want a full chunk", chunk)
+		}
+	}
+	select {
+	case msg := <-stream.sent:
+		t.Fatalf("sent %v past the window", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn.grantUploadCredit(&pb.FlowCredit{StreamId: "s1", Credits: 1})
+	if chunk := receive(); chunk.End || len(chunk.Data) != requestChunkSize {
+		t.Fatalf("got %v, want the last chunk", chunk)
+	}
+	// The END frame doesn't take credit
+	if chunk := receive(); !chunk.End || chunk.Error != "" {
+		t.Fatalf("got %v, want a clean END frame", chunk)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("sendRequestBody: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/uc-cdis/gen3-admin/internal/ca"
+	"github.com/uc-cdis/gen3-admin/internal/middleware/keycloak"
+)
+
+var errAgentNotFound = errors.New("agent not found")
+
+// isSuperAdmin checks the roles set on userInfo by the keycloak middleware.
+func isSuperAdmin(c *gin.Context) bool {
+	userInfoInterface, exists := c.Get("userInfo")
+	if !exists {
+		return false
+	}
+	userInfo, ok := userInfoInterface.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	switch r := userInfo["roles"].(type) {
+	case map[string]bool:
+		return r["superadmin"]
+	case []string:
+		for _, role := range r {
+			if role == "superadmin" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canWriteAgent reports whether the user has write access to the request's
+// agent, for GET routes that change the cluster.
+func canWriteAgent(c *gin.Context) bool {
+	return isSuperAdmin(c) || c.GetBool(keycloak.AgentWriteKey)
+}
+
+func currentUser(c *gin.Context) string {
+	userInfoInterface, _ := c.Get("userInfo")
+	if userInfo, ok := userInfoInterface.(map[string]interface{}); ok {
+		return fmt.Sprintf("%v", userInfo["username"])
+	}
+	return ""
+}
+
+// revokeAgent revokes the agent's issued certificate and the one it is
+// currently connected with, then closes its tunnel if it is online.
+func revokeAgent(agentName string, reason string) error {
+	agentsMutex.Lock()
+	conn, exists := AgentConnections[agentName]
+	if !exists {
+		agentsMutex.Unlock()
+		return errAgentNotFound
+	}
+
+	certs := []*x509.Certificate{}
+	if block, _ := pem.Decode([]byte(conn.agent.Certificate)); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		} else {
+			log.Warn().Err(err).Str("agent", agentName).Msg("Failed to parse stored agent certificate")
+		}
+	}
+	if conn.peerCert != nil {
+		certs = append(certs, conn.peerCert)
+	}
+	// Certificates the agent renewed since are still valid
+	issued := conn.agent.Certificates
+	conn.agent.Revoked = true
+	record := conn.agent
+	agentsMutex.Unlock()
+
+	// Agents that never enrolled have no certificate, marking them revoked is enough
+	if len(certs) == 0 && len(issued) == 0 {
+		log.Warn().Str("agent", agentName).Msg("Agent has no certificate to revoke")
+	}
+
+	for _, cert := range certs {
+		if err := ca.RevokeCertificate(cert, agentName, reason); err != nil {
+			return fmt.Errorf("error revoking certificate: %v", err)
+		}
+	}
+	for _, cert := range issued {
+		if err := ca.RevokeSerial(cert.SerialNumber, cert.NotAfter, agentName, reason); err != nil {
+			return fmt.Errorf("error revoking certificate %s: %v", cert.SerialNumber, err)
+		}
+	}
+
+	persistAgent(record)
+	conn.terminate(status.Error(codes.PermissionDenied, "agent certificate has been revoked"))
+
+	return nil
+}
+
+func RevokeAgentHandler(c *gin.Context) {
+	if !isSuperAdmin(c) {
+		log.Warn().Str("user", currentUser(c)).Msg("Unauthorized attempt to revoke agent")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only superadmin can revoke agents"})
+		return
+	}
+
+	agentName := c.Param("agent")
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional
+	_ = c.ShouldBindJSON(&req)
+	if req.Reason == "" {
+		req.Reason = "revoked by " + currentUser(c)
+	}
+
+	if err := revokeAgent(agentName, req.Reason); err != nil {
+		if errors.Is(err, errAgentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+			return
+		}
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to revoke agent")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Warn().
+		Str("user", currentUser(c)).
+		Str("agent", agentName).
+		Str("reason", req.Reason).
+		Msg("Agent certificate revoked")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Agent revoked",
+		"agent":   agentName,
+	})
+}
+
+func ListRevocationsHandler(c *gin.Context) {
+	revocations, err := ca.ListRevocations()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list revocations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, revocations)
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/routing"
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+const (
+	// agentLocationTTL is how long a replica's claim on an agent holds
+	// without being refreshed, a crashed replica loses its agents after it.
+	agentLocationTTL = 30 * time.Second
+	// replicaSyncInterval is how often locations are refreshed and agents
+	// held by other replicas are reloaded from the shared store.
+	replicaSyncInterval = 10 * time.Second
+)
+
+var (
+	agentRouter *routing.Router
+	// sharedRouting is set when replicas share the location registry through the store
+	sharedRouting bool
+)
+
+// InitializeRouting sets up routing between API replicas. AGENT_LOCATOR=store
+// shares agent locations through the store so the API can run several
+// replicas behind a load balancer, the default keeps them in memory for a
+// single replica. REPLICA_ID and REPLICA_ADDRESS identify this replica,
+// REPLICA_SECRET authenticates calls between replicas.
+//
+// Only a store every replica reaches can share locations: AGENT_LOCATOR=store
+// needs STORE_DRIVER=postgres, SQLite is local to one replica.
+func InitializeRouting() error {
+	id := os.Getenv("REPLICA_ID")
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("error getting hostname for replica id: %v", err)
+		}
+		id = hostname
+	}
+
+	address := os.Getenv("REPLICA_ADDRESS")
+	if address == "" {
+		host := os.Getenv("POD_IP")
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		address = "http://" + host + ":8002"
+	}
+
+	var locations routing.Registry
+	switch driver := os.Getenv("AGENT_LOCATOR"); driver {
+	case "", "memory":
+		locations = routing.NewMemoryRegistry()
+	case "store":
+		if registry == nil {
+			return fmt.Errorf("AGENT_LOCATOR=store requires the agent registry")
+		}
+		if !registry.Shared() {
+			return fmt.Errorf("AGENT_LOCATOR=store requires a store shared by all replicas such as postgres, the configured store is local to this replica")
+		}
+		locations = routing.NewStoreRegistry(registry)
+		sharedRouting = true
+	default:
+		return fmt.Errorf("unsupported agent locator: %s", driver)
+	}
+
+	secret := os.Getenv("REPLICA_SECRET")
+	if sharedRouting && secret == "" {
+		log.Warn().Msg("REPLICA_SECRET is not set, replicas can forward user requests but not make internal calls")
+	}
+
+	agentRouter = routing.NewRouter(routing.Replica{ID: id, Address: address}, locations, agentLocationTTL, secret)
+
+	log.Info().Str("replica", id).Str("address", address).Bool("shared", sharedRouting).Msg("Agent routing initialized")
+
+	go syncReplicas()
+	return nil
+}
+
+// agentHeldLocally reports whether this replica holds the agent's stream.
+func agentHeldLocally(agentName string) bool {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+	conn, exists := AgentConnections[agentName]
+	return exists && conn.stream != nil && conn.agent.Connected
+}
+
+// routeAgentRequests forwards requests for an agent to the replica holding its stream.
+func routeAgentRequests() gin.HandlerFunc {
+	if agentRouter == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return agentRouter.Middleware(agentHeldLocally)
+}
+
+// isReplicaRequest reports whether the request was made by another replica.
+func isReplicaRequest(r *http.Request) bool {
+	return agentRouter != nil && agentRouter.Internal(r)
+}
+
+func claimAgent(agentName string) {
+	if agentRouter == nil {
+		return
+	}
+	if err := agentRouter.Claim(agentName); err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to record agent location")
+	}
+}
+
+// releaseAgent drops this replica's claim on the agent. It returns false when
+// the agent has since connected to another replica, which then owns its record.
+func releaseAgent(agentName string) bool {
+	if agentRouter == nil {
+		return true
+	}
+	owner, err := agentRouter.Owner(agentName)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to look up agent location")
+	}
+	if owner != nil {
+		return false
+	}
+	if err := agentRouter.Release(agentName); err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to remove agent location")
+	}
+	return true
+}
+
+// agentOwner returns the other replica holding the agent's stream, if any.
+func agentOwner(agentName string) *routing.Replica {
+	if agentRouter == nil || agentHeldLocally(agentName) {
+		return nil
+	}
+	owner, err := agentRouter.Owner(agentName)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to look up agent location")
+		return nil
+	}
+	return owner
+}
+
+func syncReplicas() {
+	ticker := time.NewTicker(replicaSyncInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		refreshAgentLocations()
+		if sharedRouting {
+			syncRemoteAgents()
+		}
+	}
+}
+
+// refreshAgentLocations keeps this replica's claims from expiring.
+func refreshAgentLocations() {
+	held := []string{}
+	agentsMutex.RLock()
+	for name, conn := range AgentConnections {
+		if conn.stream != nil && conn.agent.Connected {
+			held = append(held, name)
+		}
+	}
+	agentsMutex.RUnlock()
+
+	for _, name := range held {
+		claimAgent(name)
+	}
+}
+
+// syncRemoteAgents reloads agents this replica doesn't hold from the store,
+// where the replica holding them persists their state. Agents created or
+// deleted on other replicas show up here the same way.
+func syncRemoteAgents() {
+	if registry == nil {
+		return
+	}
+	agents, err := registry.ListAgents()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to sync agents from registry")
+		return
+	}
+
+	remote := map[string]Agent{}
+	for _, agent := range agents {
+		if agent.Connected {
+			// The replica holding it may have died without marking it offline
+			if loc, err := agentRouter.Registry.Get(agent.Name); err == nil && (loc == nil || time.Since(loc.UpdatedAt) > agentLocationTTL) {
+				agent.Connected = false
+				agent.Status = store.AgentOffline
+			}
+		}
+		remote[agent.Name] = agent
+	}
+
+	agentsMutex.Lock()
+	for name, agent := range remote {
+		conn, exists := AgentConnections[name]
+		switch {
+		case !exists:
+			AgentConnections[name] = newAgentConnection(agent)
+		case conn.stream != nil && conn.agent.Connected:
+			// Held here, this replica's record is authoritative
+		default:
+			conn.agent = agent
+		}
+	}
+	for name, conn := range AgentConnections {
+		if _, ok := remote[name]; ok || conn.stream != nil {
+			continue
+		}
+		// Give agents created here a moment to reach the store
+		if time.Since(conn.agent.CreatedAt) > replicaSyncInterval {
+			delete(AgentConnections, name)
+		}
+	}
+	agentsMutex.Unlock()
+}
+
+type replicaUpgradeRequest struct {
+	ID      string `json:"id" binding:"required"`
+	Image   string `json:"image" binding:"required"`
+	Version string `json:"version" binding:"required"`
+}
+
+// ReplicaStartUpgradeHandler starts an agent upgrade on the replica holding
+// the agent, on behalf of the replica running the upgrade wave.
+func ReplicaStartUpgradeHandler(c *gin.Context) {
+	if !isReplicaRequest(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "replica token required"})
+		return
+	}
+	var req replicaUpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startAgentUpgrade(c.Param("agent"), req.ID, req.Image, req.Version)
+	c.Status(http.StatusAccepted)
+}
+
+// startRemoteUpgrade asks the replica holding the agent to start its upgrade.
+func startRemoteUpgrade(owner routing.Replica, agentName, upgradeID, image, version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return agentRouter.Call(ctx, owner, http.MethodPost, "/internal/agents/"+agentName+"/upgrade", replicaUpgradeRequest{
+		ID:      upgradeID,
+		Image:   image,
+		Version: version,
+	})
+}
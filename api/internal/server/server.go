@@ -13,9 +13,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 	"github.com/uc-cdis/gen3-admin/internal/aws"
 	"github.com/uc-cdis/gen3-admin/internal/k8s"
 	"github.com/uc-cdis/gen3-admin/internal/logger"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
 	"github.com/uc-cdis/gen3-admin/internal/middleware/keycloak"
 	"github.com/uc-cdis/gen3-admin/internal/runner"
 	"github.com/uc-cdis/gen3-admin/internal/terraform"
@@ -23,8 +25,8 @@ import (
 )
 
 var (
-	agentsMutex     sync.RWMutex
-	CertCurve       = elliptic.P384()
+	agentsMutex      sync.RWMutex
+	CertCurve        = elliptic.P384()
 	AgentConnections = make(map[string]*AgentConnection)
 	validAgentName   = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 )
@@ -33,7 +35,9 @@ func SetupHTTPServer() {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(logger.DefaultStructuredLogger())
+	r.Use(logger.Tracing())
 	r.Use(gin.Recovery())
+	r.Use(rejectWhileDraining())
 
 	r.Use(cors.Default())
 	r.RedirectTrailingSlash = false
@@ -42,6 +46,10 @@ func SetupHTTPServer() {
 		fmt.Println(http.ListenAndServe("localhost:6060", nil))
 	}()
 
+	keycloak.AgentLabels = agentLabelsOf
+	keycloak.AllAgentLabels = agentLabelsSnapshot
+	keycloak.InternalRequest = isReplicaRequest
+
 	mockAuth := os.Getenv("MOCK_AUTH") == "true"
 	if mockAuth {
 		log.Warn().Msg("MOCK_AUTH mode enabled - no real authentication is being applied! This should *NEVER* be used in production.")
@@ -49,12 +57,19 @@ func SetupHTTPServer() {
 	} else {
 		r.Use(keycloak.AuthMiddleware())
 	}
+	r.Use(routeAgentRequests())
 
 	// Ping
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
 
+	// Prometheus metrics, on their own address instead of the public API
+	metrics.RegisterAgentStates(agentConnectionStates)
+	if addr := metricsAddress(); addr != "" {
+		go serveMetrics(addr)
+	}
+
 	// Environment detection (public, no auth required)
 	r.GET("/api/environment", GetEnvironmentHandler)
 
@@ -75,7 +90,7 @@ func SetupHTTPServer() {
 	}
 
 	{
-		protected.Any("/api/k8s/proxy/*path", func(c *gin.Context) {
+		protected.Any("/api/k8s/proxy/*path", audit.Mutations("k8s.proxy"), func(c *gin.Context) {
 			requestPath := strings.TrimPrefix(c.Request.URL.Path, "/api/k8s/proxy")
 			c.Request.URL.Path = requestPath
 			log.Info().Msgf("Proxying request to: %s", c.Request.URL.String())
@@ -89,36 +104,38 @@ func SetupHTTPServer() {
 	RegisterProxyRoutes(protected)
 	RegisterHelmRoutes(r)
 	RegisterTerminalRoutes(r)
+	RegisterPortForwardRoutes(r)
 	RegisterDbUiRoutes(r)
+	RegisterAuditRoutes(r)
 
 	// Bootstrap endpoints (public, for workshop/onboarding)
-	r.POST("/api/bootstrap/argocd", InstallArgoCDHandler)
-	r.POST("/api/bootstrap/apps", InstallAppsHandler)
+	r.POST("/api/bootstrap/argocd", audit.Action("bootstrap.argocd"), InstallArgoCDHandler)
+	r.POST("/api/bootstrap/apps", audit.Action("bootstrap.apps"), InstallAppsHandler)
 	r.GET("/api/bootstrap/status", BootstrapStatusHandler)
-	r.POST("/api/bootstrap/alloy", InstallAlloyHandler)
+	r.POST("/api/bootstrap/alloy", audit.Action("bootstrap.alloy"), InstallAlloyHandler)
 	r.GET("/api/bootstrap/configmap", ConfigMapHandler)
-	r.POST("/api/bootstrap/configmap", ConfigMapHandler)
+	r.POST("/api/bootstrap/configmap", audit.Action("configmap.update"), ConfigMapHandler)
 
 	// Runner routes
 	store := runner.NewExecutionStore()
-	r.POST("/api/runner/execute", runner.HandleExecute(store))
+	r.POST("/api/runner/execute", audit.Action("runner.execute"), runner.HandleExecute(store))
 	r.GET("/api/runner/executions/:id", runner.HandleGetExecution(store))
 	r.GET("/api/runner/executions/:id/stream", runner.HandleStreamExecution(store))
-	r.DELETE("/api/runner/executions/:id", runner.HandleTerminate(store))
+	r.DELETE("/api/runner/executions/:id", audit.Action("runner.terminate"), runner.HandleTerminate(store))
 	r.GET("/api/runner/executions", runner.HandleListExecutions(store))
 
 	// Terraform routes
-	r.POST("/api/terraform/execute", terraform.HandleTerraformExecute())
+	r.POST("/api/terraform/execute", audit.Action("terraform.execute"), terraform.HandleTerraformExecute())
 	r.GET("/api/terraform/executions/:id", terraform.HandleGetTerraformExecution())
 	r.GET("/api/terraform/executions/:id/stream", terraform.HandleStreamTerraformExecution())
-	r.DELETE("/api/terraform/executions/:id", terraform.HandleTerminateTerraform())
+	r.DELETE("/api/terraform/executions/:id", audit.Action("terraform.terminate"), terraform.HandleTerminateTerraform())
 	r.GET("/api/terraform/executions", terraform.HandleListTerraformExecutions())
-	r.POST("/api/terraform/bootstrap-secret", terraform.HandleBootstrapAWSSecret())
+	r.POST("/api/terraform/bootstrap-secret", audit.Action("terraform.bootstrap_secret"), terraform.HandleBootstrapAWSSecret())
 
 	// AWS routes
 	r.GET("/api/aws/identity", aws.GetCallerIdentity)
 	r.GET("/api/aws/profiles", aws.ListAWSProfilesHandler)
-	r.POST("/api/aws/set-profile", aws.SetAWSProfileHandler)
+	r.POST("/api/aws/set-profile", audit.Action("aws.set_profile"), aws.SetAWSProfileHandler)
 	r.GET("/api/aws/instances", aws.ListEC2Instances)
 	r.GET("/api/aws/s3", aws.ListS3Buckets)
 
@@ -128,7 +145,7 @@ func SetupHTTPServer() {
 
 	log.Info().Msg("Starting API server")
 
-	err = r.Run(":8002")
+	err = serveHTTP(":8002", r)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error starting HTTP server")
 	}
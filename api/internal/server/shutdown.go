@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+const (
+	defaultShutdownTimeout = 25 * time.Second
+	// agentReconnectSpread spreads agents reconnecting after a drain over this window
+	agentReconnectSpread = 5 * time.Second
+)
+
+var (
+	// ShutdownTimeout bounds the whole drain, SHUTDOWN_TIMEOUT overrides it.
+	// Keep it below the pod's terminationGracePeriodSeconds.
+	ShutdownTimeout = defaultShutdownTimeout
+
+	draining atomic.Bool
+
+	serversMu  sync.Mutex
+	httpServer *http.Server
+	grpcServer *grpc.Server
+)
+
+func init() {
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ShutdownTimeout = d
+		} else {
+			log.Warn().Str("value", v).Msg("Invalid SHUTDOWN_TIMEOUT, using default")
+		}
+	}
+}
+
+// serveHTTP serves the API until Shutdown, which is not an error.
+func serveHTTP(addr string, handler http.Handler) error {
+	serversMu.Lock()
+	httpServer = &http.Server{Addr: addr, Handler: handler}
+	srv := httpServer
+	serversMu.Unlock()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// rejectWhileDraining turns away new requests once shutdown started, the
+// load balancer retries them on another replica.
+func rejectWhileDraining() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if draining.Load() && c.Request.URL.Path != "/ping" {
+			c.Header("Retry-After", "1")
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Shutdown drains the server: new requests are refused, terminal sessions
+// and port-forwards are closed since they never end on their own, agents are
+// told to reconnect elsewhere, in-flight tunnel requests get most of what is
+// left until ctx is done to finish and both servers stop.
+func Shutdown(ctx context.Context) {
+	draining.Store(true)
+	log.Info().Msg("Draining API server")
+
+	serversMu.Lock()
+	srv, gs := httpServer, grpcServer
+	serversMu.Unlock()
+
+	// Closes the listener right away and waits for in-flight HTTP requests
+	httpDone := make(chan error, 1)
+	go func() {
+		if srv == nil {
+			httpDone <- nil
+			return
+		}
+		httpDone <- srv.Shutdown(ctx)
+	}()
+
+	closeTerminalSessions()
+	closePortForwards()
+	// Agents keep serving this stream until it closes, the notice only
+	// makes them reconnect to another replica once it does
+	drainAgents("server shutting down")
+
+	waitForTunnelRequests(ctx)
+
+	if gs != nil {
+		stopped := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Warn().Msg("Agents did not disconnect in time, closing their streams")
+			gs.Stop()
+		}
+	}
+
+	if err := <-httpDone; err != nil {
+		log.Warn().Err(err).Msg("HTTP server did not shut down cleanly")
+	}
+
+	if registry != nil {
+		if err := registry.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing agent registry")
+		}
+	}
+	log.Info().Msg("API server stopped")
+}
+
+// inFlightTunnelRequests counts proxy requests still waiting on an agent.
+func inFlightTunnelRequests() int {
+	agentsMutex.RLock()
+	defer agentsMutex.RUnlock()
+
+	n := 0
+	for _, conn := range AgentConnections {
+		conn.mutex.Lock()
+		for streamID := range conn.requestChannels {
+			// Channels kept across reconnects outlive their requests
+			if ctx, ok := conn.contexts[streamID]; ok && ctx.Err() == nil {
+				n++
+			}
+		}
+		conn.mutex.Unlock()
+	}
+	return n
+}
+
+// waitForTunnelRequests waits for in-flight requests until most of the time
+// left on ctx is up, the rest is for the agents to disconnect.
+func waitForTunnelRequests(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Until(deadline)*4/5)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		n := inFlightTunnelRequests()
+		if n == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			log.Warn().Int("inFlight", n).Msg("Shutdown deadline reached with tunnel requests in flight")
+			return
+		case <-ticker.C:
+			log.Debug().Int("inFlight", n).Msg("Waiting for tunnel requests to finish")
+		}
+	}
+}
+
+// closeTerminalSessions tells the browsers of remaining terminal sessions the server is going away.
+func closeTerminalSessions() {
+	sockets := []*websocket.Conn{}
+	agentsMutex.RLock()
+	for _, conn := range AgentConnections {
+		conn.mutex.Lock()
+		for _, ws := range conn.terminalStreams {
+			sockets = append(sockets, ws)
+		}
+		conn.mutex.Unlock()
+	}
+	agentsMutex.RUnlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, ws := range sockets {
+		ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		ws.Close()
+	}
+}
+
+// closePortForwards ends the remaining port-forwards, their handlers close
+// the WebSockets and the agent's connections.
+func closePortForwards() {
+	cancels := []context.CancelFunc{}
+	agentsMutex.RLock()
+	for _, conn := range AgentConnections {
+		conn.mutex.Lock()
+		for streamID := range conn.portForwards {
+			if cancel, ok := conn.cancelFuncs[streamID]; ok {
+				cancels = append(cancels, cancel)
+			}
+		}
+		conn.mutex.Unlock()
+	}
+	agentsMutex.RUnlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// drainAgents asks every agent connected here to reconnect, which the load
+// balancer sends to another replica.
+func drainAgents(reason string) {
+	conns := []*AgentConnection{}
+	agentsMutex.RLock()
+	for _, conn := range AgentConnections {
+		if conn.stream != nil && conn.agent.Connected {
+			conns = append(conns, conn)
+		}
+	}
+	agentsMutex.RUnlock()
+
+	for _, conn := range conns {
+		err := conn.sendMessage(&pb.ServerMessage{
+			Message: &pb.ServerMessage_Drain{
+				Drain: &pb.DrainNotice{
+					Reason:           reason,
+					ReconnectDelayMs: agentReconnectSpread.Milliseconds(),
+				},
+			},
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("agent", conn.agent.Name).Msg("Failed to send drain notice")
+		}
+	}
+	log.Info().Int("agents", len(conns)).Msg("Asked agents to reconnect")
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultStatusPoints is how many buckets a query returns when no step is given
+	defaultStatusPoints = 300
+	maxStatusPoints     = 5000
+)
+
+// statusRetention bounds how much status history is kept, STATUS_RETENTION overrides it.
+var statusRetention = 7 * 24 * time.Hour
+
+func init() {
+	if v := os.Getenv("STATUS_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			statusRetention = d
+		} else {
+			log.Warn().Str("value", v).Msg("Invalid STATUS_RETENTION, using default")
+		}
+	}
+}
+
+// pruneStatusHistory periodically drops samples older than statusRetention.
+func pruneStatusHistory() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if registry != nil {
+			removed, err := registry.PruneStatus(time.Now().Add(-statusRetention))
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to prune status history")
+			} else if removed > 0 {
+				log.Debug().Int64("removed", removed).Msg("Pruned status history")
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// GetAgentStatusHistoryHandler returns an agent's status samples downsampled
+// into buckets. Query with range=6h or from/to (RFC3339), and optionally step=5m.
+func GetAgentStatusHistoryHandler(c *gin.Context) {
+	agentName := c.Param("agent")
+
+	agentsMutex.RLock()
+	_, exists := AgentConnections[agentName]
+	agentsMutex.RUnlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+	if registry == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "agent registry is not initialized"})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected RFC3339"})
+			return
+		}
+		to = t
+	}
+
+	from := to.Add(-1 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected RFC3339"})
+			return
+		}
+		from = t
+	} else if v := c.Query("range"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid range"})
+			return
+		}
+		from = to.Add(-d)
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	step := to.Sub(from) / defaultStatusPoints
+	if v := c.Query("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step"})
+			return
+		}
+		step = d
+	}
+	// Buckets smaller than the update interval would mostly be empty
+	if step < statusUpdateInterval {
+		step = statusUpdateInterval
+	}
+	if to.Sub(from)/step > maxStatusPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many points, increase step"})
+		return
+	}
+
+	points, err := registry.QueryStatus(agentName, from, to, step)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to query status history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"agent":  agentName,
+		"from":   from,
+		"to":     to,
+		"step":   step.String(),
+		"points": points,
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// defaultNodeDebugNamespace is where node debug pods run unless the request
+// names another namespace
+const defaultNodeDebugNamespace = "default"
+
+// debugImages are the images debug containers may run, the first one is the
+// default. TERMINAL_DEBUG_IMAGES replaces them.
+var debugImages = []string{"busybox:1.36"}
+
+func init() {
+	if s := os.Getenv("TERMINAL_DEBUG_IMAGES"); s != "" {
+		debugImages = nil
+		for _, image := range strings.Split(s, ",") {
+			if image = strings.TrimSpace(image); image != "" {
+				debugImages = append(debugImages, image)
+			}
+		}
+	}
+}
+
+func debugImageFrom(c *gin.Context) (string, error) {
+	image := c.Query("image")
+	if image == "" {
+		if len(debugImages) == 0 {
+			return "", fmt.Errorf("no debug image is configured")
+		}
+		return debugImages[0], nil
+	}
+	if !slices.Contains(debugImages, image) {
+		return "", fmt.Errorf("image %q is not allowed, expected one of %s", image, strings.Join(debugImages, ", "))
+	}
+	return image, nil
+}
+
+// debugSessionOptions reads what both debug endpoints take: the image and
+// the terminal options.
+func debugSessionOptions(c *gin.Context) (string, terminalOptions, bool) {
+	image, err := debugImageFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", terminalOptions{}, false
+	}
+	opts, err := terminalOptionsFrom(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", terminalOptions{}, false
+	}
+	if !requireCapability(c, c.Param("agent"), pb.CapabilityTerminalDebug) {
+		return "", terminalOptions{}, false
+	}
+	return image, opts, true
+}
+
+// HandleTerminalDebug adds an ephemeral debug container to the pod, like
+// kubectl debug, and attaches a terminal to it. ?target= shares the process
+// namespace of one of the pod's containers, for images without a shell.
+// The container stays in the pod's spec, so it needs write access.
+func HandleTerminalDebug(c *gin.Context) {
+	namespace := c.Param("namespace")
+	pod := c.Param("pod")
+	target := c.Query("target")
+	audit.Describe(c, "pod "+namespace+"/"+pod, "")
+
+	if !canWriteAgent(c) {
+		log.Warn().Str("user", currentUser(c)).Str("pod", namespace+"/"+pod).Msg("Unauthorized attempt to debug a pod")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write permission required"})
+		return
+	}
+
+	image, opts, ok := debugSessionOptions(c)
+	if !ok {
+		return
+	}
+
+	sessionID := uuid.New().String()
+	runTerminal(c, terminalSession{
+		id:        sessionID,
+		agentID:   c.Param("agent"),
+		namespace: namespace,
+		pod:       pod,
+		// Ephemeral containers stay in the pod's spec, names must not repeat
+		container: "debugger-" + sessionID[:8],
+		opts:      opts,
+		debug: map[string]any{
+			"mode":            "ephemeral",
+			"image":           image,
+			"targetContainer": target,
+		},
+		summary: fmt.Sprintf("debug %s targeting %q, %s", image, target, strings.Join(opts.Command, " ")),
+	})
+}
+
+// HandleNodeDebug starts a privileged pod on the node, with the host's
+// namespaces and its root file system at /host, and attaches a terminal to
+// it. The agent deletes the pod when the session ends. Superadmin only.
+func HandleNodeDebug(c *gin.Context) {
+	node := c.Param("node")
+	namespace := c.DefaultQuery("namespace", defaultNodeDebugNamespace)
+	audit.Describe(c, "node "+node, "")
+
+	if !isSuperAdmin(c) {
+		log.Warn().Str("user", currentUser(c)).Str("node", node).Msg("Unauthorized attempt to debug a node")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only superadmin can debug nodes"})
+		return
+	}
+
+	image, opts, ok := debugSessionOptions(c)
+	if !ok {
+		return
+	}
+
+	sessionID := uuid.New().String()
+	runTerminal(c, terminalSession{
+		id:        sessionID,
+		agentID:   c.Param("agent"),
+		namespace: namespace,
+		pod:       nodeDebugPodName(node, sessionID),
+		container: "debugger",
+		opts:      opts,
+		debug: map[string]any{
+			"mode":  "node",
+			"image": image,
+			"node":  node,
+		},
+		summary: fmt.Sprintf("node debug %s in %s, %s", image, namespace, strings.Join(opts.Command, " ")),
+	})
+}
+
+// nodeDebugPodName is node-debugger-<node>-<session>, short enough for a
+// hostname.
+func nodeDebugPodName(node, sessionID string) string {
+	name := "node-debugger-" + node
+	if len(name) > 57 {
+		name = strings.TrimRight(name[:57], "-.")
+	}
+	return name + "-" + sessionID[:5]
+}
@@ -3,110 +3,94 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
 )
 
-func HandleTerminalTest(c *gin.Context) {
-	log.Info().Msg("Hello world from terminal")
-	agentID := c.Param("agent")
-	agentsMutex.RLock()
-	agent, exists := AgentConnections[agentID]
-	agentsMutex.RUnlock()
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
-		return
-	}
+func HandleTerminalExec(c *gin.Context) {
+	namespace := c.Param("namespace")
+	pod := c.Param("pod")
+	container := c.Param("container")
+	audit.Describe(c, "pod "+namespace+"/"+pod+"/"+container, "")
 
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
-	}
-	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	opts, err := terminalOptionsFrom(c)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to upgrade to WebSocket")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	streamID := uuid.New().String()
-	ctx, cancel := context.WithCancel(c.Request.Context())
-
-	agent.mutex.Lock()
-	agent.terminalStreams[streamID] = ws
-	agent.cancelFuncs[streamID] = cancel
-	agent.contexts[streamID] = ctx
-	agent.mutex.Unlock()
-
-	err = agent.sendMessage(&pb.ServerMessage{
-		Message: &pb.ServerMessage_TerminalStream{
-			TerminalStream: &pb.TerminalStream{
-				Data:      []byte(fmt.Sprintf("INIT:%s", streamID)),
-				SessionId: streamID,
-			},
-		},
+	runTerminal(c, terminalSession{
+		id:        uuid.New().String(),
+		agentID:   c.Param("agent"),
+		namespace: namespace,
+		pod:       pod,
+		container: container,
+		opts:      opts,
+		summary:   "exec " + strings.Join(opts.Command, " "),
 	})
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send terminal init message to agent")
-		return
-	}
-
-	go func() {
-		for {
-			_, msg, err := ws.ReadMessage()
-			if err != nil {
-				log.Warn().Err(err).Msg("WebSocket read failed, closing")
-				cancel()
-				ws.Close()
-				return
-			}
-			err = agent.sendMessage(&pb.ServerMessage{
-				Message: &pb.ServerMessage_TerminalStream{
-					TerminalStream: &pb.TerminalStream{
-						Data: msg,
-					},
-				},
-			})
-			if err != nil {
-				log.Warn().Err(err).Msg("gRPC send failed, closing")
-				cancel()
-				ws.Close()
-				return
-			}
-		}
-	}()
-
-	<-ctx.Done()
-	log.Info().Msg("Done. doing cleanup now")
-	ws.Close()
+}
 
-	agent.mutex.Lock()
-	delete(agent.terminalStreams, streamID)
-	delete(agent.cancelFuncs, streamID)
-	delete(agent.contexts, streamID)
-	agent.mutex.Unlock()
+// terminalSession is what a terminal endpoint asks the agent to run.
+type terminalSession struct {
+	id        string
+	agentID   string
+	namespace string
+	pod       string
+	container string
+	opts      terminalOptions
+	// debug is the init's debug request, nil for an exec
+	debug map[string]any
+	// summary describes the session in the audit log
+	summary string
 }
 
-func HandleTerminalExec(c *gin.Context) {
-	agentID := c.Param("agent")
-	namespace := c.Param("namespace")
-	pod := c.Param("pod")
-	container := c.Param("container")
+// runTerminal relays the session between the request's WebSocket and the
+// agent until either side ends it.
+func runTerminal(c *gin.Context, session terminalSession) {
+	agentID := session.agentID
+	sessionID := session.id
+	opts := session.opts
+	audit.Describe(c, "", session.summary)
+
+	if !requireCapability(c, agentID, pb.CapabilityTerminal) {
+		return
+	}
+	// Older agents always run a TTY
+	if !opts.TTY && !requireCapability(c, agentID, pb.CapabilityTerminalControl) {
+		return
+	}
 
 	agentsMutex.RLock()
 	agent, exists := AgentConnections[agentID]
 	agentsMutex.RUnlock()
 	if !exists || agent.stream == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not connected"})
+		writeTunnelError(c, agentNotConnected(agentID))
 		return
 	}
 
+	// Sessions that must be recorded do not start without a recording
+	rec, err := startTerminalRecording(c, sessionID, agentID, session.namespace, session.pod, session.container, opts)
+	if err != nil {
+		log.Error().Err(err).Str("session", sessionID).Msg("Failed to start terminal recording")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start terminal recording: " + err.Error()})
+		return
+	}
+	defer rec.finish()
+	if rec != nil {
+		audit.Describe(c, "", session.summary+", recording "+sessionID)
+	}
+
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
@@ -116,7 +100,9 @@ func HandleTerminalExec(c *gin.Context) {
 		return
 	}
 
-	sessionID := uuid.New().String()
+	metrics.TerminalSessions.WithLabelValues(agentID).Inc()
+	defer metrics.TerminalSessions.WithLabelValues(agentID).Dec()
+
 	ctx, cancel := context.WithCancel(c.Request.Context())
 
 	agent.mutex.Lock()
@@ -128,10 +114,16 @@ func HandleTerminalExec(c *gin.Context) {
 	initPayload := map[string]any{
 		"type":      "init",
 		"sessionId": sessionID,
-		"namespace": namespace,
-		"pod":       pod,
-		"container": container,
-		"command":   []string{"sh"},
+		"namespace": session.namespace,
+		"pod":       session.pod,
+		"container": session.container,
+		"command":   opts.Command,
+		"tty":       opts.TTY,
+		"cols":      opts.Cols,
+		"rows":      opts.Rows,
+	}
+	if session.debug != nil {
+		initPayload["debug"] = session.debug
 	}
 
 	initBytes, _ := json.Marshal(initPayload)
@@ -140,29 +132,41 @@ func HandleTerminalExec(c *gin.Context) {
 		Message: &pb.ServerMessage_TerminalStream{
 			TerminalStream: &pb.TerminalStream{
 				SessionId: sessionID,
+				Type:      pb.TerminalMessageType_TERMINAL_INIT,
 				Data:      initBytes,
 			},
 		},
+		TraceContext: tracing.Inject(c.Request.Context()),
 	}); err != nil {
 		log.Error().Err(err).Msg("Failed to init exec session")
-		ws.Close()
+		closeTerminalWithError(ws, agentSendFailed(err))
 		return
 	}
 
+	control := agentSupports(agent, pb.CapabilityTerminalControl)
 	go func() {
 		defer cancel()
 		for {
-			_, msg, err := ws.ReadMessage()
+			messageType, msg, err := ws.ReadMessage()
 			if err != nil {
 				log.Warn().Err(err).Msg("WS read failed")
 				return
 			}
+			frame, err := parseTerminalMessage(messageType, msg)
+			if err != nil {
+				log.Warn().Err(err).Str("session", sessionID).Msg("Invalid terminal message")
+				continue
+			}
+			rec.frame(frame)
+			if !control {
+				if frame = legacyTerminalFrame(frame); frame == nil {
+					continue
+				}
+			}
+			frame.SessionId = sessionID
 			if err := agent.sendMessage(&pb.ServerMessage{
 				Message: &pb.ServerMessage_TerminalStream{
-					TerminalStream: &pb.TerminalStream{
-						SessionId: sessionID,
-						Data:      msg,
-					},
+					TerminalStream: frame,
 				},
 			}); err != nil {
 				log.Warn().Err(err).Msg("gRPC send failed")
@@ -179,10 +183,52 @@ func HandleTerminalExec(c *gin.Context) {
 	delete(agent.contexts, sessionID)
 	agent.mutex.Unlock()
 	ws.Close()
+
+	// Let the agent end the exec and delete debug pods, older agents keep
+	// the exec until its shell exits
+	if control {
+		if err := agent.sendMessage(&pb.ServerMessage{
+			Message: &pb.ServerMessage_TerminalStream{
+				TerminalStream: &pb.TerminalStream{
+					SessionId: sessionID,
+					Type:      pb.TerminalMessageType_TERMINAL_CLOSE,
+				},
+			},
+		}); err != nil {
+			log.Warn().Err(err).Str("session", sessionID).Msg("Failed to close terminal session on agent")
+		}
+	}
+}
+
+// closeTerminalWithError ends a session with the agent's error: a JSON
+// message the UI can show, then a close frame with code 4000 plus the HTTP
+// status, e.g. 4403 when RBAC denied the exec.
+func closeTerminalWithError(ws *websocket.Conn, te *pb.TunnelError) {
+	msg, _ := json.Marshal(map[string]any{
+		"type":      "error",
+		"error":     te.Message,
+		"code":      te.CodeName(),
+		"reason":    te.Reason,
+		"retryable": te.Retryable,
+	})
+	_ = ws.WriteMessage(websocket.TextMessage, msg)
+	reason := te.Message
+	// Close reasons must fit in a control frame
+	if len(reason) > 120 {
+		reason = strings.ToValidUTF8(reason[:120], "")
+	}
+	_ = ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(4000+te.HTTPStatus(), reason), time.Now().Add(time.Second))
+	ws.Close()
 }
 
 // RegisterTerminalRoutes registers WebSocket terminal routes
 func RegisterTerminalRoutes(r *gin.Engine) {
-	r.GET("/api/agents/:agent/terminal/test", HandleTerminalTest)
-	r.GET("/api/agents/:agent/terminal/exec/:namespace/:pod/:container", HandleTerminalExec)
+	r.GET("/api/agents/:agent/terminal/exec/:namespace/:pod/:container", audit.Action("terminal.exec"), limitAgentStreams, HandleTerminalExec)
+	r.GET("/api/agents/:agent/terminal/debug/:namespace/:pod", audit.Action("terminal.debug"), limitAgentStreams, HandleTerminalDebug)
+	r.GET("/api/agents/:agent/terminal/node/:node", audit.Action("terminal.node"), limitAgentStreams, HandleNodeDebug)
+
+	r.GET("/api/terminal/recordings", ListTerminalRecordingsHandler)
+	r.GET("/api/terminal/recordings/:id", GetTerminalRecordingHandler)
+	r.GET("/api/terminal/recordings/:id/cast", PlayTerminalRecordingHandler)
 }
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// maxTerminalSize bounds the columns and rows a client may ask for
+const maxTerminalSize = 1000
+
+// terminalCommands are the programs a terminal session may run, matched
+// against the first word of the command. TERMINAL_COMMANDS replaces them.
+var terminalCommands = []string{"sh", "/bin/sh", "bash", "/bin/bash", "psql"}
+
+func init() {
+	if s := os.Getenv("TERMINAL_COMMANDS"); s != "" {
+		terminalCommands = nil
+		for _, command := range strings.Split(s, ",") {
+			if command = strings.TrimSpace(command); command != "" {
+				terminalCommands = append(terminalCommands, command)
+			}
+		}
+	}
+}
+
+// terminalOptions is what the client asked for when opening the session.
+type terminalOptions struct {
+	Command []string
+	TTY     bool
+	Cols    uint32
+	Rows    uint32
+}
+
+// terminalOptionsFrom reads the command (one query parameter per argument,
+// e.g. ?command=psql&command=-c&command=select 1), tty and the initial
+// cols and rows. It defaults to an interactive sh.
+func terminalOptionsFrom(c *gin.Context) (terminalOptions, error) {
+	opts := terminalOptions{Command: c.QueryArray("command"), TTY: true}
+	if len(opts.Command) == 0 {
+		opts.Command = []string{"sh"}
+	}
+	if !slices.Contains(terminalCommands, opts.Command[0]) {
+		return opts, fmt.Errorf("command %q is not allowed, expected one of %s", opts.Command[0], strings.Join(terminalCommands, ", "))
+	}
+	if v := c.Query("tty"); v != "" {
+		tty, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tty: %v", err)
+		}
+		opts.TTY = tty
+	}
+	for param, size := range map[string]*uint32{"cols": &opts.Cols, "rows": &opts.Rows} {
+		if v := c.Query(param); v != "" {
+			n, err := parseTerminalSize(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s: %v", param, err)
+			}
+			*size = n
+		}
+	}
+	return opts, nil
+}
+
+func parseTerminalSize(v string) (uint32, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 || n > maxTerminalSize {
+		return 0, fmt.Errorf("expected 1 to %d", maxTerminalSize)
+	}
+	return uint32(n), nil
+}
+
+// terminalClientMessage is a typed message from the terminal UI:
+//
+//	{"type": "stdin", "data": "ls\r"}
+//	{"type": "resize", "cols": 120, "rows": 40}
+//	{"type": "signal", "signal": "SIGINT"}
+//
+// Binary frames, and text frames that are not one of these, are stdin as is.
+type terminalClientMessage struct {
+	Type   string `json:"type"`
+	Data   string `json:"data"`
+	Cols   uint32 `json:"cols"`
+	Rows   uint32 `json:"rows"`
+	Signal string `json:"signal"`
+}
+
+// parseTerminalMessage turns a WebSocket message into the frame for the agent.
+// Inits are refused: only the server starts a session, with the checked
+// options.
+func parseTerminalMessage(messageType int, msg []byte) (*pb.TerminalStream, error) {
+	if isTerminalInit(msg) {
+		return nil, errors.New("terminal init is not allowed from the client")
+	}
+	stdin := &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: msg}
+	if messageType != websocket.TextMessage || len(msg) == 0 || msg[0] != '{' {
+		return stdin, nil
+	}
+	var m terminalClientMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return stdin, nil
+	}
+	switch m.Type {
+	case "stdin":
+		if isTerminalInit([]byte(m.Data)) {
+			return nil, errors.New("terminal init is not allowed from the client")
+		}
+		return &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte(m.Data)}, nil
+	case "resize":
+		if m.Cols == 0 || m.Rows == 0 || m.Cols > maxTerminalSize || m.Rows > maxTerminalSize {
+			return nil, fmt.Errorf("invalid terminal size %dx%d, expected 1 to %d", m.Cols, m.Rows, maxTerminalSize)
+		}
+		return &pb.TerminalStream{
+			Type: pb.TerminalMessageType_TERMINAL_RESIZE,
+			Size: &pb.TerminalSize{Cols: m.Cols, Rows: m.Rows},
+		}, nil
+	case "signal":
+		if _, ok := pb.TerminalSignals[m.Signal]; !ok {
+			return nil, fmt.Errorf("unsupported signal %q", m.Signal)
+		}
+		return &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_SIGNAL, Signal: m.Signal}, nil
+	}
+	return stdin, nil
+}
+
+// isTerminalInit reports whether data is an exec request, agents without
+// terminal-control take one from stdin.
+func isTerminalInit(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	var m struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(data, &m) == nil && m.Type == "init"
+}
+
+// legacyTerminalFrame adapts a frame for agents without terminal-control,
+// they take stdin only. Signals become the control character a TTY turns
+// into them, resizes are dropped.
+func legacyTerminalFrame(frame *pb.TerminalStream) *pb.TerminalStream {
+	switch frame.Type {
+	case pb.TerminalMessageType_TERMINAL_SIGNAL:
+		return &pb.TerminalStream{Data: []byte{pb.TerminalSignals[frame.Signal]}}
+	case pb.TerminalMessageType_TERMINAL_RESIZE:
+		return nil
+	}
+	return frame
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+func TestParseTerminalMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		messageType int
+		msg         string
+		want        *pb.TerminalStream
+		wantErr     bool
+	}{
+		{
+			name:        "raw text is stdin",
+			messageType: websocket.TextMessage,
+			msg:         "ls\r",
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte("ls\r")},
+		},
+		{
+			name:        "binary is stdin",
+			messageType: websocket.BinaryMessage,
+			msg:         `{"type":"resize","cols":80,"rows":24}`,
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte(`{"type":"resize","cols":80,"rows":24}`)},
+		},
+		{
+			name:        "typed stdin",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"stdin","data":"exit\r"}`,
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte("exit\r")},
+		},
+		{
+			name:        "unknown type is stdin",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"other"}`,
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte(`{"type":"other"}`)},
+		},
+		{
+			name:        "invalid JSON is stdin",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":`,
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte(`{"type":`)},
+		},
+		{
+			name:        "resize",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"resize","cols":120,"rows":40}`,
+			want: &pb.TerminalStream{
+				Type: pb.TerminalMessageType_TERMINAL_RESIZE,
+				Size: &pb.TerminalSize{Cols: 120, Rows: 40},
+			},
+		},
+		{
+			name:        "resize to zero",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"resize","cols":0,"rows":40}`,
+			wantErr:     true,
+		},
+		{
+			name:        "resize over the maximum",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"resize","cols":120,"rows":1001}`,
+			wantErr:     true,
+		},
+		{
+			name:        "signal",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"signal","signal":"SIGINT"}`,
+			want:        &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_SIGNAL, Signal: "SIGINT"},
+		},
+		{
+			name:        "unsupported signal",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"signal","signal":"SIGKILL"}`,
+			wantErr:     true,
+		},
+		{
+			name:        "init",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"init","namespace":"kube-system","pod":"etcd","command":["sh"]}`,
+			wantErr:     true,
+		},
+		{
+			name:        "init with leading space",
+			messageType: websocket.TextMessage,
+			msg:         ` {"type":"init","namespace":"kube-system","pod":"etcd"}`,
+			wantErr:     true,
+		},
+		{
+			name:        "init as binary",
+			messageType: websocket.BinaryMessage,
+			msg:         `{"type":"init","namespace":"kube-system","pod":"etcd"}`,
+			wantErr:     true,
+		},
+		{
+			name:        "init wrapped in stdin",
+			messageType: websocket.TextMessage,
+			msg:         `{"type":"stdin","data":"{\"type\":\"init\",\"pod\":\"etcd\"}"}`,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTerminalMessage(tt.messageType, []byte(tt.msg))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Type != tt.want.Type || !bytes.Equal(got.Data, tt.want.Data) || got.Signal != tt.want.Signal {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if (got.Size == nil) != (tt.want.Size == nil) ||
+				(got.Size != nil && (got.Size.Cols != tt.want.Size.Cols || got.Size.Rows != tt.want.Size.Rows)) {
+				t.Errorf("got size %v, want %v", got.Size, tt.want.Size)
+			}
+		})
+	}
+}
+
+func TestTerminalOptionsFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    terminalOptions
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  terminalOptions{Command: []string{"sh"}, TTY: true},
+		},
+		{
+			name:  "command with arguments",
+			query: "command=psql&command=-c&command=select+1",
+			want:  terminalOptions{Command: []string{"psql", "-c", "select 1"}, TTY: true},
+		},
+		{
+			name:  "no tty and a size",
+			query: "tty=false&cols=120&rows=40",
+			want:  terminalOptions{Command: []string{"sh"}, TTY: false, Cols: 120, Rows: 40},
+		},
+		{
+			name:    "command not allowed",
+			query:   "command=rm&command=-rf&command=/",
+			wantErr: true,
+		},
+		{
+			name:    "allowed name as an argument only",
+			query:   "command=/usr/bin/env&command=sh",
+			wantErr: true,
+		},
+		{
+			name:    "invalid tty",
+			query:   "tty=maybe",
+			wantErr: true,
+		},
+		{
+			name:    "zero cols",
+			query:   "cols=0",
+			wantErr: true,
+		},
+		{
+			name:    "rows over the maximum",
+			query:   "rows=1001",
+			wantErr: true,
+		},
+		{
+			name:    "negative rows",
+			query:   "rows=-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+
+			got, err := terminalOptionsFrom(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got.Command, tt.want.Command) || got.TTY != tt.want.TTY || got.Cols != tt.want.Cols || got.Rows != tt.want.Rows {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyTerminalFrame(t *testing.T) {
+	stdin := &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_STDIN, Data: []byte("ls\r")}
+	if got := legacyTerminalFrame(stdin); got != stdin {
+		t.Errorf("stdin changed to %v", got)
+	}
+
+	resize := &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_RESIZE, Size: &pb.TerminalSize{Cols: 80, Rows: 24}}
+	if got := legacyTerminalFrame(resize); got != nil {
+		t.Errorf("resize became %v, expected it dropped", got)
+	}
+
+	signal := &pb.TerminalStream{Type: pb.TerminalMessageType_TERMINAL_SIGNAL, Signal: "SIGINT"}
+	got := legacyTerminalFrame(signal)
+	if got == nil || got.Type != pb.TerminalMessageType_TERMINAL_STDIN || !bytes.Equal(got.Data, []byte{0x03}) {
+		t.Errorf("SIGINT became %v, want Ctrl-C as stdin", got)
+	}
+}
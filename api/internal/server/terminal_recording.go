@@ -0,0 +1,366 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/recording"
+	"github.com/uc-cdis/gen3-admin/internal/store"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// recordingLabel is the agent label that turns terminal recording on or off
+// for the agent, e.g. terminal-recording=off
+const recordingLabel = "terminal-recording"
+
+const (
+	defaultRecordingLimit = 100
+	maxRecordingLimit     = 1000
+)
+
+var (
+	// recordingsDir is where the asciicast files are kept
+	recordingsDir = "recordings"
+	// recordTerminals is the default when no namespace rule or agent label
+	// applies
+	recordTerminals = true
+	// recordTerminalInput also keeps what users type. Off by default,
+	// passwords typed without echo would end up in the recording.
+	recordTerminalInput = false
+	// namespaceRecording holds the TERMINAL_RECORDING_NAMESPACES rules, keyed
+	// by "namespace" or "agent/namespace"
+	namespaceRecording = map[string]bool{}
+
+	recordingsMu sync.Mutex
+	// activeRecordings are the recorded sessions by session ID
+	activeRecordings = map[string]*terminalRecording{}
+)
+
+func init() {
+	if dir := os.Getenv("TERMINAL_RECORDINGS_DIR"); dir != "" {
+		recordingsDir = dir
+	}
+	for env, v := range map[string]*bool{
+		"TERMINAL_RECORDING":       &recordTerminals,
+		"TERMINAL_RECORDING_INPUT": &recordTerminalInput,
+	} {
+		if s := os.Getenv(env); s != "" {
+			on, ok := parseRecordingSwitch(s)
+			if !ok {
+				log.Warn().Str(env, s).Msg("Invalid terminal recording setting, using default")
+				continue
+			}
+			*v = on
+		}
+	}
+	// e.g. TERMINAL_RECORDING_NAMESPACES="kube-system=on,dev-agent/default=off"
+	for _, rule := range strings.Split(os.Getenv("TERMINAL_RECORDING_NAMESPACES"), ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		scope, value, found := strings.Cut(rule, "=")
+		on, ok := parseRecordingSwitch(value)
+		if !found || !ok || scope == "" {
+			log.Warn().Str("rule", rule).Msg("Invalid TERMINAL_RECORDING_NAMESPACES rule, ignoring")
+			continue
+		}
+		namespaceRecording[scope] = on
+	}
+}
+
+func parseRecordingSwitch(s string) (on bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "on", "true", "1", "yes", "enabled":
+		return true, true
+	case "off", "false", "0", "no", "disabled":
+		return false, true
+	}
+	return false, false
+}
+
+// shouldRecordTerminal applies the recording policy, most specific first:
+// an agent/namespace rule, a namespace rule, the agent's terminal-recording
+// label, then TERMINAL_RECORDING.
+func shouldRecordTerminal(agentName, namespace string) bool {
+	if on, ok := namespaceRecording[agentName+"/"+namespace]; ok {
+		return on
+	}
+	if on, ok := namespaceRecording[namespace]; ok {
+		return on
+	}
+	agentsMutex.RLock()
+	conn, exists := AgentConnections[agentName]
+	var label string
+	if exists {
+		label = conn.agent.Metadata.Labels[recordingLabel]
+	}
+	agentsMutex.RUnlock()
+	if on, ok := parseRecordingSwitch(label); ok {
+		return on
+	}
+	return recordTerminals
+}
+
+// terminalRecording is a session being recorded.
+type terminalRecording struct {
+	recorder *recording.Recorder
+	meta     store.TerminalRecording
+}
+
+// startTerminalRecording starts recording the session when the policy asks
+// for it and returns nil otherwise. The caller must finish it.
+func startTerminalRecording(c *gin.Context, sessionID, agentName, namespace, pod, container string, opts terminalOptions) (*terminalRecording, error) {
+	if !shouldRecordTerminal(agentName, namespace) {
+		return nil, nil
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("agent registry is not initialized")
+	}
+	if err := os.MkdirAll(recordingsDir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating recordings directory: %v", err)
+	}
+
+	meta := store.TerminalRecording{
+		ID:        sessionID,
+		Agent:     agentName,
+		User:      currentUser(c),
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		Command:   opts.Command,
+		Path:      filepath.Join(recordingsDir, sessionID+".cast"),
+		StartedAt: time.Now(),
+	}
+	f, err := os.OpenFile(meta.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("error creating recording: %v", err)
+	}
+	recorder, err := recording.NewRecorder(f, recording.Header{
+		Width:     int(opts.Cols),
+		Height:    int(opts.Rows),
+		Timestamp: meta.StartedAt.Unix(),
+		Title:     fmt.Sprintf("%s %s/%s/%s by %s", agentName, namespace, pod, container, meta.User),
+		Env: map[string]string{
+			"AGENT":     agentName,
+			"USER":      meta.User,
+			"NAMESPACE": namespace,
+			"POD":       pod,
+			"CONTAINER": container,
+			"COMMAND":   strings.Join(opts.Command, " "),
+		},
+	})
+	if err != nil {
+		os.Remove(meta.Path)
+		return nil, fmt.Errorf("error writing recording header: %v", err)
+	}
+
+	// Listed right away so sessions still running show up
+	if err := registry.SaveTerminalRecording(meta); err != nil {
+		recorder.Close()
+		os.Remove(meta.Path)
+		return nil, fmt.Errorf("error saving recording: %v", err)
+	}
+
+	rec := &terminalRecording{recorder: recorder, meta: meta}
+	recordingsMu.Lock()
+	activeRecordings[sessionID] = rec
+	recordingsMu.Unlock()
+	log.Info().Str("session", sessionID).Str("agent", agentName).Str("user", meta.User).Str("path", meta.Path).Msg("Recording terminal session")
+	return rec, nil
+}
+
+// recordTerminalOutput adds what the agent sent to the session's recording,
+// if it has one.
+func recordTerminalOutput(sessionID string, data []byte) {
+	recordingsMu.Lock()
+	rec := activeRecordings[sessionID]
+	recordingsMu.Unlock()
+	if rec == nil {
+		return
+	}
+	if err := rec.recorder.Output(data); err != nil {
+		log.Error().Err(err).Str("session", sessionID).Msg("Failed to record terminal output")
+	}
+}
+
+// output adds what the agent sent to the recording.
+func (r *terminalRecording) output(data []byte) {
+	if r == nil {
+		return
+	}
+	if err := r.recorder.Output(data); err != nil {
+		log.Error().Err(err).Str("session", r.meta.ID).Msg("Failed to record terminal output")
+	}
+}
+
+// Write adds what the user sent on a stream relayed as is. It never fails,
+// the stream goes on without the recording.
+func (r *terminalRecording) Write(data []byte) (int, error) {
+	if err := r.recorder.Input(data); err != nil {
+		log.Error().Err(err).Str("session", r.meta.ID).Msg("Failed to record terminal input")
+	}
+	return len(data), nil
+}
+
+// frame adds a message from the user: resizes, and what they typed when
+// TERMINAL_RECORDING_INPUT is on.
+func (r *terminalRecording) frame(frame *pb.TerminalStream) {
+	if r == nil {
+		return
+	}
+	var err error
+	switch frame.Type {
+	case pb.TerminalMessageType_TERMINAL_RESIZE:
+		err = r.recorder.Resize(int(frame.Size.Cols), int(frame.Size.Rows))
+	case pb.TerminalMessageType_TERMINAL_SIGNAL:
+		if recordTerminalInput {
+			err = r.recorder.Input([]byte{pb.TerminalSignals[frame.Signal]})
+		}
+	default:
+		if recordTerminalInput {
+			err = r.recorder.Input(frame.Data)
+		}
+	}
+	if err != nil {
+		log.Error().Err(err).Str("session", r.meta.ID).Msg("Failed to record terminal input")
+	}
+}
+
+// finish closes the recording and stores its end time and size.
+func (r *terminalRecording) finish() {
+	if r == nil {
+		return
+	}
+	recordingsMu.Lock()
+	delete(activeRecordings, r.meta.ID)
+	recordingsMu.Unlock()
+
+	if err := r.recorder.Close(); err != nil {
+		log.Error().Err(err).Str("session", r.meta.ID).Msg("Failed to close terminal recording")
+	}
+	ended := time.Now()
+	r.meta.EndedAt = &ended
+	r.meta.Bytes = r.recorder.Size()
+	if err := registry.SaveTerminalRecording(r.meta); err != nil {
+		log.Error().Err(err).Str("session", r.meta.ID).Msg("Failed to save terminal recording")
+	}
+}
+
+func requireRecordingAccess(c *gin.Context) bool {
+	if !isSuperAdmin(c) {
+		log.Warn().Str("user", currentUser(c)).Msg("Unauthorized attempt to read terminal recordings")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only superadmin can read terminal recordings"})
+		return false
+	}
+	if registry == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "agent registry is not initialized"})
+		return false
+	}
+	return true
+}
+
+// ListTerminalRecordingsHandler returns the newest recordings, filtered by
+// agent, user, namespace, pod, since and until (RFC 3339).
+func ListTerminalRecordingsHandler(c *gin.Context) {
+	if !requireRecordingAccess(c) {
+		return
+	}
+	filter := store.TerminalRecordingFilter{
+		Agent:     c.Query("agent"),
+		User:      c.Query("user"),
+		Namespace: c.Query("namespace"),
+		Pod:       c.Query("pod"),
+		Limit:     defaultRecordingLimit,
+	}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s: %v", param, err)})
+				return
+			}
+			*t = parsed
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxRecordingLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit, expected 1 to %d", maxRecordingLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	recordings, err := registry.ListTerminalRecordings(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list terminal recordings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recordings": recordings})
+}
+
+func getTerminalRecording(c *gin.Context) (*store.TerminalRecording, bool) {
+	rec, err := registry.GetTerminalRecording(c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("id", c.Param("id")).Msg("Failed to get terminal recording")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return rec, true
+}
+
+// GetTerminalRecordingHandler returns a recording's metadata
+func GetTerminalRecordingHandler(c *gin.Context) {
+	if !requireRecordingAccess(c) {
+		return
+	}
+	if rec, ok := getTerminalRecording(c); ok {
+		c.JSON(http.StatusOK, rec)
+	}
+}
+
+// PlayTerminalRecordingHandler serves the asciicast for asciinema-player or
+// `asciinema play`. A running session's recording holds what was written so
+// far.
+func PlayTerminalRecordingHandler(c *gin.Context) {
+	if !requireRecordingAccess(c) {
+		return
+	}
+	rec, ok := getTerminalRecording(c)
+	if !ok {
+		return
+	}
+	f, err := os.Open(rec.Path)
+	if err != nil {
+		log.Error().Err(err).Str("id", rec.ID).Str("path", rec.Path).Msg("Failed to open terminal recording")
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording file is missing"})
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Info().Str("user", currentUser(c)).Str("id", rec.ID).Msg("Playing terminal recording")
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.cast"`, rec.ID))
+	http.ServeContent(c.Writer, c.Request, rec.ID+".cast", stat.ModTime(), f)
+}
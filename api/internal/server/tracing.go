@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// startTunnelSpan times a request's trip through the tunnel, the agent's
+// spans nest under it. Put its trace context on the ServerMessage with
+// tracing.Inject.
+func startTunnelSpan(ctx context.Context, name, agentID, streamID string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen3.agent", agentID),
+			attribute.String("gen3.stream_id", streamID),
+		),
+	)
+}
+
+// failSpan marks span failed with err, keeping the tunnel error code.
+func failSpan(span trace.Span, err error) {
+	var te *pb.TunnelError
+	if errors.As(err, &te) {
+		span.SetAttributes(attribute.String("gen3.error.code", te.CodeName()))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// messageKind names the request a ServerMessage carries, e.g. "helmInstallRequest".
+func messageKind(msg *pb.ServerMessage) string {
+	m := msg.ProtoReflect()
+	field := m.WhichOneof(m.Descriptor().Oneofs().ByName("message"))
+	if field == nil {
+		return "unknown"
+	}
+	return string(field.Name())
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+// isUpgradeRequest reports whether the client asks to switch protocols, as
+// kubectl exec/attach/port-forward do with SPDY or WebSocket.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// podShellPath matches the exec and attach subresources of a pod.
+var podShellPath = regexp.MustCompile(`^/api/v1/namespaces/([^/]+)/pods/([^/]+)/(exec|attach)$`)
+
+// startProxyShellRecording records exec and attach through the k8s proxy
+// under the same policy as terminal sessions. The recording holds the stream
+// as relayed, framing of the SPDY or WebSocket protocol included. It writes
+// the error response and returns false when a required recording can't start.
+func startProxyShellRecording(c *gin.Context, streamID, agentName, apiPath string) (*terminalRecording, bool) {
+	m := podShellPath.FindStringSubmatch(path.Clean("/" + apiPath))
+	if m == nil {
+		return nil, true
+	}
+	query := c.Request.URL.Query()
+	opts := terminalOptions{Command: query["command"], TTY: query.Get("tty") == "true"}
+	rec, err := startTerminalRecording(c, streamID, agentName, m[1], m[2], query.Get("container"), opts)
+	if err != nil {
+		log.Error().Err(err).Str("stream_id", streamID).Msg("[proxy-handler] Failed to start recording of pod " + m[3])
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start terminal recording: " + err.Error()})
+		return nil, false
+	}
+	if rec != nil {
+		audit.Describe(c, "", fmt.Sprintf("%s %s/%s %s, recording %s", m[3], m[1], m[2], strings.Join(opts.Command, " "), streamID))
+	}
+	return rec, true
+}
+
+// relayUpgrade takes over the client connection once the k8s API agreed to
+// switch protocols: DATA from the agent is written to it raw and what the
+// client sends goes back as RequestBody frames. It returns when either side
+// closes, cancelling ctx so the agent drops its end. Both directions go to
+// rec when the stream is recorded, what the client sends only with
+// TERMINAL_RECORDING_INPUT on.
+func relayUpgrade(ctx context.Context, cancel context.CancelFunc, c *gin.Context, wg *sync.WaitGroup,
+	agent *AgentConnection, streamID string, resp *pb.ProxyResponse, responseChan chan *pb.ProxyResponse, flow *streamFlow, rec *terminalRecording) {
+	defer cancel()
+
+	conn, bufrw, err := c.Writer.Hijack()
+	if err != nil {
+		log.Error().Err(err).Str("stream_id", streamID).Msg("[proxy-handler] Failed to hijack connection for upgrade")
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(bufrw, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(int(resp.StatusCode)))
+	for k, v := range resp.Headers {
+		fmt.Fprintf(bufrw, "%s: %s\r\n", k, v)
+	}
+	bufrw.WriteString("\r\n")
+	if err := bufrw.Flush(); err != nil {
+		log.Warn().Err(err).Str("stream_id", streamID).Msg("[proxy-handler] Failed to write upgrade response")
+		return
+	}
+	log.Info().Str("stream_id", streamID).Str("protocol", resp.Headers["Upgrade"]).Msg("[proxy-handler] Switched protocols, relaying stream")
+
+	// Client to agent, bytes the client sent with the handshake are in bufrw
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		var body io.Reader = bufrw.Reader
+		if rec != nil && recordTerminalInput {
+			body = io.TeeReader(body, rec)
+		}
+		err := agent.sendRequestBody(ctx, streamID, body, int(flow.Window()))
+		if err != nil && ctx.Err() == nil {
+			log.Debug().Err(err).Str("stream_id", streamID).Msg("[proxy-handler] Upgraded client stream ended")
+		}
+	}()
+
+	// Agent to client
+	for {
+		select {
+		case resp, ok := <-responseChan:
+			if !ok {
+				return
+			}
+			switch resp.Status {
+			case pb.ProxyResponseType_DATA:
+				if _, err := conn.Write(resp.Body); err != nil {
+					log.Debug().Err(err).Str("stream_id", streamID).Msg("[proxy-handler] Upgraded client connection closed")
+					return
+				}
+				rec.output(resp.Body)
+				flow.Consumed()
+			case pb.ProxyResponseType_END:
+				log.Info().Str("stream_id", streamID).Msg("[proxy-handler] k8s API closed upgraded stream")
+				return
+			case pb.ProxyResponseType_ERROR:
+				log.Warn().Str("stream_id", streamID).Str("error", string(resp.Body)).Msg("[proxy-handler] Upgraded stream failed on the agent")
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+)
+
+func TestStartProxyShellRecording(t *testing.T) {
+	s, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	previousRegistry, previousDir, previousInput := registry, recordingsDir, recordTerminalInput
+	registry, recordingsDir, recordTerminalInput = s, t.TempDir(), true
+	defer func() { registry, recordingsDir, recordTerminalInput = previousRegistry, previousDir, previousInput }()
+
+	tests := []struct {
+		name          string
+		path          string
+		query         string
+		wantRecording bool
+		wantContainer string
+		wantCommand   []string
+	}{
+		{
+			name:          "exec",
+			path:          "/api/v1/namespaces/default/pods/web-0/exec",
+			query:         "container=app&command=sh&command=-c&command=ls&tty=true&stdin=true",
+			wantRecording: true,
+			wantContainer: "app",
+			wantCommand:   []string{"sh", "-c", "ls"},
+		},
+		{
+			name:          "attach",
+			path:          "/api/v1/namespaces/default/pods/web-0/attach",
+			query:         "container=app&stdin=true",
+			wantRecording: true,
+			wantContainer: "app",
+		},
+		{
+			name:          "unclean path",
+			path:          "api/v1/namespaces/default/pods/web-0/./exec",
+			query:         "command=sh",
+			wantRecording: true,
+			wantCommand:   []string{"sh"},
+		},
+		{
+			name: "port-forward",
+			path: "/api/v1/namespaces/default/pods/web-0/portforward",
+		},
+		{
+			name: "logs",
+			path: "/api/v1/namespaces/default/pods/web-0/log",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/api/k8s/test-agent/proxy?"+tt.query, nil)
+			streamID := "stream-" + string(rune('a'+i))
+
+			rec, ok := startProxyShellRecording(c, streamID, "test-agent", tt.path)
+			if !ok {
+				t.Fatal("expected the request to go on")
+			}
+			if !tt.wantRecording {
+				if rec != nil {
+					t.Fatalf("expected no recording, got %+v", rec.meta)
+				}
+				return
+			}
+			if rec == nil {
+				t.Fatal("expected a recording")
+			}
+			rec.output([]byte("\x01total 0\r\n"))
+			rec.Write([]byte("\x00exit\r"))
+			rec.finish()
+
+			meta, err := s.GetTerminalRecording(streamID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.Agent != "test-agent" || meta.Namespace != "default" || meta.Pod != "web-0" || meta.Container != tt.wantContainer {
+				t.Errorf("unexpected recording metadata %+v", meta)
+			}
+			if !slices.Equal(meta.Command, tt.wantCommand) {
+				t.Errorf("got command %q, want %q", meta.Command, tt.wantCommand)
+			}
+			if meta.EndedAt == nil || meta.Bytes == 0 {
+				t.Errorf("expected a finished recording, got %+v", meta)
+			}
+			cast, err := os.ReadFile(meta.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(cast), `"o","\u0001total 0\r\n"`) || !strings.Contains(string(cast), `"i","\u0000exit\r"`) {
+				t.Errorf("recording is missing the streams:\n%s", cast)
+			}
+		})
+	}
+}
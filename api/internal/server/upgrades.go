@@ -0,0 +1,488 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/uc-cdis/gen3-admin/internal/store"
+	pb "github.com/uc-cdis/gen3-admin/internal/tunnel"
+)
+
+const (
+	defaultUpgradeTimeout = 15 * time.Minute
+	upgradePollInterval   = 5 * time.Second
+)
+
+type upgradeWave = store.UpgradeWave
+
+const (
+	waveRunning   = store.WaveRunning
+	waveCompleted = store.WaveCompleted
+	waveHalted    = store.WaveHalted
+)
+
+var (
+	upgradeWaves  = map[string]*upgradeWave{}
+	upgradesMutex sync.RWMutex
+)
+
+type createUpgradeRequest struct {
+	Image         string   `json:"image" binding:"required"`
+	Version       string   `json:"version"`
+	Agents        []string `json:"agents"`
+	LabelSelector string   `json:"labelSelector"`
+	BatchSize     int      `json:"batchSize"`
+	Timeout       string   `json:"timeout"`
+}
+
+// versionFromImage returns the tag of an image reference, or "" when it has none.
+func versionFromImage(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+// CreateUpgradeHandler sets the desired image and version on the selected
+// agents and rolls them out in waves.
+func CreateUpgradeHandler(c *gin.Context) {
+	var req createUpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version := req.Version
+	if version == "" {
+		version = versionFromImage(req.Image)
+	}
+	if version == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required when the image has no tag"})
+		return
+	}
+
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	timeout := defaultUpgradeTimeout
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+			return
+		}
+		timeout = d
+	}
+
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid labelSelector: " + err.Error()})
+		return
+	}
+
+	targets := []string{}
+	skipped := map[string]string{}
+
+	agentsMutex.RLock()
+	candidates := req.Agents
+	if len(candidates) == 0 {
+		for name, conn := range AgentConnections {
+			if selector.Matches(labels.Set(conn.agent.Metadata.Labels)) {
+				candidates = append(candidates, name)
+			}
+		}
+		sort.Strings(candidates)
+	}
+	for _, name := range candidates {
+		conn, exists := AgentConnections[name]
+		switch {
+		case !exists:
+			agentsMutex.RUnlock()
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("agent %s not found", name)})
+			return
+		case conn.agent.Revoked:
+			skipped[name] = "agent is revoked"
+		case conn.agent.Version == version:
+			skipped[name] = "already running " + version
+		case !slices.Contains(agentCapabilities(conn.agent), pb.CapabilitySelfUpgrade):
+			skipped[name] = fmt.Sprintf("agent version %s does not support self-upgrade", conn.agent.Version)
+		case conn.agent.Upgrade != nil && !conn.agent.Upgrade.Done():
+			skipped[name] = "another upgrade is in progress"
+		default:
+			targets = append(targets, name)
+		}
+	}
+	agentsMutex.RUnlock()
+
+	if len(targets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no agents to upgrade", "skipped": skipped})
+		return
+	}
+
+	wave := &upgradeWave{
+		ID:        uuid.New().String(),
+		Image:     req.Image,
+		Version:   version,
+		Agents:    targets,
+		Skipped:   skipped,
+		BatchSize: batchSize,
+		Timeout:   timeout.String(),
+		State:     waveRunning,
+		Batches:   (len(targets) + batchSize - 1) / batchSize,
+		CreatedBy: currentUser(c),
+		CreatedAt: time.Now(),
+	}
+
+	upgradesMutex.Lock()
+	upgradeWaves[wave.ID] = wave
+	snapshot := *wave
+	upgradesMutex.Unlock()
+	persistWave(snapshot)
+
+	log.Info().
+		Str("upgrade", wave.ID).
+		Str("image", wave.Image).
+		Str("version", wave.Version).
+		Strs("agents", targets).
+		Int("batchSize", batchSize).
+		Str("createdBy", wave.CreatedBy).
+		Msg("Starting agent upgrade")
+
+	go runUpgradeWave(wave, timeout)
+
+	c.JSON(http.StatusAccepted, snapshot)
+}
+
+func runUpgradeWave(wave *upgradeWave, timeout time.Duration) {
+	for i := 0; i < len(wave.Agents); i += wave.BatchSize {
+		batch := wave.Agents[i:min(i+wave.BatchSize, len(wave.Agents))]
+
+		upgradesMutex.Lock()
+		wave.Batch = i/wave.BatchSize + 1
+		snapshot := *wave
+		upgradesMutex.Unlock()
+		persistWave(snapshot)
+
+		for _, name := range batch {
+			startAgentUpgrade(name, wave.ID, wave.Image, wave.Version)
+		}
+
+		if failed := waitForUpgradeBatch(batch, wave.ID, timeout); len(failed) > 0 {
+			finishUpgradeWave(wave, waveHalted, "halted, upgrade failed on "+strings.Join(failed, ", "))
+			return
+		}
+	}
+	finishUpgradeWave(wave, waveCompleted, "")
+}
+
+func finishUpgradeWave(wave *upgradeWave, state store.WaveState, message string) {
+	now := time.Now()
+	upgradesMutex.Lock()
+	wave.State = state
+	wave.Message = message
+	wave.FinishedAt = &now
+	snapshot := *wave
+	upgradesMutex.Unlock()
+	persistWave(snapshot)
+
+	log.Info().Str("upgrade", wave.ID).Str("state", string(state)).Str("message", message).Msg("Agent upgrade finished")
+}
+
+func persistWave(wave upgradeWave) {
+	if registry == nil {
+		return
+	}
+	if err := registry.SaveUpgradeWave(wave); err != nil {
+		log.Error().Err(err).Str("upgrade", wave.ID).Msg("Failed to persist upgrade wave")
+	}
+}
+
+// loadUpgradeWaves restores the waves of earlier runs. Nothing runs them
+// after a restart: running waves halt and the upgrades they left unfinished
+// fail, unless the agent already runs the desired version, so the agents
+// can be upgraded again.
+func loadUpgradeWaves() error {
+	waves, err := registry.ListUpgradeWaves()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	interrupted := []upgradeWave{}
+	upgradesMutex.Lock()
+	for i := range waves {
+		wave := &waves[i]
+		if wave.State == waveRunning {
+			wave.State = waveHalted
+			wave.Message = "interrupted by a server restart"
+			wave.FinishedAt = &now
+			interrupted = append(interrupted, *wave)
+		}
+		upgradeWaves[wave.ID] = wave
+	}
+	upgradesMutex.Unlock()
+
+	for _, wave := range interrupted {
+		persistWave(wave)
+	}
+
+	unfinished := []Agent{}
+	agentsMutex.RLock()
+	for _, conn := range AgentConnections {
+		if conn.agent.Upgrade != nil && !conn.agent.Upgrade.Done() {
+			unfinished = append(unfinished, conn.agent)
+		}
+	}
+	agentsMutex.RUnlock()
+
+	for _, record := range unfinished {
+		if record.DesiredVersion != "" && record.Version == record.DesiredVersion {
+			setUpgradeState(record.Name, record.Upgrade.ID, store.UpgradeSucceeded, "agent runs version "+record.Version)
+		} else {
+			setUpgradeState(record.Name, record.Upgrade.ID, store.UpgradeFailed, "interrupted by a server restart")
+		}
+	}
+
+	log.Info().Int("waves", len(waves)).Int("interrupted", len(interrupted)).Int("agents", len(unfinished)).Msg("Loaded upgrade waves from registry")
+	return nil
+}
+
+// startAgentUpgrade records the desired version on the agent and sends it the
+// upgrade request. Offline agents get it when they reconnect.
+func startAgentUpgrade(agentName, upgradeID, image, version string) {
+	owner := agentOwner(agentName)
+	if owner != nil {
+		// The replica holding the stream owns the record, let it persist the upgrade
+		if err := startRemoteUpgrade(*owner, agentName, upgradeID, image, version); err != nil {
+			log.Error().Err(err).Str("agent", agentName).Msg("Failed to start upgrade on the replica holding the agent")
+			return
+		}
+	}
+
+	agentsMutex.Lock()
+	conn, exists := AgentConnections[agentName]
+	if !exists {
+		agentsMutex.Unlock()
+		return
+	}
+	conn.agent.DesiredImage = image
+	conn.agent.DesiredVersion = version
+	conn.agent.Upgrade = &store.Upgrade{
+		ID:        upgradeID,
+		State:     store.UpgradePending,
+		UpdatedAt: time.Now(),
+	}
+	record := conn.agent
+	connected := conn.stream != nil && conn.agent.Connected
+	agentsMutex.Unlock()
+
+	if owner != nil {
+		return
+	}
+	persistAgent(record)
+	if connected {
+		sendUpgradeRequest(conn, record)
+	}
+}
+
+func sendUpgradeRequest(conn *AgentConnection, record Agent) {
+	err := conn.sendMessage(&pb.ServerMessage{
+		Message: &pb.ServerMessage_Upgrade{
+			Upgrade: &pb.AgentUpgradeRequest{
+				UpgradeId: record.Upgrade.ID,
+				Image:     record.DesiredImage,
+				Version:   record.DesiredVersion,
+			},
+		},
+	})
+	if err != nil {
+		// Still pending, it is sent again when the agent reconnects
+		log.Error().Err(err).Str("agent", record.Name).Msg("Failed to send upgrade request")
+		return
+	}
+	log.Info().Str("agent", record.Name).Str("image", record.DesiredImage).Msg("Sent upgrade request to agent")
+}
+
+// waitForUpgradeBatch blocks until every agent in the batch succeeded or
+// failed, failing the ones still going after the timeout. It returns the
+// agents that failed.
+func waitForUpgradeBatch(batch []string, upgradeID string, timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(upgradePollInterval)
+	defer ticker.Stop()
+
+	for {
+		failed := []string{}
+		pending := []string{}
+
+		agentsMutex.RLock()
+		for _, name := range batch {
+			conn, exists := AgentConnections[name]
+			switch {
+			case !exists:
+				failed = append(failed, name)
+			case conn.agent.Upgrade == nil || conn.agent.Upgrade.ID != upgradeID:
+				// Superseded by a newer upgrade
+				failed = append(failed, name)
+			case conn.agent.Upgrade.State == store.UpgradeFailed:
+				failed = append(failed, name)
+			case conn.agent.Upgrade.State != store.UpgradeSucceeded:
+				pending = append(pending, name)
+			}
+		}
+		agentsMutex.RUnlock()
+
+		if len(pending) == 0 {
+			return failed
+		}
+		if time.Now().After(deadline) {
+			for _, name := range pending {
+				setUpgradeState(name, upgradeID, store.UpgradeFailed, fmt.Sprintf("timed out after %s", timeout))
+			}
+			return append(failed, pending...)
+		}
+		<-ticker.C
+	}
+}
+
+// setUpgradeState moves the agent's upgrade along, ignoring stale reports
+// for other upgrades and upgrades that already finished.
+func setUpgradeState(agentName, upgradeID string, state store.UpgradeState, message string) {
+	agentsMutex.Lock()
+	conn, exists := AgentConnections[agentName]
+	if !exists || conn.agent.Upgrade == nil || conn.agent.Upgrade.ID != upgradeID || conn.agent.Upgrade.Done() {
+		agentsMutex.Unlock()
+		return
+	}
+	conn.agent.Upgrade = &store.Upgrade{
+		ID:        upgradeID,
+		State:     state,
+		Message:   message,
+		UpdatedAt: time.Now(),
+	}
+	record := conn.agent
+	agentsMutex.Unlock()
+
+	persistAgent(record)
+
+	event := log.Info()
+	if state == store.UpgradeFailed {
+		event = log.Warn()
+	}
+	event.Str("agent", agentName).Str("upgrade", upgradeID).Str("state", string(state)).Str("message", message).Msg("Agent upgrade state changed")
+}
+
+// handleUpgradeStatus applies the rollout progress reported by an agent.
+func handleUpgradeStatus(agentName string, msg *pb.AgentUpgradeStatus) {
+	var state store.UpgradeState
+	switch msg.State {
+	case pb.UpgradeState_UPGRADE_IN_PROGRESS:
+		state = store.UpgradeInProgress
+	case pb.UpgradeState_UPGRADE_SUCCEEDED:
+		state = store.UpgradeSucceeded
+	case pb.UpgradeState_UPGRADE_FAILED:
+		state = store.UpgradeFailed
+	default:
+		log.Warn().Str("agent", agentName).Str("state", msg.State.String()).Msg("Ignoring unknown upgrade state")
+		return
+	}
+	setUpgradeState(agentName, msg.UpgradeId, state, msg.Message)
+}
+
+// reconcileUpgrade runs when an agent registers. An agent coming back with
+// the desired version finished its upgrade, one that never got the request
+// gets it now.
+func reconcileUpgrade(agentName string) {
+	agentsMutex.RLock()
+	conn, exists := AgentConnections[agentName]
+	var record Agent
+	if exists {
+		record = conn.agent
+	}
+	agentsMutex.RUnlock()
+
+	if !exists || record.Upgrade == nil || record.Upgrade.Done() || record.DesiredVersion == "" {
+		return
+	}
+	if record.Version == record.DesiredVersion {
+		setUpgradeState(agentName, record.Upgrade.ID, store.UpgradeSucceeded, "agent registered with version "+record.Version)
+		return
+	}
+	if record.Upgrade.State == store.UpgradePending {
+		sendUpgradeRequest(conn, record)
+	}
+}
+
+// ListUpgradesHandler returns the upgrade waves, newest first.
+func ListUpgradesHandler(c *gin.Context) {
+	upgradesMutex.RLock()
+	waves := make([]upgradeWave, 0, len(upgradeWaves))
+	for _, w := range upgradeWaves {
+		waves = append(waves, *w)
+	}
+	upgradesMutex.RUnlock()
+
+	sort.Slice(waves, func(i, j int) bool {
+		return waves[i].CreatedAt.After(waves[j].CreatedAt)
+	})
+	c.JSON(http.StatusOK, waves)
+}
+
+// GetUpgradeHandler returns a wave together with the upgrade state of each of its agents.
+func GetUpgradeHandler(c *gin.Context) {
+	upgradesMutex.RLock()
+	w, exists := upgradeWaves[c.Param("id")]
+	var wave upgradeWave
+	if exists {
+		wave = *w
+	}
+	upgradesMutex.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upgrade not found"})
+		return
+	}
+
+	type agentUpgrade struct {
+		Version        string         `json:"version"`
+		DesiredVersion string         `json:"desiredVersion"`
+		Upgrade        *store.Upgrade `json:"upgrade,omitempty"`
+	}
+	agents := map[string]agentUpgrade{}
+
+	agentsMutex.RLock()
+	for _, name := range wave.Agents {
+		conn, ok := AgentConnections[name]
+		if !ok {
+			continue
+		}
+		a := agentUpgrade{Version: conn.agent.Version, DesiredVersion: conn.agent.DesiredVersion}
+		if conn.agent.Upgrade != nil && conn.agent.Upgrade.ID == wave.ID {
+			u := *conn.agent.Upgrade
+			a.Upgrade = &u
+		}
+		agents[name] = a
+	}
+	agentsMutex.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"upgrade": wave,
+		"agents":  agents,
+	})
+}
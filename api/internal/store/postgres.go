@@ -0,0 +1,708 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresSchemaLock serializes schema creation between replicas starting at once
+const postgresSchemaLock = 0x67656e33
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS agents (
+	name       TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agent_status (
+	agent TEXT PRIMARY KEY,
+	data  TEXT NOT NULL,
+	ts    BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS status_samples (
+	agent              TEXT NOT NULL,
+	ts                 BIGINT NOT NULL,
+	cpu_usage          DOUBLE PRECISION NOT NULL,
+	memory_usage       DOUBLE PRECISION NOT NULL,
+	pod_capacity       INTEGER NOT NULL,
+	pod_count          INTEGER NOT NULL,
+	healthy            INTEGER NOT NULL,
+	node_count         INTEGER NOT NULL DEFAULT 0,
+	not_ready_nodes    INTEGER NOT NULL DEFAULT 0,
+	pending_pods       INTEGER NOT NULL DEFAULT 0,
+	crash_looping_pods INTEGER NOT NULL DEFAULT 0,
+	failing_pvcs       INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_samples_agent ON status_samples (agent, ts);
+
+CREATE TABLE IF NOT EXISTS connection_events (
+	id          BIGSERIAL PRIMARY KEY,
+	agent       TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	reason      TEXT NOT NULL DEFAULT '',
+	remote_addr TEXT NOT NULL DEFAULT '',
+	ts          BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_connection_events_agent ON connection_events (agent, ts);
+
+CREATE TABLE IF NOT EXISTS join_tokens (
+	token_hash TEXT PRIMARY KEY,
+	id         TEXT NOT NULL,
+	agent      TEXT NOT NULL,
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	used_at    BIGINT,
+	used_from  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS agent_locations (
+	agent      TEXT PRIMARY KEY,
+	replica    TEXT NOT NULL,
+	address    TEXT NOT NULL,
+	updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id          BIGSERIAL PRIMARY KEY,
+	ts          BIGINT NOT NULL,
+	"user"      TEXT NOT NULL DEFAULT '',
+	agent       TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL,
+	target      TEXT NOT NULL DEFAULT '',
+	summary     TEXT NOT NULL DEFAULT '',
+	result      TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL DEFAULT '',
+	remote_addr TEXT NOT NULL DEFAULT '',
+	duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_ts ON audit_log (ts);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log ("user", id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_agent ON audit_log (agent, id);
+
+-- the audit log is append-only, even for someone with access to the database
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+	CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+	CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+EXCEPTION WHEN duplicate_object THEN NULL;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS terminal_recordings (
+	id         TEXT PRIMARY KEY,
+	agent      TEXT NOT NULL,
+	"user"     TEXT NOT NULL DEFAULT '',
+	namespace  TEXT NOT NULL,
+	pod        TEXT NOT NULL,
+	container  TEXT NOT NULL DEFAULT '',
+	command    TEXT NOT NULL DEFAULT '[]',
+	path       TEXT NOT NULL,
+	bytes      BIGINT NOT NULL DEFAULT 0,
+	started_at BIGINT NOT NULL,
+	ended_at   BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_terminal_recordings_started ON terminal_recordings (started_at);
+
+CREATE TABLE IF NOT EXISTS upgrade_waves (
+	id         TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS revocations (
+	serial_number TEXT PRIMARY KEY,
+	agent         TEXT NOT NULL DEFAULT '',
+	reason        TEXT NOT NULL DEFAULT '',
+	revoked_at    BIGINT NOT NULL,
+	not_after     BIGINT NOT NULL
+);
+`
+
+// PostgresStore keeps the registry in a PostgreSQL database every API replica
+// connects to, which lets the API run several replicas.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening postgres store: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to postgres store: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresSchemaLock); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error locking postgres schema: %v", err)
+	}
+	if _, err := tx.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating postgres schema: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating postgres schema: %v", err)
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) ListAgents() ([]Agent, error) {
+	rows, err := s.db.Query(`SELECT data FROM agents ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []Agent{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var agent Agent
+		if err := json.Unmarshal([]byte(data), &agent); err != nil {
+			return nil, fmt.Errorf("error decoding agent: %v", err)
+		}
+		agents = append(agents, agent)
+	}
+	return agents, rows.Err()
+}
+
+func (s *PostgresStore) GetAgent(name string) (*Agent, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM agents WHERE name = $1`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var agent Agent
+	if err := json.Unmarshal([]byte(data), &agent); err != nil {
+		return nil, fmt.Errorf("error decoding agent: %v", err)
+	}
+	return &agent, nil
+}
+
+func (s *PostgresStore) SaveAgent(agent Agent) error {
+	if agent.Name == "" {
+		return errors.New("agent name is required")
+	}
+	data, err := json.Marshal(agent.withoutSecrets())
+	if err != nil {
+		return fmt.Errorf("error encoding agent: %v", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO agents (name, data, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		agent.Name, string(data), time.Now().UnixMilli())
+	return err
+}
+
+func (s *PostgresStore) DeleteAgent(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM agents WHERE name = $1`,
+		`DELETE FROM agent_status WHERE agent = $1`,
+		`DELETE FROM status_samples WHERE agent = $1`,
+		`DELETE FROM connection_events WHERE agent = $1`,
+		// A stale location would route an agent created again under the name to the wrong replica
+		`DELETE FROM agent_locations WHERE agent = $1`,
+		`DELETE FROM join_tokens WHERE agent = $1 AND used_at IS NULL`,
+	} {
+		if _, err := tx.Exec(stmt, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) SaveStatus(sample StatusSample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("error encoding status sample: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ts := sample.Timestamp.UnixMilli()
+	if _, err := tx.Exec(`
+		INSERT INTO agent_status (agent, data, ts) VALUES ($1, $2, $3)
+		ON CONFLICT (agent) DO UPDATE SET data = excluded.data, ts = excluded.ts`,
+		sample.Agent, string(data), ts); err != nil {
+		return err
+	}
+
+	healthy := 0
+	if sample.HealthStatus == "OK" {
+		healthy = 1
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO status_samples (agent, ts, cpu_usage, memory_usage, pod_capacity, pod_count, healthy,
+			node_count, not_ready_nodes, pending_pods, crash_looping_pods, failing_pvcs)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		sample.Agent, ts, sample.CpuUsage, sample.MemoryUsage, sample.PodCapacity, sample.PodCount, healthy,
+		sample.NodeCount, sample.NotReadyNodes, sample.PendingPods, sample.CrashLoopingPods, sample.FailingPVCs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) QueryStatus(agent string, from, to time.Time, step time.Duration) ([]StatusPoint, error) {
+	stepMs := step.Milliseconds()
+	if stepMs <= 0 {
+		return nil, errors.New("step must be positive")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT (ts / $1) * $1 AS bucket, COUNT(*),
+			AVG(cpu_usage), MAX(cpu_usage),
+			AVG(memory_usage), MAX(memory_usage),
+			AVG(pod_count)::DOUBLE PRECISION, MAX(pod_count), MAX(pod_capacity),
+			SUM(1 - healthy),
+			MAX(node_count), MAX(not_ready_nodes), MAX(pending_pods), MAX(crash_looping_pods), MAX(failing_pvcs)
+		FROM status_samples
+		WHERE agent = $2 AND ts >= $3 AND ts <= $4
+		GROUP BY bucket ORDER BY bucket`,
+		stepMs, agent, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []StatusPoint{}
+	for rows.Next() {
+		var p StatusPoint
+		var bucket int64
+		if err := rows.Scan(&bucket, &p.Samples, &p.CpuAvg, &p.CpuMax, &p.MemoryAvg, &p.MemoryMax,
+			&p.PodCountAvg, &p.PodCountMax, &p.PodCapacity, &p.Unhealthy,
+			&p.NodeCount, &p.NotReadyNodesMax, &p.PendingPodsMax, &p.CrashLoopingPodsMax, &p.FailingPVCsMax); err != nil {
+			return nil, err
+		}
+		p.Timestamp = time.UnixMilli(bucket)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *PostgresStore) PruneStatus(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM status_samples WHERE ts < $1`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *PostgresStore) LastStatus(agent string) (*StatusSample, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM agent_status WHERE agent = $1`, agent).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sample StatusSample
+	if err := json.Unmarshal([]byte(data), &sample); err != nil {
+		return nil, fmt.Errorf("error decoding status sample: %v", err)
+	}
+	return &sample, nil
+}
+
+func (s *PostgresStore) RecordConnectionEvent(event ConnectionEvent) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO connection_events (agent, type, reason, remote_addr, ts) VALUES ($1, $2, $3, $4, $5)`,
+		event.Agent, string(event.Type), event.Reason, event.RemoteAddr, event.Timestamp.UnixMilli())
+	return err
+}
+
+// ListConnectionEvents returns the most recent events for an agent, newest first.
+func (s *PostgresStore) ListConnectionEvents(agent string, limit int) ([]ConnectionEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(`
+		SELECT id, agent, type, reason, remote_addr, ts FROM connection_events
+		WHERE agent = $1 ORDER BY ts DESC, id DESC LIMIT $2`, agent, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ConnectionEvent{}
+	for rows.Next() {
+		var ev ConnectionEvent
+		var eventType string
+		var ts int64
+		if err := rows.Scan(&ev.ID, &ev.Agent, &eventType, &ev.Reason, &ev.RemoteAddr, &ts); err != nil {
+			return nil, err
+		}
+		ev.Type = ConnectionEventType(eventType)
+		ev.Timestamp = time.UnixMilli(ts)
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+func (s *PostgresStore) CreateJoinToken(token JoinToken) error {
+	_, err := s.db.Exec(`
+		INSERT INTO join_tokens (token_hash, id, agent, created_by, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.TokenHash, token.ID, token.Agent, token.CreatedBy, token.CreatedAt.UnixMilli(), token.ExpiresAt.UnixMilli())
+	return err
+}
+
+func (s *PostgresStore) ConsumeJoinToken(tokenHash string, agent string, usedFrom string) (*JoinToken, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Locked so two replicas can't both hand out a certificate for the token
+	token, err := scanJoinToken(tx.QueryRow(`
+		SELECT token_hash, id, agent, created_by, created_at, expires_at, used_at, used_from
+		FROM join_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case token.UsedAt != nil:
+		return token, ErrTokenUsed
+	case now.After(token.ExpiresAt):
+		return token, ErrTokenExpired
+	case token.Agent != agent:
+		return token, ErrTokenAgent
+	}
+
+	res, err := tx.Exec(`UPDATE join_tokens SET used_at = $1, used_from = $2 WHERE token_hash = $3 AND used_at IS NULL`,
+		now.UnixMilli(), usedFrom, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return token, ErrTokenUsed
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	token.UsedAt = &now
+	token.UsedFrom = usedFrom
+	return token, nil
+}
+
+func (s *PostgresStore) ListJoinTokens() ([]JoinToken, error) {
+	rows, err := s.db.Query(`
+		SELECT token_hash, id, agent, created_by, created_at, expires_at, used_at, used_from
+		FROM join_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []JoinToken{}
+	for rows.Next() {
+		token, err := scanJoinToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *PostgresStore) SaveAgentLocation(location AgentLocation) error {
+	_, err := s.db.Exec(`
+		INSERT INTO agent_locations (agent, replica, address, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (agent) DO UPDATE SET
+			replica = excluded.replica, address = excluded.address, updated_at = excluded.updated_at`,
+		location.Agent, location.Replica, location.Address, location.UpdatedAt.UnixMilli())
+	return err
+}
+
+func (s *PostgresStore) DeleteAgentLocation(agent string, replica string) error {
+	_, err := s.db.Exec(`DELETE FROM agent_locations WHERE agent = $1 AND replica = $2`, agent, replica)
+	return err
+}
+
+func (s *PostgresStore) GetAgentLocation(agent string) (*AgentLocation, error) {
+	location := AgentLocation{Agent: agent}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT replica, address, updated_at FROM agent_locations WHERE agent = $1`, agent).
+		Scan(&location.Replica, &location.Address, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	location.UpdatedAt = time.UnixMilli(updatedAt)
+	return &location, nil
+}
+
+func (s *PostgresStore) RecordAudit(entry AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (ts, "user", agent, action, target, summary, result, status_code, error, remote_addr, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		entry.Timestamp.UnixMilli(), entry.User, entry.Agent, entry.Action, entry.Target, entry.Summary,
+		string(entry.Result), entry.StatusCode, entry.Error, entry.RemoteAddr, entry.DurationMs)
+	return err
+}
+
+func (s *PostgresStore) ListAudit(filter AuditFilter) ([]AuditEntry, error) {
+	where := []string{"TRUE"}
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, match := range []struct{ column, value string }{
+		{`"user"`, filter.User},
+		{"agent", filter.Agent},
+		{"action", filter.Action},
+		{"result", string(filter.Result)},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+" = "+arg(match.value))
+		}
+	}
+	if filter.Target != "" {
+		where = append(where, "target LIKE "+arg(likeEscaper.Replace(filter.Target)+"%")+` ESCAPE '\'`)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "ts >= "+arg(filter.Since.UnixMilli()))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "ts < "+arg(filter.Until.UnixMilli()))
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < "+arg(filter.BeforeID))
+	}
+	query := `
+		SELECT id, ts, "user", agent, action, target, summary, result, status_code, error, remote_addr, duration_ms
+		FROM audit_log WHERE ` + strings.Join(where, " AND ") + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var ts int64
+		var result string
+		if err := rows.Scan(&entry.ID, &ts, &entry.User, &entry.Agent, &entry.Action, &entry.Target, &entry.Summary,
+			&result, &entry.StatusCode, &entry.Error, &entry.RemoteAddr, &entry.DurationMs); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = time.UnixMilli(ts)
+		entry.Result = AuditResult(result)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *PostgresStore) SaveTerminalRecording(recording TerminalRecording) error {
+	command, err := json.Marshal(recording.Command)
+	if err != nil {
+		return err
+	}
+	var endedAt sql.NullInt64
+	if recording.EndedAt != nil {
+		endedAt = sql.NullInt64{Int64: recording.EndedAt.UnixMilli(), Valid: true}
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO terminal_recordings (id, agent, "user", namespace, pod, container, command, path, bytes, started_at, ended_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET bytes = excluded.bytes, ended_at = excluded.ended_at`,
+		recording.ID, recording.Agent, recording.User, recording.Namespace, recording.Pod, recording.Container,
+		string(command), recording.Path, recording.Bytes, recording.StartedAt.UnixMilli(), endedAt)
+	return err
+}
+
+const postgresTerminalRecordingColumns = `id, agent, "user", namespace, pod, container, command, path, bytes, started_at, ended_at`
+
+func (s *PostgresStore) GetTerminalRecording(id string) (*TerminalRecording, error) {
+	return scanTerminalRecording(s.db.QueryRow(`SELECT `+postgresTerminalRecordingColumns+` FROM terminal_recordings WHERE id = $1`, id))
+}
+
+func (s *PostgresStore) ListTerminalRecordings(filter TerminalRecordingFilter) ([]TerminalRecording, error) {
+	where := []string{"TRUE"}
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, match := range []struct{ column, value string }{
+		{"agent", filter.Agent},
+		{`"user"`, filter.User},
+		{"namespace", filter.Namespace},
+		{"pod", filter.Pod},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+" = "+arg(match.value))
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "started_at >= "+arg(filter.Since.UnixMilli()))
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "started_at < "+arg(filter.Until.UnixMilli()))
+	}
+	query := `SELECT ` + postgresTerminalRecordingColumns + ` FROM terminal_recordings
+		WHERE ` + strings.Join(where, " AND ") + ` ORDER BY started_at DESC, id`
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordings := []TerminalRecording{}
+	for rows.Next() {
+		recording, err := scanTerminalRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, *recording)
+	}
+	return recordings, rows.Err()
+}
+
+func (s *PostgresStore) SaveUpgradeWave(wave UpgradeWave) error {
+	if wave.ID == "" {
+		return errors.New("upgrade wave id is required")
+	}
+	data, err := json.Marshal(wave)
+	if err != nil {
+		return fmt.Errorf("error encoding upgrade wave: %v", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO upgrade_waves (id, data, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`,
+		wave.ID, string(data), wave.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *PostgresStore) ListUpgradeWaves() ([]UpgradeWave, error) {
+	rows, err := s.db.Query(`SELECT data FROM upgrade_waves ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	waves := []UpgradeWave{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var wave UpgradeWave
+		if err := json.Unmarshal([]byte(data), &wave); err != nil {
+			return nil, fmt.Errorf("error decoding upgrade wave: %v", err)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, rows.Err()
+}
+
+// SaveRevocation adds the certificate to the revocation list, revoking it
+// again keeps the first entry.
+func (s *PostgresStore) SaveRevocation(revocation Revocation) error {
+	_, err := s.db.Exec(`
+		INSERT INTO revocations (serial_number, agent, reason, revoked_at, not_after) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (serial_number) DO NOTHING`,
+		revocation.SerialNumber, revocation.Agent, revocation.Reason,
+		revocation.RevokedAt.UnixMilli(), revocation.NotAfter.UnixMilli())
+	return err
+}
+
+func (s *PostgresStore) ListRevocations() ([]Revocation, error) {
+	rows, err := s.db.Query(`
+		SELECT serial_number, agent, reason, revoked_at, not_after FROM revocations ORDER BY revoked_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revocations := []Revocation{}
+	for rows.Next() {
+		var r Revocation
+		var revokedAt, notAfter int64
+		if err := rows.Scan(&r.SerialNumber, &r.Agent, &r.Reason, &revokedAt, &notAfter); err != nil {
+			return nil, err
+		}
+		r.RevokedAt = time.UnixMilli(revokedAt)
+		r.NotAfter = time.UnixMilli(notAfter)
+		revocations = append(revocations, r)
+	}
+	return revocations, rows.Err()
+}
+
+// Shared is true, every replica connects to the same database.
+func (s *PostgresStore) Shared() bool {
+	return true
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
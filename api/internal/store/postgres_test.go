@@ -0,0 +1,163 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPostgresStore opens a store in a schema of its own on the database
+// STORE_TEST_POSTGRES_DSN points to, the tests are skipped without one.
+func newTestPostgresStore(t *testing.T) *PostgresStore {
+	t.Helper()
+	dsn := os.Getenv("STORE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("STORE_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("creating test schema: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	if strings.Contains(dsn, "://") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&search_path=" + schema
+		} else {
+			dsn += "?search_path=" + schema
+		}
+	} else {
+		dsn += " search_path=" + schema
+	}
+	s, err := NewPostgresStore(dsn)
+	if err != nil {
+		t.Fatalf("NewPostgresStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPostgresAgents(t *testing.T) {
+	s := newTestPostgresStore(t)
+	if !s.Shared() {
+		t.Error("postgres store is not shared")
+	}
+
+	agent := Agent{Name: "agent1", Certificate: "cert", PrivateKey: "key", SecretAccessKey: "secret"}
+	if err := s.SaveAgent(agent); err != nil {
+		t.Fatalf("SaveAgent: %v", err)
+	}
+	got, err := s.GetAgent("agent1")
+	if err != nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+	if got.Certificate != "cert" || got.PrivateKey != "" || got.SecretAccessKey != "" {
+		t.Errorf("GetAgent = %+v, want the saved agent without its secrets", got)
+	}
+
+	if err := s.DeleteAgent("agent1"); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+	if _, err := s.GetAgent("agent1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAgent after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPostgresConsumeJoinToken(t *testing.T) {
+	s := newTestPostgresStore(t)
+	now := time.Now()
+	if err := s.CreateJoinToken(JoinToken{TokenHash: "hash", ID: "id", Agent: "agent1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ConsumeJoinToken("hash", "agent2", ""); !errors.Is(err, ErrTokenAgent) {
+		t.Errorf("ConsumeJoinToken for another agent = %v, want ErrTokenAgent", err)
+	}
+	token, err := s.ConsumeJoinToken("hash", "agent1", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("ConsumeJoinToken: %v", err)
+	}
+	if token.UsedAt == nil || token.UsedFrom != "10.0.0.2" {
+		t.Errorf("consumed token = %+v, want it marked used", token)
+	}
+	if _, err := s.ConsumeJoinToken("hash", "agent1", ""); !errors.Is(err, ErrTokenUsed) {
+		t.Errorf("second ConsumeJoinToken = %v, want ErrTokenUsed", err)
+	}
+}
+
+func TestPostgresAgentLocations(t *testing.T) {
+	s := newTestPostgresStore(t)
+	now := time.Now()
+
+	if err := s.SaveAgentLocation(AgentLocation{Agent: "agent1", Replica: "r1", Address: "a1", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveAgentLocation(AgentLocation{Agent: "agent1", Replica: "r2", Address: "a2", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteAgentLocation("agent1", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	location, err := s.GetAgentLocation("agent1")
+	if err != nil {
+		t.Fatalf("GetAgentLocation: %v", err)
+	}
+	if location.Replica != "r2" {
+		t.Errorf("GetAgentLocation = %+v, want r2", location)
+	}
+}
+
+func TestPostgresRevocations(t *testing.T) {
+	s := newTestPostgresStore(t)
+	now := time.Now().Truncate(time.Millisecond)
+
+	revocation := Revocation{SerialNumber: "2", Agent: "agent1", Reason: "deleted", RevokedAt: now, NotAfter: now.Add(time.Hour)}
+	for range 2 {
+		if err := s.SaveRevocation(revocation); err != nil {
+			t.Fatalf("SaveRevocation: %v", err)
+		}
+	}
+	if err := s.SaveRevocation(Revocation{SerialNumber: "1", RevokedAt: now.Add(-time.Minute), NotAfter: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	revocations, err := s.ListRevocations()
+	if err != nil {
+		t.Fatalf("ListRevocations: %v", err)
+	}
+	if len(revocations) != 2 || revocations[0].SerialNumber != "1" || revocations[1].Agent != "agent1" || !revocations[1].RevokedAt.Equal(now) {
+		t.Errorf("ListRevocations = %+v, want both revocations once, oldest first", revocations)
+	}
+}
+
+func TestPostgresAuditAppendOnly(t *testing.T) {
+	s := newTestPostgresStore(t)
+	if err := s.RecordAudit(AuditEntry{User: "alice", Action: "agent.delete", Result: AuditSuccess}); err != nil {
+		t.Fatalf("RecordAudit: %v", err)
+	}
+
+	for _, stmt := range []string{`UPDATE audit_log SET "user" = 'mallory'`, `DELETE FROM audit_log`, `TRUNCATE audit_log`} {
+		_, err := s.db.Exec(stmt)
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Errorf("%s = %v, want the append-only error", stmt, err)
+		}
+	}
+
+	entries, err := s.ListAudit(AuditFilter{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("audit log changed: %+v", entries)
+	}
+}
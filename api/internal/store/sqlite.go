@@ -0,0 +1,718 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS agents (
+	name       TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agent_status (
+	agent TEXT PRIMARY KEY,
+	data  TEXT NOT NULL,
+	ts    INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS status_samples (
+	agent        TEXT NOT NULL,
+	ts           INTEGER NOT NULL,
+	cpu_usage    REAL NOT NULL,
+	memory_usage REAL NOT NULL,
+	pod_capacity INTEGER NOT NULL,
+	pod_count    INTEGER NOT NULL,
+	healthy      INTEGER NOT NULL
+);
+
+-- columns added after the first release go through sqliteMigrations
+CREATE INDEX IF NOT EXISTS idx_status_samples_agent ON status_samples (agent, ts);
+
+CREATE TABLE IF NOT EXISTS connection_events (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	agent       TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	reason      TEXT NOT NULL DEFAULT '',
+	remote_addr TEXT NOT NULL DEFAULT '',
+	ts          INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_connection_events_agent ON connection_events (agent, ts);
+
+CREATE TABLE IF NOT EXISTS join_tokens (
+	token_hash TEXT PRIMARY KEY,
+	id         TEXT NOT NULL,
+	agent      TEXT NOT NULL,
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	used_at    INTEGER,
+	used_from  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS agent_locations (
+	agent      TEXT PRIMARY KEY,
+	replica    TEXT NOT NULL,
+	address    TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts          INTEGER NOT NULL,
+	user        TEXT NOT NULL DEFAULT '',
+	agent       TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL,
+	target      TEXT NOT NULL DEFAULT '',
+	summary     TEXT NOT NULL DEFAULT '',
+	result      TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL DEFAULT '',
+	remote_addr TEXT NOT NULL DEFAULT '',
+	duration_ms INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_ts ON audit_log (ts);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log (user, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_agent ON audit_log (agent, id);
+
+CREATE TABLE IF NOT EXISTS terminal_recordings (
+	id         TEXT PRIMARY KEY,
+	agent      TEXT NOT NULL,
+	user       TEXT NOT NULL DEFAULT '',
+	namespace  TEXT NOT NULL,
+	pod        TEXT NOT NULL,
+	container  TEXT NOT NULL DEFAULT '',
+	command    TEXT NOT NULL DEFAULT '[]',
+	path       TEXT NOT NULL,
+	bytes      INTEGER NOT NULL DEFAULT 0,
+	started_at INTEGER NOT NULL,
+	ended_at   INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_terminal_recordings_started ON terminal_recordings (started_at);
+
+-- the audit log is append-only, even for someone with access to the database
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS upgrade_waves (
+	id         TEXT PRIMARY KEY,
+	data       TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+`
+
+// sqliteMigrations add columns to existing databases, "duplicate column"
+// errors mean the migration already ran.
+var sqliteMigrations = []string{
+	`ALTER TABLE status_samples ADD COLUMN node_count INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE status_samples ADD COLUMN not_ready_nodes INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE status_samples ADD COLUMN pending_pods INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE status_samples ADD COLUMN crash_looping_pods INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE status_samples ADD COLUMN failing_pvcs INTEGER NOT NULL DEFAULT 0`,
+}
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating store directory: %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite store: %v", err)
+	}
+	// SQLite only allows a single writer, serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating sqlite schema: %v", err)
+	}
+	for _, stmt := range sqliteMigrations {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, fmt.Errorf("error migrating sqlite schema: %v", err)
+		}
+	}
+	// Agents stored before SaveAgent stripped secrets still carry them
+	if _, err := db.Exec(`UPDATE agents SET data = json_remove(data, '$.private_key', '$.accesskey', '$.secretaccesskey')
+		WHERE json_extract(data, '$.private_key') != '' OR json_extract(data, '$.accesskey') != '' OR json_extract(data, '$.secretaccesskey') != ''`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error removing secrets from stored agents: %v", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) ListAgents() ([]Agent, error) {
+	rows, err := s.db.Query(`SELECT data FROM agents ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []Agent{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var agent Agent
+		if err := json.Unmarshal([]byte(data), &agent); err != nil {
+			return nil, fmt.Errorf("error decoding agent: %v", err)
+		}
+		agents = append(agents, agent)
+	}
+	return agents, rows.Err()
+}
+
+func (s *SQLiteStore) GetAgent(name string) (*Agent, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM agents WHERE name = ?`, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var agent Agent
+	if err := json.Unmarshal([]byte(data), &agent); err != nil {
+		return nil, fmt.Errorf("error decoding agent: %v", err)
+	}
+	return &agent, nil
+}
+
+func (s *SQLiteStore) SaveAgent(agent Agent) error {
+	if agent.Name == "" {
+		return errors.New("agent name is required")
+	}
+	data, err := json.Marshal(agent.withoutSecrets())
+	if err != nil {
+		return fmt.Errorf("error encoding agent: %v", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO agents (name, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		agent.Name, string(data), time.Now().UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) DeleteAgent(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM agents WHERE name = ?`,
+		`DELETE FROM agent_status WHERE agent = ?`,
+		`DELETE FROM status_samples WHERE agent = ?`,
+		`DELETE FROM connection_events WHERE agent = ?`,
+		// A stale location would route an agent created again under the name to the wrong replica
+		`DELETE FROM agent_locations WHERE agent = ?`,
+		`DELETE FROM join_tokens WHERE agent = ? AND used_at IS NULL`,
+	} {
+		if _, err := tx.Exec(stmt, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SaveStatus(sample StatusSample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("error encoding status sample: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ts := sample.Timestamp.UnixMilli()
+	if _, err := tx.Exec(`
+		INSERT INTO agent_status (agent, data, ts) VALUES (?, ?, ?)
+		ON CONFLICT(agent) DO UPDATE SET data = excluded.data, ts = excluded.ts`,
+		sample.Agent, string(data), ts); err != nil {
+		return err
+	}
+
+	healthy := 0
+	if sample.HealthStatus == "OK" {
+		healthy = 1
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO status_samples (agent, ts, cpu_usage, memory_usage, pod_capacity, pod_count, healthy,
+			node_count, not_ready_nodes, pending_pods, crash_looping_pods, failing_pvcs)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sample.Agent, ts, sample.CpuUsage, sample.MemoryUsage, sample.PodCapacity, sample.PodCount, healthy,
+		sample.NodeCount, sample.NotReadyNodes, sample.PendingPods, sample.CrashLoopingPods, sample.FailingPVCs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) QueryStatus(agent string, from, to time.Time, step time.Duration) ([]StatusPoint, error) {
+	stepMs := step.Milliseconds()
+	if stepMs <= 0 {
+		return nil, errors.New("step must be positive")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT (ts / ?) * ? AS bucket, COUNT(*),
+			AVG(cpu_usage), MAX(cpu_usage),
+			AVG(memory_usage), MAX(memory_usage),
+			AVG(pod_count), MAX(pod_count), MAX(pod_capacity),
+			SUM(1 - healthy),
+			MAX(node_count), MAX(not_ready_nodes), MAX(pending_pods), MAX(crash_looping_pods), MAX(failing_pvcs)
+		FROM status_samples
+		WHERE agent = ? AND ts >= ? AND ts <= ?
+		GROUP BY bucket ORDER BY bucket`,
+		stepMs, stepMs, agent, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []StatusPoint{}
+	for rows.Next() {
+		var p StatusPoint
+		var bucket int64
+		if err := rows.Scan(&bucket, &p.Samples, &p.CpuAvg, &p.CpuMax, &p.MemoryAvg, &p.MemoryMax,
+			&p.PodCountAvg, &p.PodCountMax, &p.PodCapacity, &p.Unhealthy,
+			&p.NodeCount, &p.NotReadyNodesMax, &p.PendingPodsMax, &p.CrashLoopingPodsMax, &p.FailingPVCsMax); err != nil {
+			return nil, err
+		}
+		p.Timestamp = time.UnixMilli(bucket)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *SQLiteStore) PruneStatus(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM status_samples WHERE ts < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStore) LastStatus(agent string) (*StatusSample, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM agent_status WHERE agent = ?`, agent).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sample StatusSample
+	if err := json.Unmarshal([]byte(data), &sample); err != nil {
+		return nil, fmt.Errorf("error decoding status sample: %v", err)
+	}
+	return &sample, nil
+}
+
+func (s *SQLiteStore) RecordConnectionEvent(event ConnectionEvent) error {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO connection_events (agent, type, reason, remote_addr, ts) VALUES (?, ?, ?, ?, ?)`,
+		event.Agent, string(event.Type), event.Reason, event.RemoteAddr, event.Timestamp.UnixMilli())
+	return err
+}
+
+// ListConnectionEvents returns the most recent events for an agent, newest first.
+func (s *SQLiteStore) ListConnectionEvents(agent string, limit int) ([]ConnectionEvent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(`
+		SELECT id, agent, type, reason, remote_addr, ts FROM connection_events
+		WHERE agent = ? ORDER BY ts DESC, id DESC LIMIT ?`, agent, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ConnectionEvent{}
+	for rows.Next() {
+		var ev ConnectionEvent
+		var eventType string
+		var ts int64
+		if err := rows.Scan(&ev.ID, &ev.Agent, &eventType, &ev.Reason, &ev.RemoteAddr, &ts); err != nil {
+			return nil, err
+		}
+		ev.Type = ConnectionEventType(eventType)
+		ev.Timestamp = time.UnixMilli(ts)
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLiteStore) CreateJoinToken(token JoinToken) error {
+	_, err := s.db.Exec(`
+		INSERT INTO join_tokens (token_hash, id, agent, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		token.TokenHash, token.ID, token.Agent, token.CreatedBy, token.CreatedAt.UnixMilli(), token.ExpiresAt.UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) ConsumeJoinToken(tokenHash string, agent string, usedFrom string) (*JoinToken, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token, err := scanJoinToken(tx.QueryRow(`
+		SELECT token_hash, id, agent, created_by, created_at, expires_at, used_at, used_from
+		FROM join_tokens WHERE token_hash = ?`, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case token.UsedAt != nil:
+		return token, ErrTokenUsed
+	case now.After(token.ExpiresAt):
+		return token, ErrTokenExpired
+	case token.Agent != agent:
+		return token, ErrTokenAgent
+	}
+
+	res, err := tx.Exec(`UPDATE join_tokens SET used_at = ?, used_from = ? WHERE token_hash = ? AND used_at IS NULL`,
+		now.UnixMilli(), usedFrom, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return token, ErrTokenUsed
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	token.UsedAt = &now
+	token.UsedFrom = usedFrom
+	return token, nil
+}
+
+func (s *SQLiteStore) ListJoinTokens() ([]JoinToken, error) {
+	rows, err := s.db.Query(`
+		SELECT token_hash, id, agent, created_by, created_at, expires_at, used_at, used_from
+		FROM join_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []JoinToken{}
+	for rows.Next() {
+		token, err := scanJoinToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJoinToken(row rowScanner) (*JoinToken, error) {
+	var token JoinToken
+	var createdAt, expiresAt int64
+	var usedAt sql.NullInt64
+	err := row.Scan(&token.TokenHash, &token.ID, &token.Agent, &token.CreatedBy, &createdAt, &expiresAt, &usedAt, &token.UsedFrom)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.CreatedAt = time.UnixMilli(createdAt)
+	token.ExpiresAt = time.UnixMilli(expiresAt)
+	if usedAt.Valid {
+		t := time.UnixMilli(usedAt.Int64)
+		token.UsedAt = &t
+	}
+	return &token, nil
+}
+
+func (s *SQLiteStore) SaveAgentLocation(location AgentLocation) error {
+	_, err := s.db.Exec(`
+		INSERT INTO agent_locations (agent, replica, address, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(agent) DO UPDATE SET
+			replica = excluded.replica, address = excluded.address, updated_at = excluded.updated_at`,
+		location.Agent, location.Replica, location.Address, location.UpdatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) DeleteAgentLocation(agent string, replica string) error {
+	_, err := s.db.Exec(`DELETE FROM agent_locations WHERE agent = ? AND replica = ?`, agent, replica)
+	return err
+}
+
+func (s *SQLiteStore) GetAgentLocation(agent string) (*AgentLocation, error) {
+	location := AgentLocation{Agent: agent}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT replica, address, updated_at FROM agent_locations WHERE agent = ?`, agent).
+		Scan(&location.Replica, &location.Address, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	location.UpdatedAt = time.UnixMilli(updatedAt)
+	return &location, nil
+}
+
+func (s *SQLiteStore) RecordAudit(entry AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (ts, user, agent, action, target, summary, result, status_code, error, remote_addr, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp.UnixMilli(), entry.User, entry.Agent, entry.Action, entry.Target, entry.Summary,
+		string(entry.Result), entry.StatusCode, entry.Error, entry.RemoteAddr, entry.DurationMs)
+	return err
+}
+
+func (s *SQLiteStore) ListAudit(filter AuditFilter) ([]AuditEntry, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	for _, match := range []struct{ column, value string }{
+		{"user", filter.User},
+		{"agent", filter.Agent},
+		{"action", filter.Action},
+		{"result", string(filter.Result)},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+" = ?")
+			args = append(args, match.value)
+		}
+	}
+	if filter.Target != "" {
+		where = append(where, `target LIKE ? ESCAPE '\'`)
+		args = append(args, likeEscaper.Replace(filter.Target)+"%")
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, filter.Since.UnixMilli())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "ts < ?")
+		args = append(args, filter.Until.UnixMilli())
+	}
+	if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+	query := `
+		SELECT id, ts, user, agent, action, target, summary, result, status_code, error, remote_addr, duration_ms
+		FROM audit_log WHERE ` + strings.Join(where, " AND ") + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var ts int64
+		var result string
+		if err := rows.Scan(&entry.ID, &ts, &entry.User, &entry.Agent, &entry.Action, &entry.Target, &entry.Summary,
+			&result, &entry.StatusCode, &entry.Error, &entry.RemoteAddr, &entry.DurationMs); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = time.UnixMilli(ts)
+		entry.Result = AuditResult(result)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStore) SaveTerminalRecording(recording TerminalRecording) error {
+	command, err := json.Marshal(recording.Command)
+	if err != nil {
+		return err
+	}
+	var endedAt sql.NullInt64
+	if recording.EndedAt != nil {
+		endedAt = sql.NullInt64{Int64: recording.EndedAt.UnixMilli(), Valid: true}
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO terminal_recordings (id, agent, user, namespace, pod, container, command, path, bytes, started_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET bytes = excluded.bytes, ended_at = excluded.ended_at`,
+		recording.ID, recording.Agent, recording.User, recording.Namespace, recording.Pod, recording.Container,
+		string(command), recording.Path, recording.Bytes, recording.StartedAt.UnixMilli(), endedAt)
+	return err
+}
+
+const terminalRecordingColumns = `id, agent, user, namespace, pod, container, command, path, bytes, started_at, ended_at`
+
+func (s *SQLiteStore) GetTerminalRecording(id string) (*TerminalRecording, error) {
+	return scanTerminalRecording(s.db.QueryRow(`SELECT `+terminalRecordingColumns+` FROM terminal_recordings WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) ListTerminalRecordings(filter TerminalRecordingFilter) ([]TerminalRecording, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	for _, match := range []struct{ column, value string }{
+		{"agent", filter.Agent},
+		{"user", filter.User},
+		{"namespace", filter.Namespace},
+		{"pod", filter.Pod},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+" = ?")
+			args = append(args, match.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "started_at >= ?")
+		args = append(args, filter.Since.UnixMilli())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "started_at < ?")
+		args = append(args, filter.Until.UnixMilli())
+	}
+	query := `SELECT ` + terminalRecordingColumns + ` FROM terminal_recordings
+		WHERE ` + strings.Join(where, " AND ") + ` ORDER BY started_at DESC, id`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordings := []TerminalRecording{}
+	for rows.Next() {
+		recording, err := scanTerminalRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, *recording)
+	}
+	return recordings, rows.Err()
+}
+
+func scanTerminalRecording(row rowScanner) (*TerminalRecording, error) {
+	var recording TerminalRecording
+	var command string
+	var startedAt int64
+	var endedAt sql.NullInt64
+	err := row.Scan(&recording.ID, &recording.Agent, &recording.User, &recording.Namespace, &recording.Pod,
+		&recording.Container, &command, &recording.Path, &recording.Bytes, &startedAt, &endedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(command), &recording.Command); err != nil {
+		return nil, fmt.Errorf("error decoding recording command: %v", err)
+	}
+	recording.StartedAt = time.UnixMilli(startedAt)
+	if endedAt.Valid {
+		t := time.UnixMilli(endedAt.Int64)
+		recording.EndedAt = &t
+	}
+	return &recording, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *SQLiteStore) SaveUpgradeWave(wave UpgradeWave) error {
+	if wave.ID == "" {
+		return errors.New("upgrade wave id is required")
+	}
+	data, err := json.Marshal(wave)
+	if err != nil {
+		return fmt.Errorf("error encoding upgrade wave: %v", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO upgrade_waves (id, data, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data`,
+		wave.ID, string(data), wave.CreatedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) ListUpgradeWaves() ([]UpgradeWave, error) {
+	rows, err := s.db.Query(`SELECT data FROM upgrade_waves ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	waves := []UpgradeWave{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var wave UpgradeWave
+		if err := json.Unmarshal([]byte(data), &wave); err != nil {
+			return nil, fmt.Errorf("error decoding upgrade wave: %v", err)
+		}
+		waves = append(waves, wave)
+	}
+	return waves, rows.Err()
+}
+
+// Shared is false, the database is a file on one replica's volume.
+func (s *SQLiteStore) Shared() bool {
+	return false
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
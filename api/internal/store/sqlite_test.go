@@ -0,0 +1,464 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteAgents(t *testing.T) {
+	s := newTestStore(t)
+
+	agent := Agent{
+		Name:            "agent1",
+		Certificate:     "cert",
+		PrivateKey:      "key",
+		AccessKey:       "AKIA",
+		SecretAccessKey: "secret",
+		Metadata:        Metadata{Labels: map[string]string{"environment": "prod"}},
+	}
+	if err := s.SaveAgent(agent); err != nil {
+		t.Fatalf("SaveAgent: %v", err)
+	}
+	if err := s.SaveAgent(Agent{}); err == nil {
+		t.Error("SaveAgent without a name succeeded")
+	}
+
+	got, err := s.GetAgent("agent1")
+	if err != nil {
+		t.Fatalf("GetAgent: %v", err)
+	}
+	if got.Certificate != "cert" || got.Metadata.Labels["environment"] != "prod" {
+		t.Errorf("GetAgent = %+v, want the saved agent", got)
+	}
+	if got.PrivateKey != "" || got.AccessKey != "" || got.SecretAccessKey != "" {
+		t.Errorf("secrets were stored: %+v", got)
+	}
+
+	agent.Version = "1.2.0"
+	if err := s.SaveAgent(agent); err != nil {
+		t.Fatalf("SaveAgent update: %v", err)
+	}
+	if err := s.SaveAgent(Agent{Name: "agent0"}); err != nil {
+		t.Fatal(err)
+	}
+	agents, err := s.ListAgents()
+	if err != nil {
+		t.Fatalf("ListAgents: %v", err)
+	}
+	if len(agents) != 2 || agents[0].Name != "agent0" || agents[1].Version != "1.2.0" {
+		t.Errorf("ListAgents = %+v, want agent0 and the updated agent1", agents)
+	}
+
+	if _, err := s.GetAgent("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAgent of a missing agent = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteDeleteAgent(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+
+	for _, name := range []string{"agent1", "agent2"} {
+		if err := s.SaveAgent(Agent{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveStatus(StatusSample{Agent: name, Timestamp: now, HealthStatus: "OK"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordConnectionEvent(ConnectionEvent{Agent: name, Type: EventConnected}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveAgentLocation(AgentLocation{Agent: name, Replica: "r1", Address: "10.0.0.1:50051", UpdatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		for _, hash := range []string{name + "-used", name + "-unused"} {
+			if err := s.CreateJoinToken(JoinToken{TokenHash: hash, ID: hash, Agent: name, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.ConsumeJoinToken(name+"-used", name, "10.0.0.2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.DeleteAgent("agent1"); err != nil {
+		t.Fatalf("DeleteAgent: %v", err)
+	}
+
+	if _, err := s.GetAgent("agent1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAgent after delete = %v, want ErrNotFound", err)
+	}
+	if _, err := s.LastStatus("agent1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LastStatus after delete = %v, want ErrNotFound", err)
+	}
+	if points, _ := s.QueryStatus("agent1", now.Add(-time.Hour), now.Add(time.Hour), time.Minute); len(points) != 0 {
+		t.Errorf("status history left after delete: %+v", points)
+	}
+	if events, _ := s.ListConnectionEvents("agent1", 0); len(events) != 0 {
+		t.Errorf("connection events left after delete: %+v", events)
+	}
+	if _, err := s.GetAgentLocation("agent1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAgentLocation after delete = %v, want ErrNotFound", err)
+	}
+
+	// Used tokens stay as a record of the enrollment
+	tokens, err := s.ListJoinTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, token := range tokens {
+		ids = append(ids, token.ID)
+	}
+	slices.Sort(ids)
+	if want := []string{"agent1-used", "agent2-unused", "agent2-used"}; !slices.Equal(ids, want) {
+		t.Errorf("join tokens after delete = %v, want %v", ids, want)
+	}
+
+	// Other agents are untouched
+	if _, err := s.GetAgent("agent2"); err != nil {
+		t.Errorf("GetAgent(agent2): %v", err)
+	}
+	if events, _ := s.ListConnectionEvents("agent2", 0); len(events) != 1 {
+		t.Errorf("agent2 has %d connection events, want 1", len(events))
+	}
+	if _, err := s.GetAgentLocation("agent2"); err != nil {
+		t.Errorf("GetAgentLocation(agent2): %v", err)
+	}
+}
+
+func TestSQLiteStatus(t *testing.T) {
+	s := newTestStore(t)
+	base := time.UnixMilli(1_700_000_000_000).Truncate(time.Minute)
+
+	samples := []StatusSample{
+		{Agent: "agent1", Timestamp: base, CpuUsage: 10, PodCount: 4, HealthStatus: "OK"},
+		{Agent: "agent1", Timestamp: base.Add(20 * time.Second), CpuUsage: 30, PodCount: 6, HealthStatus: "Degraded"},
+		{Agent: "agent1", Timestamp: base.Add(time.Minute), CpuUsage: 50, PodCount: 8, HealthStatus: "OK",
+			ClusterHealth: ClusterHealth{PendingPods: 2}},
+		{Agent: "agent2", Timestamp: base, CpuUsage: 90, HealthStatus: "OK"},
+	}
+	for _, sample := range samples {
+		if err := s.SaveStatus(sample); err != nil {
+			t.Fatalf("SaveStatus: %v", err)
+		}
+	}
+
+	last, err := s.LastStatus("agent1")
+	if err != nil {
+		t.Fatalf("LastStatus: %v", err)
+	}
+	if last.CpuUsage != 50 || last.PendingPods != 2 {
+		t.Errorf("LastStatus = %+v, want the latest sample", last)
+	}
+
+	points, err := s.QueryStatus("agent1", base, base.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("QueryStatus returned %d buckets, want 2", len(points))
+	}
+	if p := points[0]; p.Samples != 2 || p.CpuAvg != 20 || p.CpuMax != 30 || p.PodCountMax != 6 || p.Unhealthy != 1 {
+		t.Errorf("first bucket = %+v", p)
+	}
+	if p := points[1]; p.Samples != 1 || p.PendingPodsMax != 2 || p.Unhealthy != 0 {
+		t.Errorf("second bucket = %+v", p)
+	}
+	if _, err := s.QueryStatus("agent1", base, base.Add(time.Hour), 0); err == nil {
+		t.Error("QueryStatus with a zero step succeeded")
+	}
+
+	pruned, err := s.PruneStatus(base.Add(time.Second))
+	if err != nil {
+		t.Fatalf("PruneStatus: %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneStatus removed %d samples, want 2", pruned)
+	}
+}
+
+func TestSQLiteConnectionEvents(t *testing.T) {
+	s := newTestStore(t)
+	base := time.Now()
+
+	for i, eventType := range []ConnectionEventType{EventConnected, EventDegraded, EventDisconnected} {
+		err := s.RecordConnectionEvent(ConnectionEvent{
+			Agent:     "agent1",
+			Type:      eventType,
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("RecordConnectionEvent: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []ConnectionEventType
+	}{
+		{"newest first", 0, []ConnectionEventType{EventDisconnected, EventDegraded, EventConnected}},
+		{"limited", 2, []ConnectionEventType{EventDisconnected, EventDegraded}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.ListConnectionEvents("agent1", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []ConnectionEventType
+			for _, ev := range events {
+				got = append(got, ev.Type)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteConsumeJoinToken(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		token   JoinToken
+		agent   string
+		twice   bool
+		wantErr error
+	}{
+		{"valid", JoinToken{Agent: "agent1", ExpiresAt: now.Add(time.Hour)}, "agent1", false, nil},
+		{"used twice", JoinToken{Agent: "agent1", ExpiresAt: now.Add(time.Hour)}, "agent1", true, ErrTokenUsed},
+		{"expired", JoinToken{Agent: "agent1", ExpiresAt: now.Add(-time.Second)}, "agent1", false, ErrTokenExpired},
+		{"other agent", JoinToken{Agent: "agent1", ExpiresAt: now.Add(time.Hour)}, "agent2", false, ErrTokenAgent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			tt.token.TokenHash = "hash"
+			tt.token.ID = "id"
+			tt.token.CreatedAt = now
+			if err := s.CreateJoinToken(tt.token); err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := s.ConsumeJoinToken("hash", tt.agent, "10.0.0.2")
+			if tt.twice {
+				if err != nil {
+					t.Fatalf("first ConsumeJoinToken: %v", err)
+				}
+				_, err = s.ConsumeJoinToken("hash", tt.agent, "10.0.0.3")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeJoinToken error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (token.UsedAt == nil || token.UsedFrom != "10.0.0.2") {
+				t.Errorf("consumed token = %+v, want it marked used", token)
+			}
+		})
+	}
+
+	s := newTestStore(t)
+	if _, err := s.ConsumeJoinToken("missing", "agent1", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ConsumeJoinToken of a missing token = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteAgentLocations(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+
+	if err := s.SaveAgentLocation(AgentLocation{Agent: "agent1", Replica: "r1", Address: "a1", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveAgentLocation(AgentLocation{Agent: "agent1", Replica: "r2", Address: "a2", UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// r1 no longer holds the agent, its delete must not drop r2's location
+	if err := s.DeleteAgentLocation("agent1", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	location, err := s.GetAgentLocation("agent1")
+	if err != nil {
+		t.Fatalf("GetAgentLocation: %v", err)
+	}
+	if location.Replica != "r2" || location.Address != "a2" {
+		t.Errorf("GetAgentLocation = %+v, want r2", location)
+	}
+
+	if err := s.DeleteAgentLocation("agent1", "r2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetAgentLocation("agent1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAgentLocation after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteUpgradeWaves(t *testing.T) {
+	s := newTestStore(t)
+	base := time.Now()
+
+	older := UpgradeWave{ID: "w1", Version: "1.1.0", State: WaveCompleted, CreatedAt: base.Add(-time.Hour)}
+	newer := UpgradeWave{ID: "w2", Version: "1.2.0", State: WaveRunning, Agents: []string{"agent1"}, CreatedAt: base}
+	for _, wave := range []UpgradeWave{newer, older} {
+		if err := s.SaveUpgradeWave(wave); err != nil {
+			t.Fatalf("SaveUpgradeWave: %v", err)
+		}
+	}
+	if err := s.SaveUpgradeWave(UpgradeWave{}); err == nil {
+		t.Error("SaveUpgradeWave without an id succeeded")
+	}
+
+	newer.State = WaveHalted
+	newer.Batch = 1
+	if err := s.SaveUpgradeWave(newer); err != nil {
+		t.Fatalf("SaveUpgradeWave update: %v", err)
+	}
+
+	waves, err := s.ListUpgradeWaves()
+	if err != nil {
+		t.Fatalf("ListUpgradeWaves: %v", err)
+	}
+	if len(waves) != 2 || waves[0].ID != "w2" || waves[1].ID != "w1" {
+		t.Fatalf("ListUpgradeWaves = %+v, want w2 then w1", waves)
+	}
+	if waves[0].State != WaveHalted || waves[0].Batch != 1 || !slices.Equal(waves[0].Agents, []string{"agent1"}) {
+		t.Errorf("updated wave = %+v", waves[0])
+	}
+}
+
+func TestAgentAddCertificate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		existing []IssuedCertificate
+		serial   string
+		want     []string
+	}{
+		{"first certificate", nil, "1", []string{"1"}},
+		{
+			name:     "renewal keeps the valid one",
+			existing: []IssuedCertificate{{SerialNumber: "1", NotAfter: now.Add(time.Hour)}},
+			serial:   "2",
+			want:     []string{"1", "2"},
+		},
+		{
+			name:     "expired ones are dropped",
+			existing: []IssuedCertificate{{SerialNumber: "1", NotAfter: now.Add(-time.Hour)}},
+			serial:   "2",
+			want:     []string{"2"},
+		},
+		{
+			name:     "same serial is recorded once",
+			existing: []IssuedCertificate{{SerialNumber: "1", NotAfter: now.Add(time.Hour)}},
+			serial:   "1",
+			want:     []string{"1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := Agent{Certificates: tt.existing}
+			agent.AddCertificate(tt.serial, now.Add(24*time.Hour))
+			var got []string
+			for _, c := range agent.Certificates {
+				got = append(got, c.SerialNumber)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("certificates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteAuditAppendOnly(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.RecordAudit(AuditEntry{User: "alice", Action: "agent.delete", Result: AuditSuccess}); err != nil {
+		t.Fatalf("RecordAudit: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		stmt string
+	}{
+		{"update", `UPDATE audit_log SET user = 'mallory'`},
+		{"delete", `DELETE FROM audit_log`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.db.Exec(tt.stmt)
+			if err == nil || !strings.Contains(err.Error(), "append-only") {
+				t.Errorf("%s = %v, want the append-only error", tt.stmt, err)
+			}
+		})
+	}
+
+	entries, err := s.ListAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].User != "alice" {
+		t.Errorf("audit log changed: %+v", entries)
+	}
+}
+
+func TestSQLiteListAudit(t *testing.T) {
+	s := newTestStore(t)
+	base := time.UnixMilli(1_700_000_000_000)
+
+	for i, entry := range []AuditEntry{
+		{User: "alice", Agent: "agent1", Action: "proxy.patch", Target: "/api/v1/pods", Result: AuditSuccess},
+		{User: "bob", Agent: "agent1", Action: "agent.delete", Target: "agent1", Result: AuditFailure},
+		{User: "alice", Agent: "agent2", Action: "proxy.delete", Target: "/apis/apps/v1", Result: AuditSuccess},
+		{User: "alice", Agent: "agent2", Action: "proxy.delete", Target: "/api_v1", Result: AuditSuccess},
+	} {
+		entry.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		if err := s.RecordAudit(entry); err != nil {
+			t.Fatalf("RecordAudit: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int64
+	}{
+		{"everything newest first", AuditFilter{}, []int64{4, 3, 2, 1}},
+		{"user", AuditFilter{User: "alice"}, []int64{4, 3, 1}},
+		{"agent and action", AuditFilter{Agent: "agent2", Action: "proxy.delete"}, []int64{4, 3}},
+		{"result", AuditFilter{Result: AuditFailure}, []int64{2}},
+		{"target prefix", AuditFilter{Target: "/api"}, []int64{4, 3, 1}},
+		{"target wildcards are literal", AuditFilter{Target: "/api_"}, []int64{4}},
+		{"time range", AuditFilter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, []int64{3, 2}},
+		{"page", AuditFilter{BeforeID: 4, Limit: 2}, []int64{3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := s.ListAudit(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, entry := range entries {
+				got = append(got, entry.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListAudit(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
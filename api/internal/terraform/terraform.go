@@ -16,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
+	"github.com/uc-cdis/gen3-admin/internal/metrics"
 )
 
 type RuntimeType string
@@ -45,6 +48,9 @@ const (
 	StatusUnknown  ExecutionStatus = "unknown"
 )
 
+// terraformPollInterval is how often a launched execution is checked until it finishes
+const terraformPollInterval = 10 * time.Second
+
 type TerraformRequest struct {
 	Operation   TerraformOperation `json:"operation" binding:"required"`
 	WorkDir     string             `json:"work_dir" binding:"required"`
@@ -474,6 +480,7 @@ func HandleTerraformExecute() gin.HandlerFunc {
 		}
 
 		executionID := uuid.New().String()
+		audit.Describe(c, "execution "+executionID, fmt.Sprintf("terraform %s via %s", req.Operation, req.Runtime))
 
 		var cmd *exec.Cmd
 		switch req.Runtime {
@@ -532,6 +539,7 @@ func HandleTerraformExecute() gin.HandlerFunc {
 						Str("stderr", res.Stderr).
 						Msg("Execution failed early")
 
+					metrics.TerraformExecutions.WithLabelValues(string(req.Operation), string(req.Runtime), string(StatusError)).Inc()
 					c.JSON(500, gin.H{
 						"id":      executionID,
 						"message": "Terraform execution failed to start",
@@ -546,28 +554,22 @@ func HandleTerraformExecute() gin.HandlerFunc {
 
 			time.Sleep(500 * time.Millisecond)
 
-			var execs []*TerraformExecution
-			if req.Runtime == RuntimeDocker {
-				execs, _ = queryDockerExecutions()
-			} else {
-				execs, _ = queryKubernetesPods(req.Namespace)
-			}
-
-			for _, exec := range execs {
-				if exec.ID == executionID {
-					c.JSON(202, gin.H{
-						"id":      executionID,
-						"message": fmt.Sprintf("Terraform %s execution started", req.Operation),
-						"runtime": req.Runtime,
-					})
-					return
-				}
+			if exec, _ := findTerraformExecution(&req, executionID); exec != nil {
+				metrics.TerraformExecutionsRunning.Inc()
+				go countTerraformExecution(req, executionID)
+				c.JSON(202, gin.H{
+					"id":      executionID,
+					"message": fmt.Sprintf("Terraform %s execution started", req.Operation),
+					"runtime": req.Runtime,
+				})
+				return
 			}
 		}
 
 		// timeout or late failure
 		res := <-errCh
 		if res.Err != nil {
+			metrics.TerraformExecutions.WithLabelValues(string(req.Operation), string(req.Runtime), string(StatusError)).Inc()
 			log.Error().
 				Err(res.Err).
 				Str("stderr", res.Stderr).
@@ -581,12 +583,62 @@ func HandleTerraformExecute() gin.HandlerFunc {
 				"runtime": req.Runtime,
 			})
 		} else {
+			metrics.TerraformExecutions.WithLabelValues(string(req.Operation), string(req.Runtime), string(StatusUnknown)).Inc()
 			c.JSON(504, gin.H{"error": "Execution not visible after timeout"})
 		}
 
 	}
 }
 
+// findTerraformExecution returns the launched execution, nil when its
+// container or pod doesn't exist.
+func findTerraformExecution(req *TerraformRequest, executionID string) (*TerraformExecution, error) {
+	var execs []*TerraformExecution
+	var err error
+	if req.Runtime == RuntimeDocker {
+		execs, err = queryDockerExecutions()
+	} else {
+		execs, err = queryKubernetesPods(req.Namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, exec := range execs {
+		if exec.ID == executionID {
+			return exec, nil
+		}
+	}
+	return nil, nil
+}
+
+// countTerraformExecution waits for a launched execution to finish and counts
+// it with its final status. One removed before finishing, by a terminate or
+// by hand, counts as "unknown".
+func countTerraformExecution(req TerraformRequest, executionID string) {
+	status := StatusUnknown
+	defer func() {
+		metrics.TerraformExecutionsRunning.Dec()
+		metrics.TerraformExecutions.WithLabelValues(string(req.Operation), string(req.Runtime), string(status)).Inc()
+	}()
+
+	ticker := time.NewTicker(terraformPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		exec, err := findTerraformExecution(&req, executionID)
+		if err != nil {
+			log.Warn().Err(err).Str("execution_id", executionID).Msg("Failed to check terraform execution")
+			continue
+		}
+		if exec == nil {
+			return
+		}
+		if exec.Status == StatusComplete || exec.Status == StatusError {
+			status = exec.Status
+			return
+		}
+	}
+}
+
 func HandleGetTerraformExecution() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		execID := c.Param("id")
@@ -0,0 +1,35 @@
+package tunnel
+
+// Capabilities an agent advertises in its RegistrationRequest. The server
+// checks them before sending a request the agent might not understand.
+const (
+	CapabilityK8sProxy          = "k8s-proxy"
+	CapabilityHTTPProxy         = "http-proxy"
+	CapabilityHelm              = "helm"
+	CapabilityTerminal          = "terminal"
+	CapabilityDbUiPostgres      = "dbui-pg"
+	CapabilityDbUiElasticsearch = "dbui-es"
+	CapabilityCertRenewal       = "cert-renewal"
+	CapabilitySelfUpgrade       = "self-upgrade"
+	CapabilityFlowControl       = "flow-control"
+	CapabilityRequestStreaming  = "request-streaming"
+	CapabilityPortForward       = "port-forward"
+	CapabilityK8sUpgrade        = "k8s-upgrade"
+	// CapabilityTerminalControl agents take typed resize, signal and close frames
+	// and pick the exec command, TTY and initial size from the init request
+	CapabilityTerminalControl = "terminal-control"
+	// CapabilityTerminalDebug agents attach terminals to ephemeral debug
+	// containers and node debug pods
+	CapabilityTerminalDebug = "terminal-debug"
+)
+
+// LegacyCapabilities are assumed for agents that register without a
+// capabilities list, they predate capability negotiation.
+var LegacyCapabilities = []string{
+	CapabilityK8sProxy,
+	CapabilityHTTPProxy,
+	CapabilityHelm,
+	CapabilityTerminal,
+	CapabilityDbUiPostgres,
+	CapabilityDbUiElasticsearch,
+}
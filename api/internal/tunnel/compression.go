@@ -0,0 +1,95 @@
+package tunnel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs for ProxyResponse bodies, negotiated at registration
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// SupportedCompression lists the codecs this build can encode and decode,
+// preferred first.
+var SupportedCompression = []string{CompressionZstd, CompressionGzip}
+
+// maxDecompressedSize guards against frames that inflate far beyond any
+// chunk an agent sends.
+const maxDecompressedSize = 64 << 20
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+)
+
+// NegotiateCompression picks the first codec of ours the agent offered, or
+// "" to send bodies as is.
+func NegotiateCompression(ours, offered []string) string {
+	for _, codec := range ours {
+		if slices.Contains(offered, codec) && slices.Contains(SupportedCompression, codec) {
+			return codec
+		}
+	}
+	return ""
+}
+
+// Compress encodes data with codec.
+func Compress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %q", codec)
+}
+
+// Decompress decodes data encoded by Compress.
+func Decompress(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > maxDecompressedSize {
+			return nil, fmt.Errorf("decompressed body exceeds %d bytes", maxDecompressedSize)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported compression %q", codec)
+}
+
+// IsJSONContentType reports whether a Content-Type is JSON, including
+// application/*+json types like the k8s API's.
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,121 @@
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNegotiateCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		ours    []string
+		offered []string
+		want    string
+	}{
+		{"our preference wins", []string{CompressionZstd, CompressionGzip}, []string{CompressionGzip, CompressionZstd}, CompressionZstd},
+		{"only gzip offered", []string{CompressionZstd, CompressionGzip}, []string{CompressionGzip}, CompressionGzip},
+		{"nothing offered", []string{CompressionZstd, CompressionGzip}, nil, ""},
+		{"compression disabled", nil, []string{CompressionZstd}, ""},
+		{"unknown codec", []string{"br", CompressionGzip}, []string{"br", CompressionGzip}, CompressionGzip},
+		{"no codec in common", []string{CompressionZstd}, []string{CompressionGzip}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateCompression(tt.ours, tt.offered); got != tt.want {
+				t.Errorf("NegotiateCompression(%v, %v) = %q, want %q", tt.ours, tt.offered, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"kind":"PodList","items":[]}`), 1000)
+	for _, codec := range SupportedCompression {
+		t.Run(codec, func(t *testing.T) {
+			compressed, err := Compress(codec, body)
+			if err != nil {
+				t.Fatalf("Compress: %v", err)
+			}
+			if len(compressed) >= len(body) {
+				t.Errorf("compressed to %d bytes, expected less than %d", len(compressed), len(body))
+			}
+			out, err := Decompress(codec, compressed)
+			if err != nil {
+				t.Fatalf("Decompress: %v", err)
+			}
+			if !bytes.Equal(out, body) {
+				t.Error("round trip changed the body")
+			}
+		})
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"at the limit", maxDecompressedSize, false},
+		{"over the limit", maxDecompressedSize + 1, true},
+	}
+	for _, codec := range SupportedCompression {
+		for _, tt := range tests {
+			t.Run(codec+"/"+tt.name, func(t *testing.T) {
+				compressed, err := Compress(codec, make([]byte, tt.size))
+				if err != nil {
+					t.Fatalf("Compress: %v", err)
+				}
+				out, err := Decompress(codec, compressed)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatalf("Decompress of %d bytes succeeded, expected an error", tt.size)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Decompress: %v", err)
+				}
+				if len(out) != tt.size {
+					t.Errorf("got %d bytes, want %d", len(out), tt.size)
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec string
+		data  []byte
+	}{
+		{"unsupported codec", "br", []byte("data")},
+		{"corrupt gzip", CompressionGzip, []byte("not gzip")},
+		{"corrupt zstd", CompressionZstd, []byte("not zstd")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decompress(tt.codec, tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := map[string]bool{
+		"application/json":                    true,
+		"application/json; charset=utf-8":     true,
+		"application/merge-patch+json":        true,
+		"application/vnd.kubernetes.protobuf": false,
+		"text/plain":                          false,
+		"":                                    false,
+		"application/json-seq":                false,
+	}
+	for contentType, want := range tests {
+		if got := IsJSONContentType(contentType); got != want {
+			t.Errorf("IsJSONContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
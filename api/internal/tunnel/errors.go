@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StatusClientClosedRequest is answered when the caller went away first
+const StatusClientClosedRequest = 499
+
+// reasonCodes maps the Kubernetes API's status reasons to tunnel codes
+var reasonCodes = map[metav1.StatusReason]TunnelErrorCode{
+	metav1.StatusReasonNotFound:              TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND,
+	metav1.StatusReasonForbidden:             TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN,
+	metav1.StatusReasonUnauthorized:          TunnelErrorCode_TUNNEL_ERROR_UNAUTHORIZED,
+	metav1.StatusReasonInvalid:               TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonBadRequest:            TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonMethodNotAllowed:      TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonNotAcceptable:         TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonUnsupportedMediaType:  TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonRequestEntityTooLarge: TunnelErrorCode_TUNNEL_ERROR_INVALID,
+	metav1.StatusReasonAlreadyExists:         TunnelErrorCode_TUNNEL_ERROR_CONFLICT,
+	metav1.StatusReasonConflict:              TunnelErrorCode_TUNNEL_ERROR_CONFLICT,
+	metav1.StatusReasonTimeout:               TunnelErrorCode_TUNNEL_ERROR_TIMEOUT,
+	metav1.StatusReasonServerTimeout:         TunnelErrorCode_TUNNEL_ERROR_TIMEOUT,
+	metav1.StatusReasonTooManyRequests:       TunnelErrorCode_TUNNEL_ERROR_TOO_MANY_REQUESTS,
+	metav1.StatusReasonServiceUnavailable:    TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE,
+}
+
+// NewTunnelError wraps message under code.
+func NewTunnelError(code TunnelErrorCode, message string) *TunnelError {
+	return &TunnelError{
+		Code:      code,
+		Message:   message,
+		Retryable: code == TunnelErrorCode_TUNNEL_ERROR_TIMEOUT || code == TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE || code == TunnelErrorCode_TUNNEL_ERROR_TOO_MANY_REQUESTS,
+	}
+}
+
+// TunnelErrorFor classifies err: refusals of the k8s API keep their reason
+// and details, deadlines and network failures are retryable, anything else
+// is internal.
+func TunnelErrorFor(err error) *TunnelError {
+	if err == nil {
+		return nil
+	}
+	var te *TunnelError
+	if errors.As(err, &te) {
+		return te
+	}
+
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		code, ok := reasonCodes[status.Reason]
+		if !ok {
+			code = TunnelErrorCodeForStatus(int(status.Code))
+		}
+		te = NewTunnelError(code, err.Error())
+		te.Reason = string(status.Reason)
+		// A conflict on update means the object changed underneath, try again
+		if status.Reason == metav1.StatusReasonConflict {
+			te.Retryable = true
+		}
+		if d := status.Details; d != nil {
+			te.Details = map[string]string{}
+			if d.Kind != "" {
+				te.Details["kind"] = d.Kind
+			}
+			if d.Name != "" {
+				te.Details["name"] = d.Name
+			}
+			if d.Group != "" {
+				te.Details["group"] = d.Group
+			}
+			if d.RetryAfterSeconds > 0 {
+				te.Details["retryAfterSeconds"] = strconv.Itoa(int(d.RetryAfterSeconds))
+				te.Retryable = true
+			}
+		}
+		return te
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_TIMEOUT, err.Error())
+	case errors.Is(err, context.Canceled):
+		return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_CANCELLED, err.Error())
+	case errors.Is(err, errors.ErrUnsupported):
+		return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_UNSUPPORTED, err.Error())
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_TIMEOUT, err.Error())
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE, err.Error())
+	}
+	return NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_INTERNAL, err.Error())
+}
+
+// TunnelErrorCodeForStatus maps an HTTP status, as sent by agents that
+// predate TunnelError, to a code.
+func TunnelErrorCodeForStatus(status int) TunnelErrorCode {
+	switch status {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusNotAcceptable,
+		http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return TunnelErrorCode_TUNNEL_ERROR_INVALID
+	case http.StatusUnauthorized:
+		return TunnelErrorCode_TUNNEL_ERROR_UNAUTHORIZED
+	case http.StatusForbidden:
+		return TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN
+	case http.StatusNotFound:
+		return TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND
+	case http.StatusConflict:
+		return TunnelErrorCode_TUNNEL_ERROR_CONFLICT
+	case http.StatusTooManyRequests:
+		return TunnelErrorCode_TUNNEL_ERROR_TOO_MANY_REQUESTS
+	case http.StatusNotImplemented:
+		return TunnelErrorCode_TUNNEL_ERROR_UNSUPPORTED
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE
+	case http.StatusGatewayTimeout:
+		return TunnelErrorCode_TUNNEL_ERROR_TIMEOUT
+	}
+	return TunnelErrorCode_TUNNEL_ERROR_INTERNAL
+}
+
+// HTTPStatus is the status the server answers the error with.
+func (x *TunnelError) HTTPStatus() int {
+	switch x.GetCode() {
+	case TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND:
+		return http.StatusNotFound
+	case TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN:
+		return http.StatusForbidden
+	case TunnelErrorCode_TUNNEL_ERROR_UNAUTHORIZED:
+		return http.StatusUnauthorized
+	case TunnelErrorCode_TUNNEL_ERROR_INVALID:
+		if x.GetReason() == string(metav1.StatusReasonInvalid) {
+			return http.StatusUnprocessableEntity
+		}
+		return http.StatusBadRequest
+	case TunnelErrorCode_TUNNEL_ERROR_CONFLICT:
+		return http.StatusConflict
+	case TunnelErrorCode_TUNNEL_ERROR_TIMEOUT:
+		return http.StatusGatewayTimeout
+	case TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE:
+		return http.StatusServiceUnavailable
+	case TunnelErrorCode_TUNNEL_ERROR_CANCELLED:
+		return StatusClientClosedRequest
+	case TunnelErrorCode_TUNNEL_ERROR_UNSUPPORTED:
+		return http.StatusNotImplemented
+	case TunnelErrorCode_TUNNEL_ERROR_TOO_MANY_REQUESTS:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeName is the code in API responses, e.g. "not_found".
+func (x *TunnelError) CodeName() string {
+	return strings.ToLower(strings.TrimPrefix(x.GetCode().String(), "TUNNEL_ERROR_"))
+}
+
+func (x *TunnelError) Error() string {
+	return x.GetMessage()
+}
@@ -0,0 +1,101 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestTunnelErrorFor(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      string
+		wantStatus    int
+		wantRetryable bool
+		wantReason    string
+	}{
+		{"not found", apierrors.NewNotFound(pods, "web-0"), "not_found", http.StatusNotFound, false, "NotFound"},
+		{"forbidden", apierrors.NewForbidden(pods, "web-0", errors.New("rbac")), "forbidden", http.StatusForbidden, false, "Forbidden"},
+		{"unauthorized", apierrors.NewUnauthorized("token expired"), "unauthorized", http.StatusUnauthorized, false, "Unauthorized"},
+		{"invalid", apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "web-0", nil), "invalid", http.StatusUnprocessableEntity, false, "Invalid"},
+		{"bad request", apierrors.NewBadRequest("bad selector"), "invalid", http.StatusBadRequest, false, "BadRequest"},
+		{"already exists", apierrors.NewAlreadyExists(pods, "web-0"), "conflict", http.StatusConflict, false, "AlreadyExists"},
+		{"update conflict", apierrors.NewConflict(deployments, "web", errors.New("changed")), "conflict", http.StatusConflict, true, "Conflict"},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 5), "too_many_requests", http.StatusTooManyRequests, true, "TooManyRequests"},
+		{"server timeout", apierrors.NewServerTimeout(pods, "list", 2), "timeout", http.StatusGatewayTimeout, true, "ServerTimeout"},
+		{"service unavailable", apierrors.NewServiceUnavailable("etcd down"), "unavailable", http.StatusServiceUnavailable, true, "ServiceUnavailable"},
+		{"unknown reason keeps the status", apierrors.NewGenericServerResponse(http.StatusNotImplemented, "get", pods, "web-0", "", 0, false), "unsupported", http.StatusNotImplemented, false, "InternalError"},
+		{"deadline", fmt.Errorf("listing pods: %w", context.DeadlineExceeded), "timeout", http.StatusGatewayTimeout, true, ""},
+		{"cancelled", context.Canceled, "cancelled", StatusClientClosedRequest, false, ""},
+		{"unsupported", fmt.Errorf("exec: %w", errors.ErrUnsupported), "unsupported", http.StatusNotImplemented, false, ""},
+		{"dial failure", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, "unavailable", http.StatusServiceUnavailable, true, ""},
+		{"other", errors.New("boom"), "internal", http.StatusInternalServerError, false, ""},
+		{"already a tunnel error", fmt.Errorf("wrapped: %w", NewTunnelError(TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN, "no")), "forbidden", http.StatusForbidden, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			te := TunnelErrorFor(tt.err)
+			if te.CodeName() != tt.wantCode || te.HTTPStatus() != tt.wantStatus || te.Retryable != tt.wantRetryable || te.Reason != tt.wantReason {
+				t.Errorf("TunnelErrorFor(%v) = %s %d retryable=%v reason=%q, want %s %d retryable=%v reason=%q",
+					tt.err, te.CodeName(), te.HTTPStatus(), te.Retryable, te.Reason,
+					tt.wantCode, tt.wantStatus, tt.wantRetryable, tt.wantReason)
+			}
+		})
+	}
+
+	if te := TunnelErrorFor(nil); te != nil {
+		t.Errorf("TunnelErrorFor(nil) = %v, want nil", te)
+	}
+}
+
+func TestTunnelErrorDetails(t *testing.T) {
+	te := TunnelErrorFor(apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "web"))
+	if te.Details["kind"] != "deployments" || te.Details["name"] != "web" || te.Details["group"] != "apps" {
+		t.Errorf("details = %v, want the deployment's kind, name and group", te.Details)
+	}
+
+	te = TunnelErrorFor(apierrors.NewTooManyRequests("slow down", 5))
+	if te.Details["retryAfterSeconds"] != "5" {
+		t.Errorf("details = %v, want retryAfterSeconds", te.Details)
+	}
+}
+
+// Agents that predate TunnelError only send a status, it must come back out unchanged
+func TestTunnelErrorCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantStatus int
+	}{
+		{http.StatusBadRequest, http.StatusBadRequest},
+		{http.StatusUnauthorized, http.StatusUnauthorized},
+		{http.StatusForbidden, http.StatusForbidden},
+		{http.StatusNotFound, http.StatusNotFound},
+		{http.StatusConflict, http.StatusConflict},
+		{http.StatusTooManyRequests, http.StatusTooManyRequests},
+		{http.StatusNotImplemented, http.StatusNotImplemented},
+		{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{http.StatusBadGateway, http.StatusServiceUnavailable},
+		{http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+		{http.StatusUnprocessableEntity, http.StatusBadRequest},
+		{http.StatusInternalServerError, http.StatusInternalServerError},
+		{http.StatusTeapot, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			te := NewTunnelError(TunnelErrorCodeForStatus(tt.status), "")
+			if got := te.HTTPStatus(); got != tt.wantStatus {
+				t.Errorf("status %d came back as %d, want %d", tt.status, got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package tunnel
+
+// TerminalSignals are the signals a terminal session takes, by the control
+// character a TTY turns into them. EOF is Ctrl-D, it ends the shell's input.
+var TerminalSignals = map[string]byte{
+	"SIGINT":  0x03,
+	"SIGQUIT": 0x1c,
+	"SIGTSTP": 0x1a,
+	"EOF":     0x04,
+}
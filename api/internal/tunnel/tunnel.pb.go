@@ -20,6 +20,113 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type UpgradeState int32
+
+const (
+	UpgradeState_UPGRADE_UNKNOWN     UpgradeState = 0
+	UpgradeState_UPGRADE_IN_PROGRESS UpgradeState = 1
+	UpgradeState_UPGRADE_SUCCEEDED   UpgradeState = 2
+	UpgradeState_UPGRADE_FAILED      UpgradeState = 3
+)
+
+// Enum value maps for UpgradeState.
+var (
+	UpgradeState_name = map[int32]string{
+		0: "UPGRADE_UNKNOWN",
+		1: "UPGRADE_IN_PROGRESS",
+		2: "UPGRADE_SUCCEEDED",
+		3: "UPGRADE_FAILED",
+	}
+	UpgradeState_value = map[string]int32{
+		"UPGRADE_UNKNOWN":     0,
+		"UPGRADE_IN_PROGRESS": 1,
+		"UPGRADE_SUCCEEDED":   2,
+		"UPGRADE_FAILED":      3,
+	}
+)
+
+func (x UpgradeState) Enum() *UpgradeState {
+	p := new(UpgradeState)
+	*p = x
+	return p
+}
+
+func (x UpgradeState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UpgradeState) Descriptor() protoreflect.EnumDescriptor {
+	return file_tunnel_proto_enumTypes[0].Descriptor()
+}
+
+func (UpgradeState) Type() protoreflect.EnumType {
+	return &file_tunnel_proto_enumTypes[0]
+}
+
+func (x UpgradeState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UpgradeState.Descriptor instead.
+func (UpgradeState) EnumDescriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{0}
+}
+
+type PortForwardType int32
+
+const (
+	PortForwardType_PORT_FORWARD_UNKNOWN PortForwardType = 0
+	PortForwardType_PORT_FORWARD_OPEN    PortForwardType = 1 // server: dial the target, agent: the target is connected
+	PortForwardType_PORT_FORWARD_DATA    PortForwardType = 2
+	PortForwardType_PORT_FORWARD_CLOSE   PortForwardType = 3 // the sender is done, the stream ends after the data sent before it
+	PortForwardType_PORT_FORWARD_ERROR   PortForwardType = 4 // the stream failed and is dropped right away
+)
+
+// Enum value maps for PortForwardType.
+var (
+	PortForwardType_name = map[int32]string{
+		0: "PORT_FORWARD_UNKNOWN",
+		1: "PORT_FORWARD_OPEN",
+		2: "PORT_FORWARD_DATA",
+		3: "PORT_FORWARD_CLOSE",
+		4: "PORT_FORWARD_ERROR",
+	}
+	PortForwardType_value = map[string]int32{
+		"PORT_FORWARD_UNKNOWN": 0,
+		"PORT_FORWARD_OPEN":    1,
+		"PORT_FORWARD_DATA":    2,
+		"PORT_FORWARD_CLOSE":   3,
+		"PORT_FORWARD_ERROR":   4,
+	}
+)
+
+func (x PortForwardType) Enum() *PortForwardType {
+	p := new(PortForwardType)
+	*p = x
+	return p
+}
+
+func (x PortForwardType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PortForwardType) Descriptor() protoreflect.EnumDescriptor {
+	return file_tunnel_proto_enumTypes[1].Descriptor()
+}
+
+func (PortForwardType) Type() protoreflect.EnumType {
+	return &file_tunnel_proto_enumTypes[1]
+}
+
+func (x PortForwardType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PortForwardType.Descriptor instead.
+func (PortForwardType) EnumDescriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{1}
+}
+
 // Status of the proxy response
 type ProxyResponseType int32
 
@@ -60,11 +167,11 @@ func (x ProxyResponseType) String() string {
 }
 
 func (ProxyResponseType) Descriptor() protoreflect.EnumDescriptor {
-	return file_tunnel_proto_enumTypes[0].Descriptor()
+	return file_tunnel_proto_enumTypes[2].Descriptor()
 }
 
 func (ProxyResponseType) Type() protoreflect.EnumType {
-	return &file_tunnel_proto_enumTypes[0]
+	return &file_tunnel_proto_enumTypes[2]
 }
 
 func (x ProxyResponseType) Number() protoreflect.EnumNumber {
@@ -73,7 +180,138 @@ func (x ProxyResponseType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use ProxyResponseType.Descriptor instead.
 func (ProxyResponseType) EnumDescriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{0}
+	return file_tunnel_proto_rawDescGZIP(), []int{2}
+}
+
+type TunnelErrorCode int32
+
+const (
+	TunnelErrorCode_TUNNEL_ERROR_UNKNOWN           TunnelErrorCode = 0
+	TunnelErrorCode_TUNNEL_ERROR_INTERNAL          TunnelErrorCode = 1
+	TunnelErrorCode_TUNNEL_ERROR_NOT_FOUND         TunnelErrorCode = 2
+	TunnelErrorCode_TUNNEL_ERROR_FORBIDDEN         TunnelErrorCode = 3
+	TunnelErrorCode_TUNNEL_ERROR_UNAUTHORIZED      TunnelErrorCode = 4
+	TunnelErrorCode_TUNNEL_ERROR_INVALID           TunnelErrorCode = 5
+	TunnelErrorCode_TUNNEL_ERROR_CONFLICT          TunnelErrorCode = 6
+	TunnelErrorCode_TUNNEL_ERROR_TIMEOUT           TunnelErrorCode = 7
+	TunnelErrorCode_TUNNEL_ERROR_UNAVAILABLE       TunnelErrorCode = 8
+	TunnelErrorCode_TUNNEL_ERROR_CANCELLED         TunnelErrorCode = 9
+	TunnelErrorCode_TUNNEL_ERROR_UNSUPPORTED       TunnelErrorCode = 10
+	TunnelErrorCode_TUNNEL_ERROR_TOO_MANY_REQUESTS TunnelErrorCode = 11
+)
+
+// Enum value maps for TunnelErrorCode.
+var (
+	TunnelErrorCode_name = map[int32]string{
+		0:  "TUNNEL_ERROR_UNKNOWN",
+		1:  "TUNNEL_ERROR_INTERNAL",
+		2:  "TUNNEL_ERROR_NOT_FOUND",
+		3:  "TUNNEL_ERROR_FORBIDDEN",
+		4:  "TUNNEL_ERROR_UNAUTHORIZED",
+		5:  "TUNNEL_ERROR_INVALID",
+		6:  "TUNNEL_ERROR_CONFLICT",
+		7:  "TUNNEL_ERROR_TIMEOUT",
+		8:  "TUNNEL_ERROR_UNAVAILABLE",
+		9:  "TUNNEL_ERROR_CANCELLED",
+		10: "TUNNEL_ERROR_UNSUPPORTED",
+		11: "TUNNEL_ERROR_TOO_MANY_REQUESTS",
+	}
+	TunnelErrorCode_value = map[string]int32{
+		"TUNNEL_ERROR_UNKNOWN":           0,
+		"TUNNEL_ERROR_INTERNAL":          1,
+		"TUNNEL_ERROR_NOT_FOUND":         2,
+		"TUNNEL_ERROR_FORBIDDEN":         3,
+		"TUNNEL_ERROR_UNAUTHORIZED":      4,
+		"TUNNEL_ERROR_INVALID":           5,
+		"TUNNEL_ERROR_CONFLICT":          6,
+		"TUNNEL_ERROR_TIMEOUT":           7,
+		"TUNNEL_ERROR_UNAVAILABLE":       8,
+		"TUNNEL_ERROR_CANCELLED":         9,
+		"TUNNEL_ERROR_UNSUPPORTED":       10,
+		"TUNNEL_ERROR_TOO_MANY_REQUESTS": 11,
+	}
+)
+
+func (x TunnelErrorCode) Enum() *TunnelErrorCode {
+	p := new(TunnelErrorCode)
+	*p = x
+	return p
+}
+
+func (x TunnelErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TunnelErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_tunnel_proto_enumTypes[3].Descriptor()
+}
+
+func (TunnelErrorCode) Type() protoreflect.EnumType {
+	return &file_tunnel_proto_enumTypes[3]
+}
+
+func (x TunnelErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TunnelErrorCode.Descriptor instead.
+func (TunnelErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{3}
+}
+
+type TerminalMessageType int32
+
+const (
+	TerminalMessageType_TERMINAL_STDIN  TerminalMessageType = 0 // data is input for the session, what agents without terminal-control expect
+	TerminalMessageType_TERMINAL_INIT   TerminalMessageType = 1 // data is the JSON exec request
+	TerminalMessageType_TERMINAL_RESIZE TerminalMessageType = 2 // size is the new terminal size
+	TerminalMessageType_TERMINAL_SIGNAL TerminalMessageType = 3 // signal is e.g. SIGINT, delivered through the TTY
+	TerminalMessageType_TERMINAL_CLOSE  TerminalMessageType = 4 // the user left, the agent ends the session and cleans up after it
+)
+
+// Enum value maps for TerminalMessageType.
+var (
+	TerminalMessageType_name = map[int32]string{
+		0: "TERMINAL_STDIN",
+		1: "TERMINAL_INIT",
+		2: "TERMINAL_RESIZE",
+		3: "TERMINAL_SIGNAL",
+		4: "TERMINAL_CLOSE",
+	}
+	TerminalMessageType_value = map[string]int32{
+		"TERMINAL_STDIN":  0,
+		"TERMINAL_INIT":   1,
+		"TERMINAL_RESIZE": 2,
+		"TERMINAL_SIGNAL": 3,
+		"TERMINAL_CLOSE":  4,
+	}
+)
+
+func (x TerminalMessageType) Enum() *TerminalMessageType {
+	p := new(TerminalMessageType)
+	*p = x
+	return p
+}
+
+func (x TerminalMessageType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TerminalMessageType) Descriptor() protoreflect.EnumDescriptor {
+	return file_tunnel_proto_enumTypes[4].Descriptor()
+}
+
+func (TerminalMessageType) Type() protoreflect.EnumType {
+	return &file_tunnel_proto_enumTypes[4]
+}
+
+func (x TerminalMessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TerminalMessageType.Descriptor instead.
+func (TerminalMessageType) EnumDescriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{4}
 }
 
 // Message sent by the agent
@@ -83,7 +321,6 @@ type AgentMessage struct {
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Message:
-	//
 	//	*AgentMessage_Registration
 	//	*AgentMessage_Status
 	//	*AgentMessage_Proxy
@@ -92,6 +329,10 @@ type AgentMessage struct {
 	//	*AgentMessage_HelmInstall
 	//	*AgentMessage_TerminalStream
 	//	*AgentMessage_PgwebResponse
+	//	*AgentMessage_CertificateRenewal
+	//	*AgentMessage_UpgradeStatus
+	//	*AgentMessage_Credit
+	//	*AgentMessage_PortForward
 	Message isAgentMessage_Message `protobuf_oneof:"message"`
 }
 
@@ -190,6 +431,34 @@ func (x *AgentMessage) GetPgwebResponse() *PgWebResponse {
 	return nil
 }
 
+func (x *AgentMessage) GetCertificateRenewal() *CertificateRenewalRequest {
+	if x, ok := x.GetMessage().(*AgentMessage_CertificateRenewal); ok {
+		return x.CertificateRenewal
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetUpgradeStatus() *AgentUpgradeStatus {
+	if x, ok := x.GetMessage().(*AgentMessage_UpgradeStatus); ok {
+		return x.UpgradeStatus
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetCredit() *FlowCredit {
+	if x, ok := x.GetMessage().(*AgentMessage_Credit); ok {
+		return x.Credit
+	}
+	return nil
+}
+
+func (x *AgentMessage) GetPortForward() *PortForward {
+	if x, ok := x.GetMessage().(*AgentMessage_PortForward); ok {
+		return x.PortForward
+	}
+	return nil
+}
+
 type isAgentMessage_Message interface {
 	isAgentMessage_Message()
 }
@@ -226,6 +495,22 @@ type AgentMessage_PgwebResponse struct {
 	PgwebResponse *PgWebResponse `protobuf:"bytes,8,opt,name=pgwebResponse,proto3,oneof"`
 }
 
+type AgentMessage_CertificateRenewal struct {
+	CertificateRenewal *CertificateRenewalRequest `protobuf:"bytes,9,opt,name=certificateRenewal,proto3,oneof"` // CSR for a new client certificate
+}
+
+type AgentMessage_UpgradeStatus struct {
+	UpgradeStatus *AgentUpgradeStatus `protobuf:"bytes,10,opt,name=upgradeStatus,proto3,oneof"` // Progress of a self-upgrade
+}
+
+type AgentMessage_Credit struct {
+	Credit *FlowCredit `protobuf:"bytes,11,opt,name=credit,proto3,oneof"` // More request body chunks the server may send on a stream
+}
+
+type AgentMessage_PortForward struct {
+	PortForward *PortForward `protobuf:"bytes,12,opt,name=portForward,proto3,oneof"` // Bytes read from a port-forward target
+}
+
 func (*AgentMessage_Registration) isAgentMessage_Message() {}
 
 func (*AgentMessage_Status) isAgentMessage_Message() {}
@@ -242,6 +527,14 @@ func (*AgentMessage_TerminalStream) isAgentMessage_Message() {}
 
 func (*AgentMessage_PgwebResponse) isAgentMessage_Message() {}
 
+func (*AgentMessage_CertificateRenewal) isAgentMessage_Message() {}
+
+func (*AgentMessage_UpgradeStatus) isAgentMessage_Message() {}
+
+func (*AgentMessage_Credit) isAgentMessage_Message() {}
+
+func (*AgentMessage_PortForward) isAgentMessage_Message() {}
+
 // Message sent by the server
 type ServerMessage struct {
 	state         protoimpl.MessageState
@@ -249,7 +542,6 @@ type ServerMessage struct {
 	unknownFields protoimpl.UnknownFields
 
 	// Types that are assignable to Message:
-	//
 	//	*ServerMessage_Registration
 	//	*ServerMessage_Status
 	//	*ServerMessage_Proxy
@@ -259,7 +551,16 @@ type ServerMessage struct {
 	//	*ServerMessage_HelmInstallRequest
 	//	*ServerMessage_TerminalStream
 	//	*ServerMessage_DbuiRequest
+	//	*ServerMessage_CertificateRenewal
+	//	*ServerMessage_Upgrade
+	//	*ServerMessage_Drain
+	//	*ServerMessage_Credit
+	//	*ServerMessage_RequestBody
+	//	*ServerMessage_PortForward
 	Message isServerMessage_Message `protobuf_oneof:"message"`
+	// W3C trace context (traceparent, tracestate) of the request that caused
+	// the message, the agent's spans join the server's trace
+	TraceContext map[string]string `protobuf:"bytes,16,rep,name=trace_context,json=traceContext,proto3" json:"trace_context,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *ServerMessage) Reset() {
@@ -364,6 +665,55 @@ func (x *ServerMessage) GetDbuiRequest() *DbUiRequest {
 	return nil
 }
 
+func (x *ServerMessage) GetCertificateRenewal() *CertificateRenewalResponse {
+	if x, ok := x.GetMessage().(*ServerMessage_CertificateRenewal); ok {
+		return x.CertificateRenewal
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetUpgrade() *AgentUpgradeRequest {
+	if x, ok := x.GetMessage().(*ServerMessage_Upgrade); ok {
+		return x.Upgrade
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetDrain() *DrainNotice {
+	if x, ok := x.GetMessage().(*ServerMessage_Drain); ok {
+		return x.Drain
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetCredit() *FlowCredit {
+	if x, ok := x.GetMessage().(*ServerMessage_Credit); ok {
+		return x.Credit
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetRequestBody() *RequestBody {
+	if x, ok := x.GetMessage().(*ServerMessage_RequestBody); ok {
+		return x.RequestBody
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetPortForward() *PortForward {
+	if x, ok := x.GetMessage().(*ServerMessage_PortForward); ok {
+		return x.PortForward
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
+	}
+	return nil
+}
+
 type isServerMessage_Message interface {
 	isServerMessage_Message()
 }
@@ -404,6 +754,30 @@ type ServerMessage_DbuiRequest struct {
 	DbuiRequest *DbUiRequest `protobuf:"bytes,9,opt,name=dbuiRequest,proto3,oneof"`
 }
 
+type ServerMessage_CertificateRenewal struct {
+	CertificateRenewal *CertificateRenewalResponse `protobuf:"bytes,10,opt,name=certificateRenewal,proto3,oneof"` // Signed certificate for a renewal request
+}
+
+type ServerMessage_Upgrade struct {
+	Upgrade *AgentUpgradeRequest `protobuf:"bytes,11,opt,name=upgrade,proto3,oneof"` // Upgrade the agent's own Deployment
+}
+
+type ServerMessage_Drain struct {
+	Drain *DrainNotice `protobuf:"bytes,12,opt,name=drain,proto3,oneof"` // Server is shutting down, reconnect
+}
+
+type ServerMessage_Credit struct {
+	Credit *FlowCredit `protobuf:"bytes,13,opt,name=credit,proto3,oneof"` // More DATA chunks the agent may send on a stream
+}
+
+type ServerMessage_RequestBody struct {
+	RequestBody *RequestBody `protobuf:"bytes,14,opt,name=requestBody,proto3,oneof"` // Chunk of a streamed proxy request body
+}
+
+type ServerMessage_PortForward struct {
+	PortForward *PortForward `protobuf:"bytes,15,opt,name=portForward,proto3,oneof"` // Open, feed or close a TCP port-forward
+}
+
 func (*ServerMessage_Registration) isServerMessage_Message() {}
 
 func (*ServerMessage_Status) isServerMessage_Message() {}
@@ -422,14 +796,28 @@ func (*ServerMessage_TerminalStream) isServerMessage_Message() {}
 
 func (*ServerMessage_DbuiRequest) isServerMessage_Message() {}
 
+func (*ServerMessage_CertificateRenewal) isServerMessage_Message() {}
+
+func (*ServerMessage_Upgrade) isServerMessage_Message() {}
+
+func (*ServerMessage_Drain) isServerMessage_Message() {}
+
+func (*ServerMessage_Credit) isServerMessage_Message() {}
+
+func (*ServerMessage_RequestBody) isServerMessage_Message() {}
+
+func (*ServerMessage_PortForward) isServerMessage_Message() {}
+
 // Agent registration request
 type RegistrationRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	AgentName    string `protobuf:"bytes,1,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
-	AgentVersion string `protobuf:"bytes,2,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	AgentName    string   `protobuf:"bytes,1,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	AgentVersion string   `protobuf:"bytes,2,opt,name=agent_version,json=agentVersion,proto3" json:"agent_version,omitempty"`
+	Capabilities []string `protobuf:"bytes,3,rep,name=capabilities,proto3" json:"capabilities,omitempty"` // Features the agent supports, see capabilities.go
+	Compression  []string `protobuf:"bytes,4,rep,name=compression,proto3" json:"compression,omitempty"`   // Codecs the agent can compress bodies with, see compression.go
 }
 
 func (x *RegistrationRequest) Reset() {
@@ -478,14 +866,29 @@ func (x *RegistrationRequest) GetAgentVersion() string {
 	return ""
 }
 
+func (x *RegistrationRequest) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *RegistrationRequest) GetCompression() []string {
+	if x != nil {
+		return x.Compression
+	}
+	return nil
+}
+
 // Server registration response
 type RegistrationResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Success bool   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"` // Optional message on success or failure
+	Success     bool   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message     string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`         // Optional message on success or failure
+	Compression string `protobuf:"bytes,3,opt,name=compression,proto3" json:"compression,omitempty"` // Codec the agent may compress bodies with, empty for none
 }
 
 func (x *RegistrationResponse) Reset() {
@@ -534,23 +937,26 @@ func (x *RegistrationResponse) GetMessage() string {
 	return ""
 }
 
-// Status update (for both agent and server)
-type StatusUpdate struct {
+func (x *RegistrationResponse) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
+// Enrollment of a new agent, sent without a client certificate
+type EnrollRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	CpuUsage     float64 `protobuf:"fixed64,1,opt,name=cpu_usage,json=cpuUsage,proto3" json:"cpu_usage,omitempty"`
-	MemoryUsage  float64 `protobuf:"fixed64,2,opt,name=memory_usage,json=memoryUsage,proto3" json:"memory_usage,omitempty"`
-	HealthStatus string  `protobuf:"bytes,3,opt,name=health_status,json=healthStatus,proto3" json:"health_status,omitempty"`
-	Provider     string  `protobuf:"bytes,4,opt,name=provider,proto3" json:"provider,omitempty"`
-	K8SVersion   string  `protobuf:"bytes,5,opt,name=k8s_version,json=k8sVersion,proto3" json:"k8s_version,omitempty"`
-	PodCapacity  int32   `protobuf:"varint,6,opt,name=pod_capacity,json=podCapacity,proto3" json:"pod_capacity,omitempty"`
-	PodCount     int32   `protobuf:"varint,7,opt,name=pod_count,json=podCount,proto3" json:"pod_count,omitempty"`
+	AgentName string `protobuf:"bytes,1,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	JoinToken string `protobuf:"bytes,2,opt,name=join_token,json=joinToken,proto3" json:"join_token,omitempty"`
+	Csr       []byte `protobuf:"bytes,3,opt,name=csr,proto3" json:"csr,omitempty"` // PEM encoded certificate signing request
 }
 
-func (x *StatusUpdate) Reset() {
-	*x = StatusUpdate{}
+func (x *EnrollRequest) Reset() {
+	*x = EnrollRequest{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_tunnel_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -558,13 +964,13 @@ func (x *StatusUpdate) Reset() {
 	}
 }
 
-func (x *StatusUpdate) String() string {
+func (x *EnrollRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatusUpdate) ProtoMessage() {}
+func (*EnrollRequest) ProtoMessage() {}
 
-func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
+func (x *EnrollRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_tunnel_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -576,76 +982,43 @@ func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatusUpdate.ProtoReflect.Descriptor instead.
-func (*StatusUpdate) Descriptor() ([]byte, []int) {
+// Deprecated: Use EnrollRequest.ProtoReflect.Descriptor instead.
+func (*EnrollRequest) Descriptor() ([]byte, []int) {
 	return file_tunnel_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *StatusUpdate) GetCpuUsage() float64 {
-	if x != nil {
-		return x.CpuUsage
-	}
-	return 0
-}
-
-func (x *StatusUpdate) GetMemoryUsage() float64 {
-	if x != nil {
-		return x.MemoryUsage
-	}
-	return 0
-}
-
-func (x *StatusUpdate) GetHealthStatus() string {
-	if x != nil {
-		return x.HealthStatus
-	}
-	return ""
-}
-
-func (x *StatusUpdate) GetProvider() string {
+func (x *EnrollRequest) GetAgentName() string {
 	if x != nil {
-		return x.Provider
+		return x.AgentName
 	}
 	return ""
 }
 
-func (x *StatusUpdate) GetK8SVersion() string {
+func (x *EnrollRequest) GetJoinToken() string {
 	if x != nil {
-		return x.K8SVersion
+		return x.JoinToken
 	}
 	return ""
 }
 
-func (x *StatusUpdate) GetPodCapacity() int32 {
-	if x != nil {
-		return x.PodCapacity
-	}
-	return 0
-}
-
-func (x *StatusUpdate) GetPodCount() int32 {
+func (x *EnrollRequest) GetCsr() []byte {
 	if x != nil {
-		return x.PodCount
+		return x.Csr
 	}
-	return 0
+	return nil
 }
 
-// ProxyRequest sent by the server to the agent
-type ProxyRequest struct {
+type EnrollResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	StreamId  string            `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
-	Method    string            `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
-	Path      string            `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
-	Headers   map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	Body      []byte            `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
-	ProxyType string            `protobuf:"bytes,6,opt,name=proxy_type,json=proxyType,proto3" json:"proxy_type,omitempty"`
+	Certificate   []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`                          // PEM encoded signed certificate
+	CaCertificate []byte `protobuf:"bytes,2,opt,name=ca_certificate,json=caCertificate,proto3" json:"ca_certificate,omitempty"` // PEM encoded CA certificate
 }
 
-func (x *ProxyRequest) Reset() {
-	*x = ProxyRequest{}
+func (x *EnrollResponse) Reset() {
+	*x = EnrollResponse{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_tunnel_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -653,13 +1026,13 @@ func (x *ProxyRequest) Reset() {
 	}
 }
 
-func (x *ProxyRequest) String() string {
+func (x *EnrollResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ProxyRequest) ProtoMessage() {}
+func (*EnrollResponse) ProtoMessage() {}
 
-func (x *ProxyRequest) ProtoReflect() protoreflect.Message {
+func (x *EnrollResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_tunnel_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -671,14 +1044,583 @@ func (x *ProxyRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ProxyRequest.ProtoReflect.Descriptor instead.
-func (*ProxyRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use EnrollResponse.ProtoReflect.Descriptor instead.
+func (*EnrollResponse) Descriptor() ([]byte, []int) {
 	return file_tunnel_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *ProxyRequest) GetStreamId() string {
+func (x *EnrollResponse) GetCertificate() []byte {
 	if x != nil {
-		return x.StreamId
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *EnrollResponse) GetCaCertificate() []byte {
+	if x != nil {
+		return x.CaCertificate
+	}
+	return nil
+}
+
+// Sent by the agent when its client certificate is close to expiry
+type CertificateRenewalRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Csr []byte `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"` // PEM encoded certificate signing request
+}
+
+func (x *CertificateRenewalRequest) Reset() {
+	*x = CertificateRenewalRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CertificateRenewalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CertificateRenewalRequest) ProtoMessage() {}
+
+func (x *CertificateRenewalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CertificateRenewalRequest.ProtoReflect.Descriptor instead.
+func (*CertificateRenewalRequest) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CertificateRenewalRequest) GetCsr() []byte {
+	if x != nil {
+		return x.Csr
+	}
+	return nil
+}
+
+// Server response to a CertificateRenewalRequest
+type CertificateRenewalResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success       bool   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Certificate   []byte `protobuf:"bytes,3,opt,name=certificate,proto3" json:"certificate,omitempty"`                          // PEM encoded signed certificate
+	CaCertificate []byte `protobuf:"bytes,4,opt,name=ca_certificate,json=caCertificate,proto3" json:"ca_certificate,omitempty"` // PEM encoded CA certificate
+}
+
+func (x *CertificateRenewalResponse) Reset() {
+	*x = CertificateRenewalResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CertificateRenewalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CertificateRenewalResponse) ProtoMessage() {}
+
+func (x *CertificateRenewalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CertificateRenewalResponse.ProtoReflect.Descriptor instead.
+func (*CertificateRenewalResponse) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CertificateRenewalResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *CertificateRenewalResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CertificateRenewalResponse) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *CertificateRenewalResponse) GetCaCertificate() []byte {
+	if x != nil {
+		return x.CaCertificate
+	}
+	return nil
+}
+
+// Grants the agent credit for more DATA chunks on a proxy stream, sent as
+// the server consumes the ones it received
+type FlowCredit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StreamId string `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	Credits  int32  `protobuf:"varint,2,opt,name=credits,proto3" json:"credits,omitempty"`
+}
+
+func (x *FlowCredit) Reset() {
+	*x = FlowCredit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FlowCredit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FlowCredit) ProtoMessage() {}
+
+func (x *FlowCredit) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FlowCredit.ProtoReflect.Descriptor instead.
+func (*FlowCredit) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FlowCredit) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *FlowCredit) GetCredits() int32 {
+	if x != nil {
+		return x.Credits
+	}
+	return 0
+}
+
+// Sent before the server shuts down, the agent closes its stream and
+// reconnects, landing on another replica
+type DrainNotice struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reason           string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReconnectDelayMs int64  `protobuf:"varint,2,opt,name=reconnect_delay_ms,json=reconnectDelayMs,proto3" json:"reconnect_delay_ms,omitempty"` // Upper bound, the agent picks a jittered delay below it
+}
+
+func (x *DrainNotice) Reset() {
+	*x = DrainNotice{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DrainNotice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainNotice) ProtoMessage() {}
+
+func (x *DrainNotice) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainNotice.ProtoReflect.Descriptor instead.
+func (*DrainNotice) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DrainNotice) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *DrainNotice) GetReconnectDelayMs() int64 {
+	if x != nil {
+		return x.ReconnectDelayMs
+	}
+	return 0
+}
+
+// Asks the agent to roll its Deployment to a new image
+type AgentUpgradeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UpgradeId string `protobuf:"bytes,1,opt,name=upgrade_id,json=upgradeId,proto3" json:"upgrade_id,omitempty"`
+	Image     string `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	Version   string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"` // Version the upgraded agent will register with
+}
+
+func (x *AgentUpgradeRequest) Reset() {
+	*x = AgentUpgradeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentUpgradeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentUpgradeRequest) ProtoMessage() {}
+
+func (x *AgentUpgradeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentUpgradeRequest.ProtoReflect.Descriptor instead.
+func (*AgentUpgradeRequest) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AgentUpgradeRequest) GetUpgradeId() string {
+	if x != nil {
+		return x.UpgradeId
+	}
+	return ""
+}
+
+func (x *AgentUpgradeRequest) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *AgentUpgradeRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+// Rollout progress reported by the agent for an AgentUpgradeRequest
+type AgentUpgradeStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UpgradeId string       `protobuf:"bytes,1,opt,name=upgrade_id,json=upgradeId,proto3" json:"upgrade_id,omitempty"`
+	State     UpgradeState `protobuf:"varint,2,opt,name=state,proto3,enum=tunnel.UpgradeState" json:"state,omitempty"`
+	Message   string       `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *AgentUpgradeStatus) Reset() {
+	*x = AgentUpgradeStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentUpgradeStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentUpgradeStatus) ProtoMessage() {}
+
+func (x *AgentUpgradeStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentUpgradeStatus.ProtoReflect.Descriptor instead.
+func (*AgentUpgradeStatus) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AgentUpgradeStatus) GetUpgradeId() string {
+	if x != nil {
+		return x.UpgradeId
+	}
+	return ""
+}
+
+func (x *AgentUpgradeStatus) GetState() UpgradeState {
+	if x != nil {
+		return x.State
+	}
+	return UpgradeState_UPGRADE_UNKNOWN
+}
+
+func (x *AgentUpgradeStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Status update (for both agent and server)
+type StatusUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CpuUsage         float64 `protobuf:"fixed64,1,opt,name=cpu_usage,json=cpuUsage,proto3" json:"cpu_usage,omitempty"`
+	MemoryUsage      float64 `protobuf:"fixed64,2,opt,name=memory_usage,json=memoryUsage,proto3" json:"memory_usage,omitempty"`
+	HealthStatus     string  `protobuf:"bytes,3,opt,name=health_status,json=healthStatus,proto3" json:"health_status,omitempty"`
+	Provider         string  `protobuf:"bytes,4,opt,name=provider,proto3" json:"provider,omitempty"`
+	K8SVersion       string  `protobuf:"bytes,5,opt,name=k8s_version,json=k8sVersion,proto3" json:"k8s_version,omitempty"`
+	PodCapacity      int32   `protobuf:"varint,6,opt,name=pod_capacity,json=podCapacity,proto3" json:"pod_capacity,omitempty"`
+	PodCount         int32   `protobuf:"varint,7,opt,name=pod_count,json=podCount,proto3" json:"pod_count,omitempty"`
+	NodeCount        int32   `protobuf:"varint,8,opt,name=node_count,json=nodeCount,proto3" json:"node_count,omitempty"`
+	NotReadyNodes    int32   `protobuf:"varint,9,opt,name=not_ready_nodes,json=notReadyNodes,proto3" json:"not_ready_nodes,omitempty"`
+	PendingPods      int32   `protobuf:"varint,10,opt,name=pending_pods,json=pendingPods,proto3" json:"pending_pods,omitempty"`
+	CrashLoopingPods int32   `protobuf:"varint,11,opt,name=crash_looping_pods,json=crashLoopingPods,proto3" json:"crash_looping_pods,omitempty"`
+	FailingPvcs      int32   `protobuf:"varint,12,opt,name=failing_pvcs,json=failingPvcs,proto3" json:"failing_pvcs,omitempty"`
+	Reconnects       int64   `protobuf:"varint,13,opt,name=reconnects,proto3" json:"reconnects,omitempty"` // Times the agent re-established its stream since it started
+}
+
+func (x *StatusUpdate) Reset() {
+	*x = StatusUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StatusUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusUpdate) ProtoMessage() {}
+
+func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusUpdate.ProtoReflect.Descriptor instead.
+func (*StatusUpdate) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StatusUpdate) GetCpuUsage() float64 {
+	if x != nil {
+		return x.CpuUsage
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetMemoryUsage() float64 {
+	if x != nil {
+		return x.MemoryUsage
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetHealthStatus() string {
+	if x != nil {
+		return x.HealthStatus
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetK8SVersion() string {
+	if x != nil {
+		return x.K8SVersion
+	}
+	return ""
+}
+
+func (x *StatusUpdate) GetPodCapacity() int32 {
+	if x != nil {
+		return x.PodCapacity
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetPodCount() int32 {
+	if x != nil {
+		return x.PodCount
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetNodeCount() int32 {
+	if x != nil {
+		return x.NodeCount
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetNotReadyNodes() int32 {
+	if x != nil {
+		return x.NotReadyNodes
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetPendingPods() int32 {
+	if x != nil {
+		return x.PendingPods
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetCrashLoopingPods() int32 {
+	if x != nil {
+		return x.CrashLoopingPods
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetFailingPvcs() int32 {
+	if x != nil {
+		return x.FailingPvcs
+	}
+	return 0
+}
+
+func (x *StatusUpdate) GetReconnects() int64 {
+	if x != nil {
+		return x.Reconnects
+	}
+	return 0
+}
+
+// ProxyRequest sent by the server to the agent
+type ProxyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StreamId     string            `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	Method       string            `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	Path         string            `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Headers      map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Body         []byte            `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	ProxyType    string            `protobuf:"bytes,6,opt,name=proxy_type,json=proxyType,proto3" json:"proxy_type,omitempty"`
+	Window       int32             `protobuf:"varint,7,opt,name=window,proto3" json:"window,omitempty"`                                 // DATA chunks the agent may send before waiting for credit, 0 means unlimited
+	BodyStreamed bool              `protobuf:"varint,8,opt,name=body_streamed,json=bodyStreamed,proto3" json:"body_streamed,omitempty"` // body is empty, it follows in RequestBody frames under the same window
+	Upgrade      bool              `protobuf:"varint,9,opt,name=upgrade,proto3" json:"upgrade,omitempty"`                               // connection upgrade (SPDY, WebSocket): after a 101 HEADERS, DATA and RequestBody frames carry the raw streams
+}
+
+func (x *ProxyRequest) Reset() {
+	*x = ProxyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProxyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProxyRequest) ProtoMessage() {}
+
+func (x *ProxyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProxyRequest.ProtoReflect.Descriptor instead.
+func (*ProxyRequest) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ProxyRequest) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
 	}
 	return ""
 }
@@ -718,6 +1660,213 @@ func (x *ProxyRequest) GetProxyType() string {
 	return ""
 }
 
+func (x *ProxyRequest) GetWindow() int32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *ProxyRequest) GetBodyStreamed() bool {
+	if x != nil {
+		return x.BodyStreamed
+	}
+	return false
+}
+
+func (x *ProxyRequest) GetUpgrade() bool {
+	if x != nil {
+		return x.Upgrade
+	}
+	return false
+}
+
+// PortForward carries a TCP connection to a pod or service port in the
+// agent's cluster. DATA in both directions is flow controlled with FlowCredit
+// under the window of the OPEN message.
+type PortForward struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StreamId  string          `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	Type      PortForwardType `protobuf:"varint,2,opt,name=type,proto3,enum=tunnel.PortForwardType" json:"type,omitempty"`
+	Namespace string          `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"` // OPEN only
+	Kind      string          `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`           // OPEN only, "pod" or "service"
+	Name      string          `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`           // OPEN only
+	Port      int32           `protobuf:"varint,6,opt,name=port,proto3" json:"port,omitempty"`          // OPEN only
+	Window    int32           `protobuf:"varint,7,opt,name=window,proto3" json:"window,omitempty"`      // OPEN only
+	Data      []byte          `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"`
+	Error     string          `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *PortForward) Reset() {
+	*x = PortForward{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PortForward) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortForward) ProtoMessage() {}
+
+func (x *PortForward) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortForward.ProtoReflect.Descriptor instead.
+func (*PortForward) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PortForward) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *PortForward) GetType() PortForwardType {
+	if x != nil {
+		return x.Type
+	}
+	return PortForwardType_PORT_FORWARD_UNKNOWN
+}
+
+func (x *PortForward) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PortForward) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *PortForward) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PortForward) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *PortForward) GetWindow() int32 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *PortForward) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *PortForward) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// RequestBody carries a proxy request body in chunks, the last one has end set.
+type RequestBody struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	StreamId string `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	Data     []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	End      bool   `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	Error    string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"` // the client upload failed, the agent aborts the request
+}
+
+func (x *RequestBody) Reset() {
+	*x = RequestBody{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RequestBody) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RequestBody) ProtoMessage() {}
+
+func (x *RequestBody) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RequestBody.ProtoReflect.Descriptor instead.
+func (*RequestBody) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RequestBody) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *RequestBody) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *RequestBody) GetEnd() bool {
+	if x != nil {
+		return x.End
+	}
+	return false
+}
+
+func (x *RequestBody) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 // ProxyResponse sent by the agent to the server
 type ProxyResponse struct {
 	state         protoimpl.MessageState
@@ -729,12 +1878,14 @@ type ProxyResponse struct {
 	StatusCode int32             `protobuf:"varint,3,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
 	Headers    map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	Body       []byte            `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	Encoding   string            `protobuf:"bytes,6,opt,name=encoding,proto3" json:"encoding,omitempty"` // Codec the body is compressed with, empty when sent as is
+	Error      *TunnelError      `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`       // ERROR only, the body keeps the plain message for older servers
 }
 
 func (x *ProxyResponse) Reset() {
 	*x = ProxyResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[6]
+		mi := &file_tunnel_proto_msgTypes[16]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -747,7 +1898,7 @@ func (x *ProxyResponse) String() string {
 func (*ProxyResponse) ProtoMessage() {}
 
 func (x *ProxyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[6]
+	mi := &file_tunnel_proto_msgTypes[16]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -760,7 +1911,7 @@ func (x *ProxyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProxyResponse.ProtoReflect.Descriptor instead.
 func (*ProxyResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{6}
+	return file_tunnel_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *ProxyResponse) GetStreamId() string {
@@ -798,6 +1949,101 @@ func (x *ProxyResponse) GetBody() []byte {
 	return nil
 }
 
+func (x *ProxyResponse) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *ProxyResponse) GetError() *TunnelError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+// TunnelError says why an operation failed on the agent, so the server can
+// answer with the matching HTTP status.
+type TunnelError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code      TunnelErrorCode   `protobuf:"varint,1,opt,name=code,proto3,enum=tunnel.TunnelErrorCode" json:"code,omitempty"`
+	Reason    string            `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"` // Kubernetes StatusReason when the k8s API refused, e.g. Forbidden
+	Message   string            `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Retryable bool              `protobuf:"varint,4,opt,name=retryable,proto3" json:"retryable,omitempty"`
+	Details   map[string]string `protobuf:"bytes,5,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // e.g. kind and name of the missing object
+}
+
+func (x *TunnelError) Reset() {
+	*x = TunnelError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TunnelError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TunnelError) ProtoMessage() {}
+
+func (x *TunnelError) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TunnelError.ProtoReflect.Descriptor instead.
+func (*TunnelError) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TunnelError) GetCode() TunnelErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return TunnelErrorCode_TUNNEL_ERROR_UNKNOWN
+}
+
+func (x *TunnelError) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *TunnelError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *TunnelError) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
+func (x *TunnelError) GetDetails() map[string]string {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
 // ProjectsResponse sent by the agent to the server
 type ProjectsResponse struct {
 	state         protoimpl.MessageState
@@ -810,7 +2056,7 @@ type ProjectsResponse struct {
 func (x *ProjectsResponse) Reset() {
 	*x = ProjectsResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[7]
+		mi := &file_tunnel_proto_msgTypes[18]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -823,7 +2069,7 @@ func (x *ProjectsResponse) String() string {
 func (*ProjectsResponse) ProtoMessage() {}
 
 func (x *ProjectsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[7]
+	mi := &file_tunnel_proto_msgTypes[18]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -836,7 +2082,7 @@ func (x *ProjectsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProjectsResponse.ProtoReflect.Descriptor instead.
 func (*ProjectsResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{7}
+	return file_tunnel_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ProjectsResponse) GetProjects() []*Project {
@@ -857,7 +2103,7 @@ type ProjectsRequest struct {
 func (x *ProjectsRequest) Reset() {
 	*x = ProjectsRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[8]
+		mi := &file_tunnel_proto_msgTypes[19]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -870,7 +2116,7 @@ func (x *ProjectsRequest) String() string {
 func (*ProjectsRequest) ProtoMessage() {}
 
 func (x *ProjectsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[8]
+	mi := &file_tunnel_proto_msgTypes[19]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -883,7 +2129,7 @@ func (x *ProjectsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProjectsRequest.ProtoReflect.Descriptor instead.
 func (*ProjectsRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{8}
+	return file_tunnel_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *ProjectsRequest) GetStreamId() string {
@@ -906,7 +2152,7 @@ type HelmValuesRequest struct {
 func (x *HelmValuesRequest) Reset() {
 	*x = HelmValuesRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[9]
+		mi := &file_tunnel_proto_msgTypes[20]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -919,7 +2165,7 @@ func (x *HelmValuesRequest) String() string {
 func (*HelmValuesRequest) ProtoMessage() {}
 
 func (x *HelmValuesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[9]
+	mi := &file_tunnel_proto_msgTypes[20]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -932,7 +2178,7 @@ func (x *HelmValuesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmValuesRequest.ProtoReflect.Descriptor instead.
 func (*HelmValuesRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{9}
+	return file_tunnel_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *HelmValuesRequest) GetStreamId() string {
@@ -969,7 +2215,7 @@ type HelmDeleteRequest struct {
 func (x *HelmDeleteRequest) Reset() {
 	*x = HelmDeleteRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[10]
+		mi := &file_tunnel_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -982,7 +2228,7 @@ func (x *HelmDeleteRequest) String() string {
 func (*HelmDeleteRequest) ProtoMessage() {}
 
 func (x *HelmDeleteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[10]
+	mi := &file_tunnel_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -995,7 +2241,7 @@ func (x *HelmDeleteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmDeleteRequest.ProtoReflect.Descriptor instead.
 func (*HelmDeleteRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{10}
+	return file_tunnel_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *HelmDeleteRequest) GetStreamId() string {
@@ -1050,7 +2296,7 @@ type HelmInstallRequest struct {
 func (x *HelmInstallRequest) Reset() {
 	*x = HelmInstallRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[11]
+		mi := &file_tunnel_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1063,7 +2309,7 @@ func (x *HelmInstallRequest) String() string {
 func (*HelmInstallRequest) ProtoMessage() {}
 
 func (x *HelmInstallRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[11]
+	mi := &file_tunnel_proto_msgTypes[22]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1076,7 +2322,7 @@ func (x *HelmInstallRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmInstallRequest.ProtoReflect.Descriptor instead.
 func (*HelmInstallRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{11}
+	return file_tunnel_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *HelmInstallRequest) GetStreamId() string {
@@ -1167,7 +2413,7 @@ type HelmDeleteResponse struct {
 func (x *HelmDeleteResponse) Reset() {
 	*x = HelmDeleteResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[12]
+		mi := &file_tunnel_proto_msgTypes[23]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1180,7 +2426,7 @@ func (x *HelmDeleteResponse) String() string {
 func (*HelmDeleteResponse) ProtoMessage() {}
 
 func (x *HelmDeleteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[12]
+	mi := &file_tunnel_proto_msgTypes[23]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1193,7 +2439,7 @@ func (x *HelmDeleteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmDeleteResponse.ProtoReflect.Descriptor instead.
 func (*HelmDeleteResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{12}
+	return file_tunnel_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *HelmDeleteResponse) GetStreamId() string {
@@ -1214,7 +2460,7 @@ type HelmValuesResponse struct {
 func (x *HelmValuesResponse) Reset() {
 	*x = HelmValuesResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[13]
+		mi := &file_tunnel_proto_msgTypes[24]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1227,7 +2473,7 @@ func (x *HelmValuesResponse) String() string {
 func (*HelmValuesResponse) ProtoMessage() {}
 
 func (x *HelmValuesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[13]
+	mi := &file_tunnel_proto_msgTypes[24]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1240,7 +2486,7 @@ func (x *HelmValuesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmValuesResponse.ProtoReflect.Descriptor instead.
 func (*HelmValuesResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{13}
+	return file_tunnel_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *HelmValuesResponse) GetValues() []string {
@@ -1261,7 +2507,7 @@ type HelmInstallResponse struct {
 func (x *HelmInstallResponse) Reset() {
 	*x = HelmInstallResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[14]
+		mi := &file_tunnel_proto_msgTypes[25]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1274,7 +2520,7 @@ func (x *HelmInstallResponse) String() string {
 func (*HelmInstallResponse) ProtoMessage() {}
 
 func (x *HelmInstallResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[14]
+	mi := &file_tunnel_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1287,7 +2533,7 @@ func (x *HelmInstallResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelmInstallResponse.ProtoReflect.Descriptor instead.
 func (*HelmInstallResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{14}
+	return file_tunnel_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *HelmInstallResponse) GetStreamId() string {
@@ -1312,7 +2558,7 @@ type Project struct {
 func (x *Project) Reset() {
 	*x = Project{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[15]
+		mi := &file_tunnel_proto_msgTypes[26]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1325,7 +2571,7 @@ func (x *Project) String() string {
 func (*Project) ProtoMessage() {}
 
 func (x *Project) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[15]
+	mi := &file_tunnel_proto_msgTypes[26]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1338,7 +2584,7 @@ func (x *Project) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Project.ProtoReflect.Descriptor instead.
 func (*Project) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{15}
+	return file_tunnel_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *Project) GetName() string {
@@ -1381,14 +2627,18 @@ type TerminalStream struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Data      []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
-	SessionId string `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"` // optional
+	Data      []byte              `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	SessionId string              `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`       // optional
+	Error     *TunnelError        `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`                                // agent only, the session failed and is over
+	Type      TerminalMessageType `protobuf:"varint,4,opt,name=type,proto3,enum=tunnel.TerminalMessageType" json:"type,omitempty"` // server only
+	Size      *TerminalSize       `protobuf:"bytes,5,opt,name=size,proto3" json:"size,omitempty"`                                  // RESIZE only
+	Signal    string              `protobuf:"bytes,6,opt,name=signal,proto3" json:"signal,omitempty"`                              // SIGNAL only
 }
 
 func (x *TerminalStream) Reset() {
 	*x = TerminalStream{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[16]
+		mi := &file_tunnel_proto_msgTypes[27]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1401,7 +2651,7 @@ func (x *TerminalStream) String() string {
 func (*TerminalStream) ProtoMessage() {}
 
 func (x *TerminalStream) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[16]
+	mi := &file_tunnel_proto_msgTypes[27]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1414,7 +2664,7 @@ func (x *TerminalStream) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TerminalStream.ProtoReflect.Descriptor instead.
 func (*TerminalStream) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{16}
+	return file_tunnel_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *TerminalStream) GetData() []byte {
@@ -1431,6 +2681,89 @@ func (x *TerminalStream) GetSessionId() string {
 	return ""
 }
 
+func (x *TerminalStream) GetError() *TunnelError {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *TerminalStream) GetType() TerminalMessageType {
+	if x != nil {
+		return x.Type
+	}
+	return TerminalMessageType_TERMINAL_STDIN
+}
+
+func (x *TerminalStream) GetSize() *TerminalSize {
+	if x != nil {
+		return x.Size
+	}
+	return nil
+}
+
+func (x *TerminalStream) GetSignal() string {
+	if x != nil {
+		return x.Signal
+	}
+	return ""
+}
+
+type TerminalSize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cols uint32 `protobuf:"varint,1,opt,name=cols,proto3" json:"cols,omitempty"`
+	Rows uint32 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+}
+
+func (x *TerminalSize) Reset() {
+	*x = TerminalSize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_tunnel_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TerminalSize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TerminalSize) ProtoMessage() {}
+
+func (x *TerminalSize) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TerminalSize.ProtoReflect.Descriptor instead.
+func (*TerminalSize) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TerminalSize) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *TerminalSize) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
 // Message to launch pgweb
 type DbUiRequest struct {
 	state         protoimpl.MessageState
@@ -1447,7 +2780,7 @@ type DbUiRequest struct {
 func (x *DbUiRequest) Reset() {
 	*x = DbUiRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[17]
+		mi := &file_tunnel_proto_msgTypes[29]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1460,7 +2793,7 @@ func (x *DbUiRequest) String() string {
 func (*DbUiRequest) ProtoMessage() {}
 
 func (x *DbUiRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[17]
+	mi := &file_tunnel_proto_msgTypes[29]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1473,7 +2806,7 @@ func (x *DbUiRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DbUiRequest.ProtoReflect.Descriptor instead.
 func (*DbUiRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{17}
+	return file_tunnel_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *DbUiRequest) GetStreamId() string {
@@ -1526,7 +2859,7 @@ type PgWebResponse struct {
 func (x *PgWebResponse) Reset() {
 	*x = PgWebResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[18]
+		mi := &file_tunnel_proto_msgTypes[30]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1539,7 +2872,7 @@ func (x *PgWebResponse) String() string {
 func (*PgWebResponse) ProtoMessage() {}
 
 func (x *PgWebResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[18]
+	mi := &file_tunnel_proto_msgTypes[30]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1552,7 +2885,7 @@ func (x *PgWebResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PgWebResponse.ProtoReflect.Descriptor instead.
 func (*PgWebResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{18}
+	return file_tunnel_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *PgWebResponse) GetSuccess() bool {
@@ -1603,7 +2936,7 @@ type StopPgWebRequest struct {
 func (x *StopPgWebRequest) Reset() {
 	*x = StopPgWebRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[19]
+		mi := &file_tunnel_proto_msgTypes[31]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1616,7 +2949,7 @@ func (x *StopPgWebRequest) String() string {
 func (*StopPgWebRequest) ProtoMessage() {}
 
 func (x *StopPgWebRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[19]
+	mi := &file_tunnel_proto_msgTypes[31]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1629,7 +2962,7 @@ func (x *StopPgWebRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StopPgWebRequest.ProtoReflect.Descriptor instead.
 func (*StopPgWebRequest) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{19}
+	return file_tunnel_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *StopPgWebRequest) GetDbName() string {
@@ -1658,7 +2991,7 @@ type StopPgWebResponse struct {
 func (x *StopPgWebResponse) Reset() {
 	*x = StopPgWebResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_tunnel_proto_msgTypes[20]
+		mi := &file_tunnel_proto_msgTypes[32]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1671,7 +3004,7 @@ func (x *StopPgWebResponse) String() string {
 func (*StopPgWebResponse) ProtoMessage() {}
 
 func (x *StopPgWebResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[20]
+	mi := &file_tunnel_proto_msgTypes[32]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1684,7 +3017,7 @@ func (x *StopPgWebResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StopPgWebResponse.ProtoReflect.Descriptor instead.
 func (*StopPgWebResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{20}
+	return file_tunnel_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *StopPgWebResponse) GetSuccess() bool {
@@ -1705,7 +3038,7 @@ var File_tunnel_proto protoreflect.FileDescriptor
 
 var file_tunnel_proto_rawDesc = []byte{
 	0x0a, 0x0c, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
-	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x22, 0xf9, 0x03, 0x0a, 0x0c, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x22, 0xf9, 0x05, 0x0a, 0x0c, 0x41, 0x67, 0x65, 0x6e, 0x74,
 	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x41, 0x0a, 0x0c, 0x72, 0x65, 0x67, 0x69, 0x73,
 	0x74, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e,
 	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61, 0x74,
@@ -1736,8 +3069,24 @@ var file_tunnel_proto_rawDesc = []byte{
 	0x62, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32,
 	0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x67, 0x57, 0x65, 0x62, 0x52, 0x65,
 	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x70, 0x67, 0x77, 0x65, 0x62, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x22, 0xd2, 0x04, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x53, 0x0a, 0x12, 0x63, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x65, 0x72,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x12, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x12, 0x42, 0x0a, 0x0d,
+	0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65,
+	0x6e, 0x74, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48,
+	0x00, 0x52, 0x0d, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x2c, 0x0a, 0x06, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x46, 0x6c, 0x6f, 0x77, 0x43, 0x72,
+	0x65, 0x64, 0x69, 0x74, 0x48, 0x00, 0x52, 0x06, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x12, 0x37,
+	0x0a, 0x0b, 0x70, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x18, 0x0c, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x6f, 0x72,
+	0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x48, 0x00, 0x52, 0x0b, 0x70, 0x6f, 0x72, 0x74,
+	0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x22, 0xbd, 0x08, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73,
 	0x73, 0x61, 0x67, 0x65, 0x12, 0x42, 0x0a, 0x0c, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61,
 	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x74, 0x75, 0x6e,
 	0x6e, 0x65, 0x6c, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
@@ -1773,174 +3122,384 @@ var file_tunnel_proto_rawDesc = []byte{
 	0x65, 0x61, 0x6d, 0x12, 0x37, 0x0a, 0x0b, 0x64, 0x62, 0x75, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65,
 	0x73, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
 	0x6c, 0x2e, 0x44, 0x62, 0x55, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
-	0x0b, 0x64, 0x62, 0x75, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x09, 0x0a, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x59, 0x0a, 0x13, 0x52, 0x65, 0x67, 0x69, 0x73,
-	0x74, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d,
-	0x0a, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x09, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x23, 0x0a,
-	0x0d, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x22, 0x4a, 0x0a, 0x14, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63,
-	0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xf0,
-	0x01, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12,
-	0x1b, 0x0a, 0x09, 0x63, 0x70, 0x75, 0x5f, 0x75, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x01, 0x52, 0x08, 0x63, 0x70, 0x75, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c,
-	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x75, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x01, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12,
-	0x23, 0x0a, 0x0d, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72,
-	0x12, 0x1f, 0x0a, 0x0b, 0x6b, 0x38, 0x73, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
-	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6b, 0x38, 0x73, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x6f, 0x64, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74,
-	0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x70, 0x6f, 0x64, 0x43, 0x61, 0x70, 0x61,
-	0x63, 0x69, 0x74, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x6f, 0x64, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
-	0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x6f, 0x64, 0x43, 0x6f, 0x75, 0x6e,
-	0x74, 0x22, 0x83, 0x02, 0x0a, 0x0c, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12,
-	0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x3b, 0x0a, 0x07, 0x68,
-	0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74,
-	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
-	0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x1d, 0x0a, 0x0a,
-	0x70, 0x72, 0x6f, 0x78, 0x79, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x09, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x54, 0x79, 0x70, 0x65, 0x1a, 0x3a, 0x0a, 0x0c, 0x48,
-	0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x0b, 0x64, 0x62, 0x75, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x54, 0x0a, 0x12,
+	0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e, 0x65, 0x77,
+	0x61, 0x6c, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e,
+	0x65, 0x77, 0x61, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x12,
+	0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e, 0x65, 0x77,
+	0x61, 0x6c, 0x12, 0x37, 0x0a, 0x07, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x18, 0x0b, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65,
+	0x6e, 0x74, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x00, 0x52, 0x07, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x12, 0x2b, 0x0a, 0x05, 0x64,
+	0x72, 0x61, 0x69, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x2e, 0x44, 0x72, 0x61, 0x69, 0x6e, 0x4e, 0x6f, 0x74, 0x69, 0x63, 0x65, 0x48,
+	0x00, 0x52, 0x05, 0x64, 0x72, 0x61, 0x69, 0x6e, 0x12, 0x2c, 0x0a, 0x06, 0x63, 0x72, 0x65, 0x64,
+	0x69, 0x74, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x46, 0x6c, 0x6f, 0x77, 0x43, 0x72, 0x65, 0x64, 0x69, 0x74, 0x48, 0x00, 0x52, 0x06,
+	0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x12, 0x37, 0x0a, 0x0b, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x79,
+	0x48, 0x00, 0x52, 0x0b, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x79, 0x12,
+	0x37, 0x0a, 0x0b, 0x70, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x18, 0x0f,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x6f,
+	0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x48, 0x00, 0x52, 0x0b, 0x70, 0x6f, 0x72,
+	0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12, 0x4c, 0x0a, 0x0d, 0x74, 0x72, 0x61, 0x63,
+	0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x10, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x27, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74,
+	0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0c, 0x74, 0x72, 0x61, 0x63, 0x65, 0x43,
+	0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x1a, 0x3f, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43,
+	0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
 	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
 	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x8e, 0x02, 0x0a, 0x0d, 0x50, 0x72, 0x6f, 0x78,
-	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74,
-	0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x31, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e,
-	0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70,
-	0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61,
-	0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a,
-	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x3c, 0x0a, 0x07, 0x68, 0x65,
-	0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x74, 0x75,
-	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
-	0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x1a, 0x3a, 0x0a, 0x0c,
-	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
-	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x3f, 0x0a, 0x10, 0x50, 0x72, 0x6f, 0x6a,
-	0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x08,
-	0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f,
-	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x52,
-	0x08, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x22, 0x2e, 0x0a, 0x0f, 0x50, 0x72, 0x6f,
-	0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09,
-	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22, 0x68, 0x0a, 0x11, 0x48, 0x65, 0x6c,
-	0x6d, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b,
-	0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x72,
-	0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65,
-	0x6c, 0x65, 0x61, 0x73, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
-	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
-	0x61, 0x63, 0x65, 0x22, 0x68, 0x0a, 0x11, 0x48, 0x65, 0x6c, 0x6d, 0x44, 0x65, 0x6c, 0x65, 0x74,
-	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x12,
-	0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0xb8, 0x02,
-	0x0a, 0x12, 0x48, 0x65, 0x6c, 0x6d, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x22, 0x9f, 0x01, 0x0a, 0x13, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x67,
+	0x65, 0x6e, 0x74, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x61, 0x67, 0x65, 0x6e, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x67, 0x65,
+	0x6e, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x22,
+	0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69,
+	0x65, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x22, 0x6c, 0x0a, 0x14, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73,
+	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x22, 0x5f, 0x0a, 0x0d, 0x45, 0x6e, 0x72, 0x6f, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x4e, 0x61,
+	0x6d, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x6a, 0x6f, 0x69, 0x6e, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6a, 0x6f, 0x69, 0x6e, 0x54, 0x6f, 0x6b, 0x65,
+	0x6e, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x73, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
+	0x63, 0x73, 0x72, 0x22, 0x59, 0x0a, 0x0e, 0x45, 0x6e, 0x72, 0x6f, 0x6c, 0x6c, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x61, 0x5f, 0x63, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0d, 0x63, 0x61, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0x2d,
+	0x0a, 0x19, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e,
+	0x65, 0x77, 0x61, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63,
+	0x73, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x73, 0x72, 0x22, 0x99, 0x01,
+	0x0a, 0x1a, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x6e,
+	0x65, 0x77, 0x61, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73,
+	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x61, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x63, 0x61, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x22, 0x43, 0x0a, 0x0a, 0x46, 0x6c, 0x6f,
+	0x77, 0x43, 0x72, 0x65, 0x64, 0x69, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x63, 0x72, 0x65, 0x64, 0x69, 0x74, 0x73, 0x22, 0x53,
+	0x0a, 0x0b, 0x44, 0x72, 0x61, 0x69, 0x6e, 0x4e, 0x6f, 0x74, 0x69, 0x63, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72,
+	0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x65, 0x63, 0x6f, 0x6e, 0x6e, 0x65,
+	0x63, 0x74, 0x5f, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x5f, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x10, 0x72, 0x65, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x44, 0x65, 0x6c, 0x61,
+	0x79, 0x4d, 0x73, 0x22, 0x64, 0x0a, 0x13, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x55, 0x70, 0x67, 0x72,
+	0x61, 0x64, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x75, 0x70,
+	0x67, 0x72, 0x61, 0x64, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x79, 0x0a, 0x12, 0x41, 0x67, 0x65,
+	0x6e, 0x74, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x1d, 0x0a, 0x0a, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x49, 0x64, 0x12, 0x2a,
+	0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x22, 0xcb, 0x03, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x70, 0x75, 0x5f, 0x75, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x63, 0x70, 0x75, 0x55, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x75, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79,
+	0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x68, 0x65,
+	0x61, 0x6c, 0x74, 0x68, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12, 0x1f, 0x0a, 0x0b, 0x6b, 0x38, 0x73, 0x5f, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6b, 0x38, 0x73,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x6f, 0x64, 0x5f, 0x63,
+	0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x70,
+	0x6f, 0x64, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x6f,
+	0x64, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70,
+	0x6f, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6e, 0x6f, 0x64, 0x65, 0x5f,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x6e, 0x6f, 0x64,
+	0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x6f, 0x74, 0x5f, 0x72, 0x65,
+	0x61, 0x64, 0x79, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x0d, 0x6e, 0x6f, 0x74, 0x52, 0x65, 0x61, 0x64, 0x79, 0x4e, 0x6f, 0x64, 0x65, 0x73, 0x12, 0x21,
+	0x0a, 0x0c, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x6f, 0x64, 0x73, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x70, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x50, 0x6f, 0x64,
+	0x73, 0x12, 0x2c, 0x0a, 0x12, 0x63, 0x72, 0x61, 0x73, 0x68, 0x5f, 0x6c, 0x6f, 0x6f, 0x70, 0x69,
+	0x6e, 0x67, 0x5f, 0x70, 0x6f, 0x64, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x63,
+	0x72, 0x61, 0x73, 0x68, 0x4c, 0x6f, 0x6f, 0x70, 0x69, 0x6e, 0x67, 0x50, 0x6f, 0x64, 0x73, 0x12,
+	0x21, 0x0a, 0x0c, 0x66, 0x61, 0x69, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x76, 0x63, 0x73, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x66, 0x61, 0x69, 0x6c, 0x69, 0x6e, 0x67, 0x50, 0x76,
+	0x63, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x73,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x72, 0x65, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63,
+	0x74, 0x73, 0x22, 0xda, 0x02, 0x0a, 0x0c, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x3b, 0x0a, 0x07,
+	0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64,
+	0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x1d, 0x0a,
+	0x0a, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x54, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x12, 0x23, 0x0a, 0x0d, 0x62, 0x6f, 0x64, 0x79, 0x5f, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x62, 0x6f, 0x64,
+	0x79, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x75, 0x70, 0x67,
+	0x72, 0x61, 0x64, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x75, 0x70, 0x67, 0x72,
+	0x61, 0x64, 0x65, 0x1a, 0x3a, 0x0a, 0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0xf3, 0x01, 0x0a, 0x0b, 0x50, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x12,
+	0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x2b, 0x0a, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x74, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d,
+	0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61,
+	0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70,
+	0x6f, 0x72, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x12, 0x0a, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x66, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x42, 0x6f, 0x64, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69,
 	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49,
-	0x64, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x63, 0x68, 0x61, 0x72, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61,
-	0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73,
-	0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12,
-	0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52,
-	0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x18,
-	0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12, 0x18, 0x0a, 0x07, 0x72,
-	0x65, 0x70, 0x6f, 0x55, 0x72, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65,
-	0x70, 0x6f, 0x55, 0x72, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
-	0x12, 0x0a, 0x04, 0x77, 0x61, 0x69, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x77,
-	0x61, 0x69, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x0a,
-	0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x29, 0x0a,
-	0x10, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
-	0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x4e,
-	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0x31, 0x0a, 0x12, 0x48, 0x65, 0x6c, 0x6d,
-	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0xd5, 0x02,
+	0x0a, 0x0d, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x31, 0x0a, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x43, 0x6f, 0x64, 0x65,
+	0x12, 0x3c, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x22, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f,
+	0x64, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x29,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x1a, 0x3a, 0x0a, 0x0c, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x82, 0x02, 0x0a, 0x0b, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
+	0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x2b, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62, 0x6c,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x72, 0x65, 0x74, 0x72, 0x79, 0x61, 0x62,
+	0x6c, 0x65, 0x12, 0x3a, 0x0a, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x18, 0x05, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x1a, 0x3a,
+	0x0a, 0x0c, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x3f, 0x0a, 0x10, 0x50, 0x72,
+	0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b,
+	0x0a, 0x08, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0f, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63,
+	0x74, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x22, 0x2e, 0x0a, 0x0f, 0x50,
+	0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b,
 	0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22, 0x2c, 0x0a, 0x12, 0x48,
-	0x65, 0x6c, 0x6d, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
-	0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x32, 0x0a, 0x13, 0x48, 0x65, 0x6c,
-	0x6d, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22, 0x68, 0x0a, 0x11, 0x48,
+	0x65, 0x6c, 0x6d, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
 	0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22, 0x8c, 0x01,
-	0x0a, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a,
-	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61,
-	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
-	0x61, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a,
-	0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74, 0x22, 0x43, 0x0a, 0x0e,
-	0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x12,
-	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
-	0x74, 0x61, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49,
-	0x64, 0x22, 0xee, 0x01, 0x0a, 0x0b, 0x44, 0x62, 0x55, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x17,
-	0x0a, 0x07, 0x64, 0x62, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x64, 0x62, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x18, 0x0a,
+	0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
 	0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65,
-	0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x37, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18,
-	0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x44,
-	0x62, 0x55, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c,
-	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x17,
-	0x0a, 0x07, 0x64, 0x62, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x64, 0x62, 0x54, 0x79, 0x70, 0x65, 0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c,
-	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
-	0x38, 0x01, 0x22, 0x95, 0x01, 0x0a, 0x0d, 0x50, 0x67, 0x57, 0x65, 0x62, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18,
-	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x6f, 0x64, 0x5f,
-	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x6f, 0x64, 0x4e,
-	0x61, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x6e,
-	0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x76, 0x69,
-	0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x05,
-	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x22, 0x49, 0x0a, 0x10, 0x53, 0x74,
-	0x6f, 0x70, 0x50, 0x67, 0x57, 0x65, 0x62, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17,
-	0x0a, 0x07, 0x64, 0x62, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x64, 0x62, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
-	0x70, 0x61, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65,
-	0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0x47, 0x0a, 0x11, 0x53, 0x74, 0x6f, 0x70, 0x50, 0x67, 0x57,
-	0x65, 0x62, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63,
-	0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2a, 0x4b,
-	0x0a, 0x11, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x54,
-	0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00,
-	0x12, 0x0b, 0x0a, 0x07, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x53, 0x10, 0x01, 0x12, 0x08, 0x0a,
-	0x04, 0x44, 0x41, 0x54, 0x41, 0x10, 0x02, 0x12, 0x07, 0x0a, 0x03, 0x45, 0x4e, 0x44, 0x10, 0x03,
-	0x12, 0x09, 0x0a, 0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x04, 0x32, 0x4d, 0x0a, 0x0d, 0x54,
-	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3c, 0x0a, 0x07,
-	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
-	0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x15, 0x2e,
-	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0b, 0x5a, 0x09, 0x2e, 0x2f,
-	0x3b, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0x68, 0x0a, 0x11, 0x48, 0x65, 0x6c, 0x6d, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61,
+	0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73,
+	0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x22,
+	0xb8, 0x02, 0x0a, 0x12, 0x48, 0x65, 0x6c, 0x6d, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x63, 0x68, 0x61, 0x72, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6c,
+	0x65, 0x61, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6c, 0x65,
+	0x61, 0x73, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x70,
+	0x6f, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12, 0x18, 0x0a,
+	0x07, 0x72, 0x65, 0x70, 0x6f, 0x55, 0x72, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x72, 0x65, 0x70, 0x6f, 0x55, 0x72, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x12, 0x0a, 0x04, 0x77, 0x61, 0x69, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x04, 0x77, 0x61, 0x69, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x18, 0x0a, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12,
+	0x29, 0x0a, 0x10, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
+	0x61, 0x63, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x63, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0x31, 0x0a, 0x12, 0x48, 0x65,
+	0x6c, 0x6d, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22, 0x2c, 0x0a,
+	0x12, 0x48, 0x65, 0x6c, 0x6d, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x32, 0x0a, 0x13, 0x48,
+	0x65, 0x6c, 0x6d, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x22,
+	0x8c, 0x01, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x1d, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74, 0x22, 0xe1,
+	0x01, 0x0a, 0x0e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x49, 0x64, 0x12, 0x29, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12,
+	0x2f, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x12, 0x28, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c,
+	0x53, 0x69, 0x7a, 0x65, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x69, 0x67, 0x6e,
+	0x61, 0x6c, 0x22, 0x36, 0x0a, 0x0c, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69,
+	0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x04, 0x63, 0x6f, 0x6c, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x22, 0xee, 0x01, 0x0a, 0x0b, 0x44,
+	0x62, 0x55, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x64, 0x62, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x62, 0x4e, 0x61, 0x6d, 0x65,
+	0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x37,
+	0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f,
+	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x44, 0x62, 0x55, 0x69, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x17, 0x0a, 0x07, 0x64, 0x62, 0x5f, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x62, 0x54, 0x79, 0x70, 0x65,
+	0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x95, 0x01, 0x0a, 0x0d,
+	0x50, 0x67, 0x57, 0x65, 0x62, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x6f, 0x64, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x6f, 0x64, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70,
+	0x6f, 0x72, 0x74, 0x22, 0x49, 0x0a, 0x10, 0x53, 0x74, 0x6f, 0x70, 0x50, 0x67, 0x57, 0x65, 0x62,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x64, 0x62, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x62, 0x4e, 0x61, 0x6d, 0x65,
+	0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x22, 0x47,
+	0x0a, 0x11, 0x53, 0x74, 0x6f, 0x70, 0x50, 0x67, 0x57, 0x65, 0x62, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2a, 0x67, 0x0a, 0x0c, 0x55, 0x70, 0x67, 0x72, 0x61,
+	0x64, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x55, 0x50, 0x47, 0x52, 0x41,
+	0x44, 0x45, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x17, 0x0a, 0x13,
+	0x55, 0x50, 0x47, 0x52, 0x41, 0x44, 0x45, 0x5f, 0x49, 0x4e, 0x5f, 0x50, 0x52, 0x4f, 0x47, 0x52,
+	0x45, 0x53, 0x53, 0x10, 0x01, 0x12, 0x15, 0x0a, 0x11, 0x55, 0x50, 0x47, 0x52, 0x41, 0x44, 0x45,
+	0x5f, 0x53, 0x55, 0x43, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x02, 0x12, 0x12, 0x0a, 0x0e,
+	0x55, 0x50, 0x47, 0x52, 0x41, 0x44, 0x45, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x03,
+	0x2a, 0x89, 0x01, 0x0a, 0x0f, 0x50, 0x6f, 0x72, 0x74, 0x46, 0x6f, 0x72, 0x77, 0x61, 0x72, 0x64,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x14, 0x50, 0x4f, 0x52, 0x54, 0x5f, 0x46, 0x4f, 0x52,
+	0x57, 0x41, 0x52, 0x44, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x15,
+	0x0a, 0x11, 0x50, 0x4f, 0x52, 0x54, 0x5f, 0x46, 0x4f, 0x52, 0x57, 0x41, 0x52, 0x44, 0x5f, 0x4f,
+	0x50, 0x45, 0x4e, 0x10, 0x01, 0x12, 0x15, 0x0a, 0x11, 0x50, 0x4f, 0x52, 0x54, 0x5f, 0x46, 0x4f,
+	0x52, 0x57, 0x41, 0x52, 0x44, 0x5f, 0x44, 0x41, 0x54, 0x41, 0x10, 0x02, 0x12, 0x16, 0x0a, 0x12,
+	0x50, 0x4f, 0x52, 0x54, 0x5f, 0x46, 0x4f, 0x52, 0x57, 0x41, 0x52, 0x44, 0x5f, 0x43, 0x4c, 0x4f,
+	0x53, 0x45, 0x10, 0x03, 0x12, 0x16, 0x0a, 0x12, 0x50, 0x4f, 0x52, 0x54, 0x5f, 0x46, 0x4f, 0x52,
+	0x57, 0x41, 0x52, 0x44, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x04, 0x2a, 0x4b, 0x0a, 0x11,
+	0x50, 0x72, 0x6f, 0x78, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0b,
+	0x0a, 0x07, 0x48, 0x45, 0x41, 0x44, 0x45, 0x52, 0x53, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x44,
+	0x41, 0x54, 0x41, 0x10, 0x02, 0x12, 0x07, 0x0a, 0x03, 0x45, 0x4e, 0x44, 0x10, 0x03, 0x12, 0x09,
+	0x0a, 0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x04, 0x2a, 0xe8, 0x02, 0x0a, 0x0f, 0x54, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a,
+	0x14, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e,
+	0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x19, 0x0a, 0x15, 0x54, 0x55, 0x4e, 0x4e, 0x45,
+	0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c,
+	0x10, 0x01, 0x12, 0x1a, 0x0a, 0x16, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55, 0x4e, 0x44, 0x10, 0x02, 0x12, 0x1a,
+	0x0a, 0x16, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x46,
+	0x4f, 0x52, 0x42, 0x49, 0x44, 0x44, 0x45, 0x4e, 0x10, 0x03, 0x12, 0x1d, 0x0a, 0x19, 0x54, 0x55,
+	0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x41, 0x55, 0x54,
+	0x48, 0x4f, 0x52, 0x49, 0x5a, 0x45, 0x44, 0x10, 0x04, 0x12, 0x18, 0x0a, 0x14, 0x54, 0x55, 0x4e,
+	0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49,
+	0x44, 0x10, 0x05, 0x12, 0x19, 0x0a, 0x15, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52,
+	0x52, 0x4f, 0x52, 0x5f, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x10, 0x06, 0x12, 0x18,
+	0x0a, 0x14, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x54,
+	0x49, 0x4d, 0x45, 0x4f, 0x55, 0x54, 0x10, 0x07, 0x12, 0x1c, 0x0a, 0x18, 0x54, 0x55, 0x4e, 0x4e,
+	0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x41, 0x56, 0x41, 0x49, 0x4c,
+	0x41, 0x42, 0x4c, 0x45, 0x10, 0x08, 0x12, 0x1a, 0x0a, 0x16, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c,
+	0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x43, 0x41, 0x4e, 0x43, 0x45, 0x4c, 0x4c, 0x45, 0x44,
+	0x10, 0x09, 0x12, 0x1c, 0x0a, 0x18, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x55, 0x50, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x10, 0x0a,
+	0x12, 0x22, 0x0a, 0x1e, 0x54, 0x55, 0x4e, 0x4e, 0x45, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52,
+	0x5f, 0x54, 0x4f, 0x4f, 0x5f, 0x4d, 0x41, 0x4e, 0x59, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53,
+	0x54, 0x53, 0x10, 0x0b, 0x2a, 0x7a, 0x0a, 0x13, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x0e, 0x54,
+	0x45, 0x52, 0x4d, 0x49, 0x4e, 0x41, 0x4c, 0x5f, 0x53, 0x54, 0x44, 0x49, 0x4e, 0x10, 0x00, 0x12,
+	0x11, 0x0a, 0x0d, 0x54, 0x45, 0x52, 0x4d, 0x49, 0x4e, 0x41, 0x4c, 0x5f, 0x49, 0x4e, 0x49, 0x54,
+	0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x45, 0x52, 0x4d, 0x49, 0x4e, 0x41, 0x4c, 0x5f, 0x52,
+	0x45, 0x53, 0x49, 0x5a, 0x45, 0x10, 0x02, 0x12, 0x13, 0x0a, 0x0f, 0x54, 0x45, 0x52, 0x4d, 0x49,
+	0x4e, 0x41, 0x4c, 0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x4c, 0x10, 0x03, 0x12, 0x12, 0x0a, 0x0e,
+	0x54, 0x45, 0x52, 0x4d, 0x49, 0x4e, 0x41, 0x4c, 0x5f, 0x43, 0x4c, 0x4f, 0x53, 0x45, 0x10, 0x04,
+	0x32, 0x88, 0x01, 0x0a, 0x0d, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x12, 0x3c, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x14, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x1a, 0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01,
+	0x12, 0x39, 0x0a, 0x06, 0x45, 0x6e, 0x72, 0x6f, 0x6c, 0x6c, 0x12, 0x15, 0x2e, 0x74, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x2e, 0x45, 0x6e, 0x72, 0x6f, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x45, 0x6e, 0x72, 0x6f, 0x6c,
+	0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x0b, 0x5a, 0x09, 0x2e,
+	0x2f, 0x3b, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1955,65 +3514,104 @@ func file_tunnel_proto_rawDescGZIP() []byte {
 	return file_tunnel_proto_rawDescData
 }
 
-var file_tunnel_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_tunnel_proto_enumTypes = make([]protoimpl.EnumInfo, 5)
+var file_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 38)
 var file_tunnel_proto_goTypes = []any{
-	(ProxyResponseType)(0),       // 0: tunnel.ProxyResponseType
-	(*AgentMessage)(nil),         // 1: tunnel.AgentMessage
-	(*ServerMessage)(nil),        // 2: tunnel.ServerMessage
-	(*RegistrationRequest)(nil),  // 3: tunnel.RegistrationRequest
-	(*RegistrationResponse)(nil), // 4: tunnel.RegistrationResponse
-	(*StatusUpdate)(nil),         // 5: tunnel.StatusUpdate
-	(*ProxyRequest)(nil),         // 6: tunnel.ProxyRequest
-	(*ProxyResponse)(nil),        // 7: tunnel.ProxyResponse
-	(*ProjectsResponse)(nil),     // 8: tunnel.ProjectsResponse
-	(*ProjectsRequest)(nil),      // 9: tunnel.ProjectsRequest
-	(*HelmValuesRequest)(nil),    // 10: tunnel.HelmValuesRequest
-	(*HelmDeleteRequest)(nil),    // 11: tunnel.HelmDeleteRequest
-	(*HelmInstallRequest)(nil),   // 12: tunnel.HelmInstallRequest
-	(*HelmDeleteResponse)(nil),   // 13: tunnel.HelmDeleteResponse
-	(*HelmValuesResponse)(nil),   // 14: tunnel.HelmValuesResponse
-	(*HelmInstallResponse)(nil),  // 15: tunnel.HelmInstallResponse
-	(*Project)(nil),              // 16: tunnel.Project
-	(*TerminalStream)(nil),       // 17: tunnel.TerminalStream
-	(*DbUiRequest)(nil),          // 18: tunnel.DbUiRequest
-	(*PgWebResponse)(nil),        // 19: tunnel.PgWebResponse
-	(*StopPgWebRequest)(nil),     // 20: tunnel.StopPgWebRequest
-	(*StopPgWebResponse)(nil),    // 21: tunnel.StopPgWebResponse
-	nil,                          // 22: tunnel.ProxyRequest.HeadersEntry
-	nil,                          // 23: tunnel.ProxyResponse.HeadersEntry
-	nil,                          // 24: tunnel.DbUiRequest.LabelsEntry
+	(UpgradeState)(0),                  // 0: tunnel.UpgradeState
+	(PortForwardType)(0),               // 1: tunnel.PortForwardType
+	(ProxyResponseType)(0),             // 2: tunnel.ProxyResponseType
+	(TunnelErrorCode)(0),               // 3: tunnel.TunnelErrorCode
+	(TerminalMessageType)(0),           // 4: tunnel.TerminalMessageType
+	(*AgentMessage)(nil),               // 5: tunnel.AgentMessage
+	(*ServerMessage)(nil),              // 6: tunnel.ServerMessage
+	(*RegistrationRequest)(nil),        // 7: tunnel.RegistrationRequest
+	(*RegistrationResponse)(nil),       // 8: tunnel.RegistrationResponse
+	(*EnrollRequest)(nil),              // 9: tunnel.EnrollRequest
+	(*EnrollResponse)(nil),             // 10: tunnel.EnrollResponse
+	(*CertificateRenewalRequest)(nil),  // 11: tunnel.CertificateRenewalRequest
+	(*CertificateRenewalResponse)(nil), // 12: tunnel.CertificateRenewalResponse
+	(*FlowCredit)(nil),                 // 13: tunnel.FlowCredit
+	(*DrainNotice)(nil),                // 14: tunnel.DrainNotice
+	(*AgentUpgradeRequest)(nil),        // 15: tunnel.AgentUpgradeRequest
+	(*AgentUpgradeStatus)(nil),         // 16: tunnel.AgentUpgradeStatus
+	(*StatusUpdate)(nil),               // 17: tunnel.StatusUpdate
+	(*ProxyRequest)(nil),               // 18: tunnel.ProxyRequest
+	(*PortForward)(nil),                // 19: tunnel.PortForward
+	(*RequestBody)(nil),                // 20: tunnel.RequestBody
+	(*ProxyResponse)(nil),              // 21: tunnel.ProxyResponse
+	(*TunnelError)(nil),                // 22: tunnel.TunnelError
+	(*ProjectsResponse)(nil),           // 23: tunnel.ProjectsResponse
+	(*ProjectsRequest)(nil),            // 24: tunnel.ProjectsRequest
+	(*HelmValuesRequest)(nil),          // 25: tunnel.HelmValuesRequest
+	(*HelmDeleteRequest)(nil),          // 26: tunnel.HelmDeleteRequest
+	(*HelmInstallRequest)(nil),         // 27: tunnel.HelmInstallRequest
+	(*HelmDeleteResponse)(nil),         // 28: tunnel.HelmDeleteResponse
+	(*HelmValuesResponse)(nil),         // 29: tunnel.HelmValuesResponse
+	(*HelmInstallResponse)(nil),        // 30: tunnel.HelmInstallResponse
+	(*Project)(nil),                    // 31: tunnel.Project
+	(*TerminalStream)(nil),             // 32: tunnel.TerminalStream
+	(*TerminalSize)(nil),               // 33: tunnel.TerminalSize
+	(*DbUiRequest)(nil),                // 34: tunnel.DbUiRequest
+	(*PgWebResponse)(nil),              // 35: tunnel.PgWebResponse
+	(*StopPgWebRequest)(nil),           // 36: tunnel.StopPgWebRequest
+	(*StopPgWebResponse)(nil),          // 37: tunnel.StopPgWebResponse
+	nil,                                // 38: tunnel.ServerMessage.TraceContextEntry
+	nil,                                // 39: tunnel.ProxyRequest.HeadersEntry
+	nil,                                // 40: tunnel.ProxyResponse.HeadersEntry
+	nil,                                // 41: tunnel.TunnelError.DetailsEntry
+	nil,                                // 42: tunnel.DbUiRequest.LabelsEntry
 }
 var file_tunnel_proto_depIdxs = []int32{
-	3,  // 0: tunnel.AgentMessage.registration:type_name -> tunnel.RegistrationRequest
-	5,  // 1: tunnel.AgentMessage.status:type_name -> tunnel.StatusUpdate
-	7,  // 2: tunnel.AgentMessage.proxy:type_name -> tunnel.ProxyResponse
-	14, // 3: tunnel.AgentMessage.helmValues:type_name -> tunnel.HelmValuesResponse
-	13, // 4: tunnel.AgentMessage.helmDelete:type_name -> tunnel.HelmDeleteResponse
-	15, // 5: tunnel.AgentMessage.helmInstall:type_name -> tunnel.HelmInstallResponse
-	17, // 6: tunnel.AgentMessage.terminalStream:type_name -> tunnel.TerminalStream
-	19, // 7: tunnel.AgentMessage.pgwebResponse:type_name -> tunnel.PgWebResponse
-	4,  // 8: tunnel.ServerMessage.registration:type_name -> tunnel.RegistrationResponse
-	5,  // 9: tunnel.ServerMessage.status:type_name -> tunnel.StatusUpdate
-	6,  // 10: tunnel.ServerMessage.proxy:type_name -> tunnel.ProxyRequest
-	9,  // 11: tunnel.ServerMessage.projects:type_name -> tunnel.ProjectsRequest
-	10, // 12: tunnel.ServerMessage.helmValuesRequest:type_name -> tunnel.HelmValuesRequest
-	11, // 13: tunnel.ServerMessage.helmDeleteRequest:type_name -> tunnel.HelmDeleteRequest
-	12, // 14: tunnel.ServerMessage.helmInstallRequest:type_name -> tunnel.HelmInstallRequest
-	17, // 15: tunnel.ServerMessage.terminalStream:type_name -> tunnel.TerminalStream
-	18, // 16: tunnel.ServerMessage.dbuiRequest:type_name -> tunnel.DbUiRequest
-	22, // 17: tunnel.ProxyRequest.headers:type_name -> tunnel.ProxyRequest.HeadersEntry
-	0,  // 18: tunnel.ProxyResponse.status:type_name -> tunnel.ProxyResponseType
-	23, // 19: tunnel.ProxyResponse.headers:type_name -> tunnel.ProxyResponse.HeadersEntry
-	16, // 20: tunnel.ProjectsResponse.projects:type_name -> tunnel.Project
-	24, // 21: tunnel.DbUiRequest.labels:type_name -> tunnel.DbUiRequest.LabelsEntry
-	1,  // 22: tunnel.TunnelService.Connect:input_type -> tunnel.AgentMessage
-	2,  // 23: tunnel.TunnelService.Connect:output_type -> tunnel.ServerMessage
-	23, // [23:24] is the sub-list for method output_type
-	22, // [22:23] is the sub-list for method input_type
-	22, // [22:22] is the sub-list for extension type_name
-	22, // [22:22] is the sub-list for extension extendee
-	0,  // [0:22] is the sub-list for field type_name
+	7,  // 0: tunnel.AgentMessage.registration:type_name -> tunnel.RegistrationRequest
+	17, // 1: tunnel.AgentMessage.status:type_name -> tunnel.StatusUpdate
+	21, // 2: tunnel.AgentMessage.proxy:type_name -> tunnel.ProxyResponse
+	29, // 3: tunnel.AgentMessage.helmValues:type_name -> tunnel.HelmValuesResponse
+	28, // 4: tunnel.AgentMessage.helmDelete:type_name -> tunnel.HelmDeleteResponse
+	30, // 5: tunnel.AgentMessage.helmInstall:type_name -> tunnel.HelmInstallResponse
+	32, // 6: tunnel.AgentMessage.terminalStream:type_name -> tunnel.TerminalStream
+	35, // 7: tunnel.AgentMessage.pgwebResponse:type_name -> tunnel.PgWebResponse
+	11, // 8: tunnel.AgentMessage.certificateRenewal:type_name -> tunnel.CertificateRenewalRequest
+	16, // 9: tunnel.AgentMessage.upgradeStatus:type_name -> tunnel.AgentUpgradeStatus
+	13, // 10: tunnel.AgentMessage.credit:type_name -> tunnel.FlowCredit
+	19, // 11: tunnel.AgentMessage.portForward:type_name -> tunnel.PortForward
+	8,  // 12: tunnel.ServerMessage.registration:type_name -> tunnel.RegistrationResponse
+	17, // 13: tunnel.ServerMessage.status:type_name -> tunnel.StatusUpdate
+	18, // 14: tunnel.ServerMessage.proxy:type_name -> tunnel.ProxyRequest
+	24, // 15: tunnel.ServerMessage.projects:type_name -> tunnel.ProjectsRequest
+	25, // 16: tunnel.ServerMessage.helmValuesRequest:type_name -> tunnel.HelmValuesRequest
+	26, // 17: tunnel.ServerMessage.helmDeleteRequest:type_name -> tunnel.HelmDeleteRequest
+	27, // 18: tunnel.ServerMessage.helmInstallRequest:type_name -> tunnel.HelmInstallRequest
+	32, // 19: tunnel.ServerMessage.terminalStream:type_name -> tunnel.TerminalStream
+	34, // 20: tunnel.ServerMessage.dbuiRequest:type_name -> tunnel.DbUiRequest
+	12, // 21: tunnel.ServerMessage.certificateRenewal:type_name -> tunnel.CertificateRenewalResponse
+	15, // 22: tunnel.ServerMessage.upgrade:type_name -> tunnel.AgentUpgradeRequest
+	14, // 23: tunnel.ServerMessage.drain:type_name -> tunnel.DrainNotice
+	13, // 24: tunnel.ServerMessage.credit:type_name -> tunnel.FlowCredit
+	20, // 25: tunnel.ServerMessage.requestBody:type_name -> tunnel.RequestBody
+	19, // 26: tunnel.ServerMessage.portForward:type_name -> tunnel.PortForward
+	38, // 27: tunnel.ServerMessage.trace_context:type_name -> tunnel.ServerMessage.TraceContextEntry
+	0,  // 28: tunnel.AgentUpgradeStatus.state:type_name -> tunnel.UpgradeState
+	39, // 29: tunnel.ProxyRequest.headers:type_name -> tunnel.ProxyRequest.HeadersEntry
+	1,  // 30: tunnel.PortForward.type:type_name -> tunnel.PortForwardType
+	2,  // 31: tunnel.ProxyResponse.status:type_name -> tunnel.ProxyResponseType
+	40, // 32: tunnel.ProxyResponse.headers:type_name -> tunnel.ProxyResponse.HeadersEntry
+	22, // 33: tunnel.ProxyResponse.error:type_name -> tunnel.TunnelError
+	3,  // 34: tunnel.TunnelError.code:type_name -> tunnel.TunnelErrorCode
+	41, // 35: tunnel.TunnelError.details:type_name -> tunnel.TunnelError.DetailsEntry
+	31, // 36: tunnel.ProjectsResponse.projects:type_name -> tunnel.Project
+	22, // 37: tunnel.TerminalStream.error:type_name -> tunnel.TunnelError
+	4,  // 38: tunnel.TerminalStream.type:type_name -> tunnel.TerminalMessageType
+	33, // 39: tunnel.TerminalStream.size:type_name -> tunnel.TerminalSize
+	42, // 40: tunnel.DbUiRequest.labels:type_name -> tunnel.DbUiRequest.LabelsEntry
+	5,  // 41: tunnel.TunnelService.Connect:input_type -> tunnel.AgentMessage
+	9,  // 42: tunnel.TunnelService.Enroll:input_type -> tunnel.EnrollRequest
+	6,  // 43: tunnel.TunnelService.Connect:output_type -> tunnel.ServerMessage
+	10, // 44: tunnel.TunnelService.Enroll:output_type -> tunnel.EnrollResponse
+	43, // [43:45] is the sub-list for method output_type
+	41, // [41:43] is the sub-list for method input_type
+	41, // [41:41] is the sub-list for extension type_name
+	41, // [41:41] is the sub-list for extension extendee
+	0,  // [0:41] is the sub-list for field type_name
 }
 
 func init() { file_tunnel_proto_init() }
@@ -2071,7 +3669,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[4].Exporter = func(v any, i int) any {
-			switch v := v.(*StatusUpdate); i {
+			switch v := v.(*EnrollRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2083,7 +3681,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[5].Exporter = func(v any, i int) any {
-			switch v := v.(*ProxyRequest); i {
+			switch v := v.(*EnrollResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2095,7 +3693,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[6].Exporter = func(v any, i int) any {
-			switch v := v.(*ProxyResponse); i {
+			switch v := v.(*CertificateRenewalRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2107,7 +3705,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[7].Exporter = func(v any, i int) any {
-			switch v := v.(*ProjectsResponse); i {
+			switch v := v.(*CertificateRenewalResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2119,7 +3717,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[8].Exporter = func(v any, i int) any {
-			switch v := v.(*ProjectsRequest); i {
+			switch v := v.(*FlowCredit); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2131,7 +3729,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[9].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmValuesRequest); i {
+			switch v := v.(*DrainNotice); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2143,7 +3741,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[10].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmDeleteRequest); i {
+			switch v := v.(*AgentUpgradeRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2155,7 +3753,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[11].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmInstallRequest); i {
+			switch v := v.(*AgentUpgradeStatus); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2167,7 +3765,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[12].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmDeleteResponse); i {
+			switch v := v.(*StatusUpdate); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2179,7 +3777,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[13].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmValuesResponse); i {
+			switch v := v.(*ProxyRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2191,7 +3789,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[14].Exporter = func(v any, i int) any {
-			switch v := v.(*HelmInstallResponse); i {
+			switch v := v.(*PortForward); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2203,7 +3801,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[15].Exporter = func(v any, i int) any {
-			switch v := v.(*Project); i {
+			switch v := v.(*RequestBody); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2215,7 +3813,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[16].Exporter = func(v any, i int) any {
-			switch v := v.(*TerminalStream); i {
+			switch v := v.(*ProxyResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2227,7 +3825,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[17].Exporter = func(v any, i int) any {
-			switch v := v.(*DbUiRequest); i {
+			switch v := v.(*TunnelError); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2239,7 +3837,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[18].Exporter = func(v any, i int) any {
-			switch v := v.(*PgWebResponse); i {
+			switch v := v.(*ProjectsResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2251,7 +3849,7 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[19].Exporter = func(v any, i int) any {
-			switch v := v.(*StopPgWebRequest); i {
+			switch v := v.(*ProjectsRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -2263,6 +3861,150 @@ func file_tunnel_proto_init() {
 			}
 		}
 		file_tunnel_proto_msgTypes[20].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmValuesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[21].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmDeleteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[22].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmInstallRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[23].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmDeleteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[24].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmValuesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[25].Exporter = func(v any, i int) any {
+			switch v := v.(*HelmInstallResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[26].Exporter = func(v any, i int) any {
+			switch v := v.(*Project); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[27].Exporter = func(v any, i int) any {
+			switch v := v.(*TerminalStream); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[28].Exporter = func(v any, i int) any {
+			switch v := v.(*TerminalSize); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[29].Exporter = func(v any, i int) any {
+			switch v := v.(*DbUiRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[30].Exporter = func(v any, i int) any {
+			switch v := v.(*PgWebResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[31].Exporter = func(v any, i int) any {
+			switch v := v.(*StopPgWebRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_tunnel_proto_msgTypes[32].Exporter = func(v any, i int) any {
 			switch v := v.(*StopPgWebResponse); i {
 			case 0:
 				return &v.state
@@ -2284,6 +4026,10 @@ func file_tunnel_proto_init() {
 		(*AgentMessage_HelmInstall)(nil),
 		(*AgentMessage_TerminalStream)(nil),
 		(*AgentMessage_PgwebResponse)(nil),
+		(*AgentMessage_CertificateRenewal)(nil),
+		(*AgentMessage_UpgradeStatus)(nil),
+		(*AgentMessage_Credit)(nil),
+		(*AgentMessage_PortForward)(nil),
 	}
 	file_tunnel_proto_msgTypes[1].OneofWrappers = []any{
 		(*ServerMessage_Registration)(nil),
@@ -2295,14 +4041,20 @@ func file_tunnel_proto_init() {
 		(*ServerMessage_HelmInstallRequest)(nil),
 		(*ServerMessage_TerminalStream)(nil),
 		(*ServerMessage_DbuiRequest)(nil),
+		(*ServerMessage_CertificateRenewal)(nil),
+		(*ServerMessage_Upgrade)(nil),
+		(*ServerMessage_Drain)(nil),
+		(*ServerMessage_Credit)(nil),
+		(*ServerMessage_RequestBody)(nil),
+		(*ServerMessage_PortForward)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_tunnel_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   24,
+			NumEnums:      5,
+			NumMessages:   38,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
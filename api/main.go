@@ -3,9 +3,13 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/uc-cdis/gen3-admin/internal/server"
+	"github.com/uc-cdis/gen3-admin/internal/tracing"
 
 	"github.com/joho/godotenv"
 
@@ -29,8 +33,13 @@ func main() {
 		}
 	}
 
-	// initialize agents from certs
-	server.InitializeAgentsFromCerts()
+	// load the agent registry, importing any agents that only exist as certs
+	if err := server.InitializeAgentRegistry(); err != nil {
+		log.Fatal().Err(err).Msg("Error initializing agent registry")
+	}
+	if err := server.InitializeRouting(); err != nil {
+		log.Fatal().Err(err).Msg("Error initializing agent routing")
+	}
 	// db, err := initializeDatabase()
 	// if err != nil {
 	// 	log.Fatal().Err(err).Msg("Error initializing database")
@@ -40,7 +49,26 @@ func main() {
 	// ctx, cancel := context.WithCancel(context.Background())
 	// defer cancel()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.ConfigFromEnv("gen3-admin-api", ""))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error initializing tracing")
+	}
+
 	server.SetupGRCPServer()
-	server.SetupHTTPServer()
+	go server.SetupHTTPServer()
+
+	<-ctx.Done()
+	stop()
+	log.Info().Msg("Shutdown signal received")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.ShutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush traces")
+	}
 
 }
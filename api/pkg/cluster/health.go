@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/uc-cdis/gen3-admin/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type Health struct {
+	Nodes            int `json:"nodes"`
+	NotReadyNodes    int `json:"notReadyNodes"`
+	PodCapacity      int `json:"podCapacity"`
+	PodCount         int `json:"podCount"`
+	PendingPods      int `json:"pendingPods"`
+	CrashLoopingPods int `json:"crashLoopingPods"`
+	FailingPVCs      int `json:"failingPVCs"`
+}
+
+// Healthy reports whether nothing in the cluster needs attention.
+func (h *Health) Healthy() bool {
+	return h.NotReadyNodes == 0 && h.CrashLoopingPods == 0 && h.FailingPVCs == 0
+}
+
+// GetClusterHealth summarizes node, pod and PVC state across the whole cluster.
+func GetClusterHealth(ctx context.Context) (*Health, error) {
+	client, _, err := config.K8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	health := Health{}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		health.Nodes++
+		health.PodCapacity += int(node.Status.Allocatable.Pods().Value())
+		if !nodeReady(&node) {
+			health.NotReadyNodes++
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(corev1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		// Completed pods don't take up a slot on the node
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeName != "" {
+			health.PodCount++
+		}
+		if pod.Status.Phase == corev1.PodPending {
+			health.PendingPods++
+		}
+		if crashLooping(&pod) {
+			health.CrashLoopingPods++
+		}
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(corev1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs.Items {
+		if pvc.Status.Phase == corev1.ClaimPending || pvc.Status.Phase == corev1.ClaimLost {
+			health.FailingPVCs++
+		}
+	}
+
+	return &health, nil
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func crashLooping(pod *corev1.Pod) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+				return true
+			}
+		}
+	}
+	return false
+}
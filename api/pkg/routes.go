@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 	"github.com/uc-cdis/gen3-admin/pkg/awspkg"
 	"github.com/uc-cdis/gen3-admin/pkg/squid"
 )
@@ -28,7 +29,7 @@ func Routes(route *gin.Engine) {
 	{
 		squids.GET("/asgs", squid.ListASGsHandler)
 		squids.GET("/proxies", squid.GetProxiesHandler)
-		squids.POST("/swap", squid.SwapProxyHandler)
+		squids.POST("/swap", audit.Action("squid.swap"), squid.SwapProxyHandler)
 	}
 
 	ssm := route.Group("/api/ssm")
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/uc-cdis/gen3-admin/internal/audit"
 )
 
 // ListASGsHandler returns all the Squid Auto Scaling Groups
@@ -78,6 +80,7 @@ func SwapProxyHandler(c *gin.Context) {
 	}
 
 	// Perform the proxy swap
+	audit.Describe(c, "squid "+envName, "swap to instance "+requestBody.InstanceID)
 	err := SwapProxy(envName, requestBody.InstanceID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})